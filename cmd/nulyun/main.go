@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -15,6 +16,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -25,10 +27,13 @@ import (
 	"github.com/nulnl/nulyun/internal/auth"
 	"github.com/nulnl/nulyun/internal/files"
 	fbhttp "github.com/nulnl/nulyun/internal/handler"
+	"github.com/nulnl/nulyun/internal/mail"
 	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/keyring"
 	"github.com/nulnl/nulyun/internal/model/users"
 	storage "github.com/nulnl/nulyun/internal/repository"
 	"github.com/nulnl/nulyun/internal/repository/bolt"
+	"github.com/nulnl/nulyun/internal/service"
 	"github.com/nulnl/nulyun/www"
 )
 
@@ -52,18 +57,55 @@ var (
 	// Token settings
 	tokenExpirationTime     = flag.String("tokenExpirationTime", "2h", "user session timeout")
 	totpTokenExpirationTime = flag.String("totpTokenExpirationTime", "2m", "user totp session timeout to login")
+	jwtAlgorithm            = flag.String("jwtAlgorithm", keyring.AlgHS256, "token signing algorithm (HS256, EdDSA or RS256)")
+	jwtKeyFile              = flag.String("jwtKeyFile", "", "JSON keyring file with the token signing keys (overrides the database keyring)")
+	jwtKeyRotation          = flag.String("jwtKeyRotation", "720h", "token signing key rotation interval (0 to disable)")
 
 	// Feature flags
 	disableThumbnails            = flag.Bool("disableThumbnails", false, "disable image thumbnails")
 	disablePreviewResize         = flag.Bool("disablePreviewResize", false, "disable resize of image previews")
 	disableTypeDetectionByHeader = flag.Bool("disableTypeDetectionByHeader", false, "disables type detection by reading file headers")
 	disableTOTP                  = flag.Bool("disableTOTP", false, "disable TOTP authentication feature")
+	disableSearchIndex           = flag.Bool("disableSearchIndex", false, "disable the search index, searches walk the disk")
 
 	// Quick setup flags
 	noauth   = flag.Bool("noauth", false, "use the noauth auther when using quick setup")
 	username = flag.String("username", "admin", "username for the first user when using quick setup")
 	password = flag.String("password", "", "hashed password for the first user when using quick setup")
 
+	// Email
+	publicURL    = flag.String("publicURL", "", "external URL of the app used in the links of emails, required by the email verification")
+	smtpHost     = flag.String("smtpHost", "", "SMTP server used to send emails")
+	smtpPort     = flag.Int("smtpPort", 587, "SMTP server port (465 for implicit TLS)")
+	smtpUsername = flag.String("smtpUsername", "", "SMTP username")
+	smtpPassword = flag.String("smtpPassword", "", "SMTP password")
+	smtpFrom     = flag.String("smtpFrom", "", "sender address of the emails")
+
+	// Accounts
+	expiredArchiveDir = flag.String("expiredArchiveDir", "", "directory where the homes of expired accounts are archived (disabled if empty)")
+
+	// Audit log
+	auditMaxAge     = flag.String("auditMaxAge", "2160h", "how long the audit log entries are kept (0 to keep them forever)")
+	auditMaxEntries = flag.Int("auditMaxEntries", 0, "maximum number of audit log entries kept (0 for unlimited)")
+
+	// Realtime events
+	watchFiles = flag.Bool("watchFiles", false, "watch the root for changes made outside of the app and notify the clients")
+
+	// Antivirus
+	clamdAddress  = flag.String("clamd", "", "clamd address scanning the uploads, as tcp://host:port or unix:///path (disabled if empty)")
+	quarantineDir = flag.String("quarantineDir", "", "directory where the infected uploads are moved (defaults to quarantine next to the database)")
+
+	// Search
+	searchIndex         = flag.String("searchIndex", "", "search index file (defaults to search.db next to the database)")
+	searchIndexInterval = flag.String("searchIndexInterval", "6h", "interval between two reconciliations of the search index with the disk (0 to disable)")
+	indexContent        = flag.Bool("indexContent", false, "index the content of the text files for the content: searches")
+	contentMaxSize      = flag.String("contentMaxSize", "1M", "size beyond which the content of the files isn't indexed nor searched (e.g., 500K, 10M)")
+
+	// Maintenance
+	rotateTOTPKey = flag.Bool("rotateTOTPKey", false, "re-encrypt the TOTP secrets with a new key and exit")
+	totpKeyFile   = flag.String("totpKeyFile", "", "file with the new TOTP encryption key (32 bytes, raw or base64), generated if empty")
+	dryRun        = flag.Bool("dryRun", false, "check the maintenance operation without saving its changes")
+
 	// Other
 	imageProcessors = flag.Int("imageProcessors", 4, "image processors count")
 
@@ -84,10 +126,30 @@ type Config struct {
 	CacheDir                     string `json:"cacheDir,omitempty"`
 	TokenExpirationTime          string `json:"tokenExpirationTime,omitempty"`
 	TotpTokenExpirationTime      string `json:"totpTokenExpirationTime,omitempty"`
+	JWTAlgorithm                 string `json:"jwtAlgorithm,omitempty"`
+	JWTKeyFile                   string `json:"jwtKeyFile,omitempty"`
+	JWTKeyRotation               string `json:"jwtKeyRotation,omitempty"`
+	PublicURL                    string `json:"publicURL,omitempty"`
+	SMTPHost                     string `json:"smtpHost,omitempty"`
+	SMTPPort                     *int   `json:"smtpPort,omitempty"`
+	SMTPUsername                 string `json:"smtpUsername,omitempty"`
+	SMTPPassword                 string `json:"smtpPassword,omitempty"`
+	SMTPFrom                     string `json:"smtpFrom,omitempty"`
+	ExpiredArchiveDir            string `json:"expiredArchiveDir,omitempty"`
+	AuditMaxAge                  string `json:"auditMaxAge,omitempty"`
+	AuditMaxEntries              *int   `json:"auditMaxEntries,omitempty"`
+	WatchFiles                   *bool  `json:"watchFiles,omitempty"`
+	Clamd                        string `json:"clamd,omitempty"`
+	QuarantineDir                string `json:"quarantineDir,omitempty"`
+	SearchIndex                  string `json:"searchIndex,omitempty"`
+	SearchIndexInterval          string `json:"searchIndexInterval,omitempty"`
+	IndexContent                 *bool  `json:"indexContent,omitempty"`
+	ContentMaxSize               string `json:"contentMaxSize,omitempty"`
 	DisableThumbnails            *bool  `json:"disableThumbnails,omitempty"`
 	DisablePreviewResize         *bool  `json:"disablePreviewResize,omitempty"`
 	DisableTypeDetectionByHeader *bool  `json:"disableTypeDetectionByHeader,omitempty"`
 	DisableTOTP                  *bool  `json:"disableTOTP,omitempty"`
+	DisableSearchIndex           *bool  `json:"disableSearchIndex,omitempty"`
 	Noauth                       *bool  `json:"noauth,omitempty"`
 	Username                     string `json:"username,omitempty"`
 	Password                     string `json:"password,omitempty"`
@@ -105,11 +167,74 @@ func main() {
 		}
 	}
 
+	if *rotateTOTPKey {
+		if err := rotateTOTPEncryptionKey(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if err := run(); err != nil {
 		log.Fatal(err)
 	}
 }
 
+// rotateTOTPEncryptionKey re-encrypts the TOTP secrets of every user with a
+// new key. The server must be stopped as it holds the database lock.
+func rotateTOTPEncryptionKey() error {
+	newKey, err := readTOTPKey(*totpKeyFile)
+	if err != nil {
+		return err
+	}
+
+	db, err := storm.Open(*database, storm.BoltOptions(0640, nil))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	count, err := bolt.RotateTOTPKey(db, newKey, *dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to rotate the TOTP encryption key: %w", err)
+	}
+
+	if *dryRun {
+		log.Printf("Dry run: %d TOTP secrets can be re-encrypted, nothing was saved", count)
+	} else {
+		log.Printf("Re-encrypted %d TOTP secrets with the new key", count)
+	}
+
+	return nil
+}
+
+// readTOTPKey reads a 32 bytes key, raw or base64 encoded, from path or
+// generates one when path is empty.
+func readTOTPKey(path string) ([]byte, error) {
+	if path == "" {
+		k, err := settings.GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+		return k[:32], nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TOTP key file: %w", err)
+	}
+
+	if len(data) == 32 {
+		return data, nil
+	}
+
+	k, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(k) != 32 {
+		return nil, errors.New("TOTP key file must contain 32 bytes, raw or base64 encoded")
+	}
+
+	return k, nil
+}
+
 func run() error {
 	// Check if database exists
 	databaseExisted, err := dbExists(*database)
@@ -174,6 +299,18 @@ func run() error {
 		return err
 	}
 
+	if err := setupKeyring(st, server); err != nil {
+		return err
+	}
+
+	go expireAccounts(st, server)
+
+	auditAge, err := time.ParseDuration(*auditMaxAge)
+	if err != nil {
+		return fmt.Errorf("invalid audit log retention: %w", err)
+	}
+	go pruneAuditLog(st, auditAge, *auditMaxEntries)
+
 	// Create listener
 	adr := server.Address + ":" + server.Port
 	var listener net.Listener
@@ -294,6 +431,63 @@ func loadConfig(path string) error {
 	if cfg.TotpTokenExpirationTime != "" && !isFlagSet("totpTokenExpirationTime") {
 		*totpTokenExpirationTime = cfg.TotpTokenExpirationTime
 	}
+	if cfg.JWTAlgorithm != "" && !isFlagSet("jwtAlgorithm") {
+		*jwtAlgorithm = cfg.JWTAlgorithm
+	}
+	if cfg.JWTKeyFile != "" && !isFlagSet("jwtKeyFile") {
+		*jwtKeyFile = cfg.JWTKeyFile
+	}
+	if cfg.JWTKeyRotation != "" && !isFlagSet("jwtKeyRotation") {
+		*jwtKeyRotation = cfg.JWTKeyRotation
+	}
+	if cfg.PublicURL != "" && !isFlagSet("publicURL") {
+		*publicURL = cfg.PublicURL
+	}
+	if cfg.SMTPHost != "" && !isFlagSet("smtpHost") {
+		*smtpHost = cfg.SMTPHost
+	}
+	if cfg.SMTPPort != nil && !isFlagSet("smtpPort") {
+		*smtpPort = *cfg.SMTPPort
+	}
+	if cfg.SMTPUsername != "" && !isFlagSet("smtpUsername") {
+		*smtpUsername = cfg.SMTPUsername
+	}
+	if cfg.SMTPPassword != "" && !isFlagSet("smtpPassword") {
+		*smtpPassword = cfg.SMTPPassword
+	}
+	if cfg.SMTPFrom != "" && !isFlagSet("smtpFrom") {
+		*smtpFrom = cfg.SMTPFrom
+	}
+	if cfg.ExpiredArchiveDir != "" && !isFlagSet("expiredArchiveDir") {
+		*expiredArchiveDir = cfg.ExpiredArchiveDir
+	}
+	if cfg.AuditMaxAge != "" && !isFlagSet("auditMaxAge") {
+		*auditMaxAge = cfg.AuditMaxAge
+	}
+	if cfg.AuditMaxEntries != nil && !isFlagSet("auditMaxEntries") {
+		*auditMaxEntries = *cfg.AuditMaxEntries
+	}
+	if cfg.WatchFiles != nil && !isFlagSet("watchFiles") {
+		*watchFiles = *cfg.WatchFiles
+	}
+	if cfg.Clamd != "" && !isFlagSet("clamd") {
+		*clamdAddress = cfg.Clamd
+	}
+	if cfg.QuarantineDir != "" && !isFlagSet("quarantineDir") {
+		*quarantineDir = cfg.QuarantineDir
+	}
+	if cfg.SearchIndex != "" && !isFlagSet("searchIndex") {
+		*searchIndex = cfg.SearchIndex
+	}
+	if cfg.SearchIndexInterval != "" && !isFlagSet("searchIndexInterval") {
+		*searchIndexInterval = cfg.SearchIndexInterval
+	}
+	if cfg.IndexContent != nil && !isFlagSet("indexContent") {
+		*indexContent = *cfg.IndexContent
+	}
+	if cfg.ContentMaxSize != "" && !isFlagSet("contentMaxSize") {
+		*contentMaxSize = cfg.ContentMaxSize
+	}
 	if cfg.DisableThumbnails != nil && !isFlagSet("disableThumbnails") {
 		*disableThumbnails = *cfg.DisableThumbnails
 	}
@@ -306,6 +500,9 @@ func loadConfig(path string) error {
 	if cfg.DisableTOTP != nil && !isFlagSet("disableTOTP") {
 		*disableTOTP = *cfg.DisableTOTP
 	}
+	if cfg.DisableSearchIndex != nil && !isFlagSet("disableSearchIndex") {
+		*disableSearchIndex = *cfg.DisableSearchIndex
+	}
 	if cfg.Noauth != nil && !isFlagSet("noauth") {
 		*noauth = *cfg.Noauth
 	}
@@ -375,6 +572,32 @@ func getServerSettings(st *storage.Storage) (*settings.Server, error) {
 	server.ResizePreview = !*disablePreviewResize
 	server.TypeDetectionByHeader = !*disableTypeDetectionByHeader
 	server.EnableTOTP = !*disableTOTP
+	server.PublicURL = *publicURL
+	server.WatchFiles = *watchFiles
+	server.Clamd = *clamdAddress
+	server.QuarantineDir = *quarantineDir
+	if server.QuarantineDir == "" {
+		server.QuarantineDir = filepath.Join(filepath.Dir(*database), "quarantine")
+	}
+	if !*disableSearchIndex {
+		server.SearchIndex = *searchIndex
+		if server.SearchIndex == "" {
+			server.SearchIndex = filepath.Join(filepath.Dir(*database), "search.db")
+		}
+	}
+	server.SearchIndexInterval = *searchIndexInterval
+	server.IndexContent = *indexContent
+	server.ContentMaxSize, err = users.ParseQuotaString(*contentMaxSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content max size: %w", err)
+	}
+	server.SMTP = mail.SMTP{
+		Host:     *smtpHost,
+		Port:     *smtpPort,
+		Username: *smtpUsername,
+		Password: *smtpPassword,
+		From:     *smtpFrom,
+	}
 
 	return server, nil
 }
@@ -530,6 +753,100 @@ func setTOTPEncryptionKey(set *settings.Settings, store *storage.Storage) error
 	return store.Settings.Save(set)
 }
 
+// keyringCheckInterval is the delay between two checks of the signing keys
+// for rotation and expiry.
+const keyringCheckInterval = time.Hour
+
+// setupKeyring loads the token signing keys from the key file or the
+// NULYUN_JWT_KEYS environment variable when provided. Otherwise the keys are
+// kept in the database and rotated in the background.
+func setupKeyring(st *storage.Storage, server *settings.Server) error {
+	var data []byte
+	switch {
+	case *jwtKeyFile != "":
+		var err error
+		data, err = os.ReadFile(*jwtKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read jwt key file: %w", err)
+		}
+	case os.Getenv("NULYUN_JWT_KEYS") != "":
+		data = []byte(os.Getenv("NULYUN_JWT_KEYS"))
+	}
+
+	if data != nil {
+		ring, err := keyring.Parse(data)
+		if err != nil {
+			return fmt.Errorf("invalid jwt keyring: %w", err)
+		}
+		return st.Keyring.Use(ring)
+	}
+
+	every, err := time.ParseDuration(*jwtKeyRotation)
+	if err != nil {
+		return fmt.Errorf("invalid jwt key rotation interval: %w", err)
+	}
+
+	tokenTTL, totpTTL := server.GetTokenExpirationTime(fbhttp.DefaultTokenExpirationTime, fbhttp.DefaultTOTPTokenExpirationTime)
+	ttl := max(tokenTTL, totpTTL)
+
+	maintain := func() error {
+		rotated, err := st.Keyring.Maintain(*jwtAlgorithm, every, ttl)
+		if rotated {
+			log.Printf("Rotated the token signing key (%s)", *jwtAlgorithm)
+		}
+		return err
+	}
+
+	if err := maintain(); err != nil {
+		return err
+	}
+
+	go func() {
+		for range time.Tick(keyringCheckInterval) {
+			if err := maintain(); err != nil {
+				log.Printf("failed to rotate the token signing key: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// accountsCheckInterval is the delay between two checks of the expired
+// accounts.
+const accountsCheckInterval = time.Hour
+
+// expireAccounts periodically disables the accounts past their expiry date.
+func expireAccounts(st *storage.Storage, server *settings.Server) {
+	for {
+		if _, err := service.ExpireAccounts(context.Background(), st, server, *expiredArchiveDir); err != nil {
+			log.Printf("failed to disable the expired accounts: %v", err)
+		}
+		time.Sleep(accountsCheckInterval)
+	}
+}
+
+// auditPruneInterval is the delay between two prunings of the audit log.
+const auditPruneInterval = time.Hour
+
+// pruneAuditLog periodically removes the audit log entries beyond the
+// retention limits.
+func pruneAuditLog(st *storage.Storage, maxAge time.Duration, maxEntries int) {
+	if maxAge <= 0 && maxEntries <= 0 {
+		return
+	}
+
+	for {
+		n, err := st.Audit.Prune(maxAge, maxEntries)
+		if err != nil {
+			log.Printf("failed to prune the audit log: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d audit log entries", n)
+		}
+		time.Sleep(auditPruneInterval)
+	}
+}
+
 func generateKey() []byte {
 	k, err := settings.GenerateKey()
 	if err != nil {
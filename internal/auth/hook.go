@@ -232,6 +232,7 @@ func (a *HookAuth) GetUser(d *users.User) *users.User {
 		HideDotfiles:      a.Fields.GetBoolean("user.hideDotfiles", d.HideDotfiles),
 		HideHiddenFolders: a.Fields.GetBoolean("user.hideHiddenFolders", d.HideHiddenFolders),
 		Perm:              perms,
+		Commands:          a.Fields.GetArray("user.commands", d.Commands),
 		LockPassword:      true,
 	}
 
@@ -262,6 +263,7 @@ var validHookFields = []string{
 	"user.perm.delete",
 	"user.perm.share",
 	"user.perm.download",
+	"user.commands",
 }
 
 // IsValid checks if the provided field is on the valid fields list
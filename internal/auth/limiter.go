@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	settings "github.com/nulnl/nulyun/internal/model/global"
+)
+
+// limiterMinWindow is the minimum time a failure is remembered for.
+const limiterMinWindow = time.Hour
+
+type limiterEntry struct {
+	failures     uint
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+// Limiter throttles failed authentication attempts. Attempts are tracked
+// per key (e.g. an IP address, a username or a share hash) and every
+// failure past the allowed attempts doubles the time the key is blocked.
+type Limiter struct {
+	mux       sync.Mutex
+	entries   map[string]*limiterEntry
+	lastSweep time.Time
+}
+
+// NewLimiter creates an empty limiter.
+func NewLimiter() *Limiter {
+	return &Limiter{entries: map[string]*limiterEntry{}}
+}
+
+// Check returns how long to wait before another attempt is allowed for
+// any of the keys. A zero duration means the attempt is allowed.
+func (l *Limiter) Check(cfg settings.BruteForce, keys ...string) time.Duration {
+	if !cfg.Enabled {
+		return 0
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range keys {
+		if e, ok := l.entries[key]; ok && e.blockedUntil.After(now) {
+			wait = max(wait, e.blockedUntil.Sub(now))
+		}
+	}
+
+	return wait
+}
+
+// Fail records a failed attempt for every key. It returns the highest
+// number of consecutive failures among the keys.
+func (l *Limiter) Fail(cfg settings.BruteForce, keys ...string) uint {
+	if !cfg.Enabled {
+		return 0
+	}
+
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	now := time.Now()
+	window := max(2*time.Duration(cfg.MaxDelay)*time.Second, limiterMinWindow)
+	l.sweep(now, window)
+
+	var failures uint
+	for _, key := range keys {
+		e, ok := l.entries[key]
+		if !ok || now.Sub(e.lastFailure) > window {
+			e = &limiterEntry{}
+			l.entries[key] = e
+		}
+
+		e.failures++
+		e.lastFailure = now
+		if delay := cfg.Delay(e.failures); delay > 0 {
+			e.blockedUntil = now.Add(delay)
+		}
+		failures = max(failures, e.failures)
+	}
+
+	return failures
+}
+
+// Reset forgets the failures recorded for the keys.
+func (l *Limiter) Reset(keys ...string) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	for _, key := range keys {
+		delete(l.entries, key)
+	}
+}
+
+// sweep drops the entries that haven't failed within the window.
+func (l *Limiter) sweep(now time.Time, window time.Duration) {
+	if now.Sub(l.lastSweep) < window {
+		return
+	}
+
+	for key, e := range l.entries {
+		if now.Sub(e.lastFailure) > window && now.After(e.blockedUntil) {
+			delete(l.entries, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	settings "github.com/nulnl/nulyun/internal/model/global"
+)
+
+func TestLimiter(t *testing.T) {
+	cfg := settings.BruteForce{
+		Enabled:     true,
+		MaxAttempts: 2,
+		BaseDelay:   10,
+		MaxDelay:    25,
+	}
+	l := NewLimiter()
+
+	for i := 0; i < 2; i++ {
+		l.Fail(cfg, "ip:1.2.3.4", "user:admin")
+		if wait := l.Check(cfg, "ip:1.2.3.4"); wait != 0 {
+			t.Fatalf("attempt %d: expected no wait, got %v", i+1, wait)
+		}
+	}
+
+	expected := []time.Duration{10 * time.Second, 20 * time.Second, 25 * time.Second}
+	for i, want := range expected {
+		if failures := l.Fail(cfg, "ip:1.2.3.4", "user:admin"); failures != uint(i+3) {
+			t.Fatalf("expected %d failures, got %d", i+3, failures)
+		}
+
+		wait := l.Check(cfg, "user:admin")
+		if wait <= want-time.Second || wait > want {
+			t.Errorf("failure %d: expected wait close to %v, got %v", i+3, want, wait)
+		}
+	}
+
+	if wait := l.Check(cfg, "ip:5.6.7.8"); wait != 0 {
+		t.Errorf("expected unrelated key not to wait, got %v", wait)
+	}
+
+	l.Reset("user:admin")
+	if wait := l.Check(cfg, "user:admin"); wait != 0 {
+		t.Errorf("expected no wait after reset, got %v", wait)
+	}
+	if wait := l.Check(cfg, "ip:1.2.3.4", "user:admin"); wait == 0 {
+		t.Errorf("expected the ip key to still wait")
+	}
+
+	cfg.Enabled = false
+	if wait := l.Check(cfg, "ip:1.2.3.4"); wait != 0 {
+		t.Errorf("expected disabled limiter not to wait, got %v", wait)
+	}
+}
@@ -0,0 +1,191 @@
+// Package clamd is a client of the ClamAV daemon, scanning streams with
+// the INSTREAM command.
+package clamd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize is the size of the chunks the streams are sent in.
+const chunkSize = 64 << 10
+
+// DefaultTimeout limits a scan when the client sets no timeout.
+const DefaultTimeout = 5 * time.Minute
+
+var (
+	ErrInvalidAddress = errors.New("invalid clamd address")
+	ErrScan           = errors.New("clamd scan failed")
+)
+
+// Result is the verdict of a scan.
+type Result struct {
+	Infected bool `json:"infected"`
+	// Signature is the name of the detected malware, if any.
+	Signature string `json:"signature,omitempty"`
+}
+
+// Client connects to a clamd daemon over TCP or a UNIX socket.
+type Client struct {
+	Network string
+	Address string
+	Timeout time.Duration
+}
+
+// New creates a client from an address such as tcp://127.0.0.1:3310 or
+// unix:///run/clamav/clamd.ctl. Addresses without a scheme are sockets
+// when they are absolute paths, TCP addresses otherwise.
+func New(addr string) (*Client, error) {
+	network, address, found := strings.Cut(addr, "://")
+	if !found {
+		network, address = "tcp", addr
+		if strings.HasPrefix(addr, "/") {
+			network = "unix"
+		}
+	}
+
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAddress, addr)
+		}
+	case "unix":
+		if address == "" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAddress, addr)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAddress, addr)
+	}
+
+	return &Client{Network: network, Address: address, Timeout: DefaultTimeout}, nil
+}
+
+// dial opens a connection whose deadline is the one of ctx, or the timeout
+// of the client.
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.Network, c.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// Ping checks the daemon is answering.
+func (c *Client) Ping(ctx context.Context) error {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return err
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("%w: %s", ErrScan, reply)
+	}
+	return nil
+}
+
+// Scan sends the content of r to the daemon and returns its verdict.
+func (c *Client) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// The daemon stops reading when the stream exceeds its size limit, so
+	// the writes are done aside and its reply is read in any case.
+	done := make(chan error, 1)
+	go func() {
+		done <- writeStream(conn, r)
+	}()
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return nil, err
+	}
+	if err := <-done; err != nil && !strings.HasSuffix(reply, "ERROR") {
+		return nil, err
+	}
+
+	return parseReply(reply)
+}
+
+// writeStream writes the INSTREAM command and r as chunks prefixed by their
+// length, followed by an empty chunk ending the stream.
+func writeStream(w io.Writer, r io.Reader) error {
+	if _, err := w.Write([]byte("zINSTREAM\x00")); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := r.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// readReply reads a reply ended by a null byte.
+func readReply(r io.Reader) (string, error) {
+	reply, err := bufio.NewReader(r).ReadBytes(0)
+	if err != nil && !(errors.Is(err, io.EOF) && len(reply) > 0) {
+		return "", err
+	}
+	return string(bytes.TrimSpace(bytes.TrimSuffix(reply, []byte{0}))), nil
+}
+
+// parseReply reads the verdict of the replies "stream: OK" and
+// "stream: <signature> FOUND".
+func parseReply(reply string) (*Result, error) {
+	verdict := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case verdict == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrScan, reply)
+	}
+}
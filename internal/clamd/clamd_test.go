@@ -0,0 +1,76 @@
+package clamd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nulnl/nulyun/internal/clamd/clamdtest"
+)
+
+func TestNew(t *testing.T) {
+	testCases := map[string]struct {
+		addr    string
+		network string
+		address string
+		err     error
+	}{
+		"tcp":         {"tcp://127.0.0.1:3310", "tcp", "127.0.0.1:3310", nil},
+		"unix":        {"unix:///run/clamav/clamd.ctl", "unix", "/run/clamav/clamd.ctl", nil},
+		"bare tcp":    {"localhost:3310", "tcp", "localhost:3310", nil},
+		"bare socket": {"/run/clamd.sock", "unix", "/run/clamd.sock", nil},
+		"no port":     {"tcp://localhost", "", "", ErrInvalidAddress},
+		"bad scheme":  {"http://localhost:3310", "", "", ErrInvalidAddress},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c, err := New(tc.addr)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("New() error = %v, want %v", err, tc.err)
+			}
+			if err == nil && (c.Network != tc.network || c.Address != tc.address) {
+				t.Errorf("New() = %s %s, want %s %s", c.Network, c.Address, tc.network, tc.address)
+			}
+		})
+	}
+}
+
+func TestScan(t *testing.T) {
+	srv := clamdtest.NewServer()
+	srv.MaxSize = 1 << 20
+	defer srv.Close()
+
+	c, err := New(srv.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+
+	testCases := map[string]struct {
+		content string
+		want    *Result
+		err     error
+	}{
+		"clean":           {"hello world", &Result{}, nil},
+		"empty":           {"", &Result{}, nil},
+		"infected":        {"prefix " + clamdtest.EICAR, &Result{Infected: true, Signature: clamdtest.Signature}, nil},
+		"multiple chunks": {strings.Repeat("a", 3*chunkSize) + clamdtest.EICAR, &Result{Infected: true, Signature: clamdtest.Signature}, nil},
+		"too large":       {strings.Repeat("a", 2<<20), nil, ErrScan},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			res, err := c.Scan(context.Background(), strings.NewReader(tc.content))
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Scan() error = %v, want %v", err, tc.err)
+			}
+			if tc.want != nil && *res != *tc.want {
+				t.Errorf("Scan() = %+v, want %+v", res, tc.want)
+			}
+		})
+	}
+}
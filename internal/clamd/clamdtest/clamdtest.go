@@ -0,0 +1,114 @@
+// Package clamdtest provides a fake clamd daemon for the tests.
+package clamdtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+)
+
+// EICAR is the content of the standard antivirus test file.
+const EICAR = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// Signature is the one reported for the streams containing EICAR.
+const Signature = "Eicar-Test-Signature"
+
+// Server is a fake clamd daemon listening on the loopback interface. It
+// answers PING and INSTREAM, finding the streams containing EICAR infected.
+type Server struct {
+	// MaxSize is the stream size limit, 0 for unlimited.
+	MaxSize int
+
+	ln    net.Listener
+	mux   sync.Mutex
+	scans int
+}
+
+// NewServer starts a fake daemon. The caller closes it when done.
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("clamdtest: failed to listen: " + err.Error())
+	}
+
+	s := &Server{ln: ln}
+	go s.serve()
+	return s
+}
+
+// Address is the address to give to the clients.
+func (s *Server) Address() string {
+	return "tcp://" + s.ln.Addr().String()
+}
+
+// Scans returns the number of streams scanned so far.
+func (s *Server) Scans() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.scans
+}
+
+// Close stops the daemon.
+func (s *Server) Close() {
+	s.ln.Close()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\x00")) }
+
+	cmd, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	switch cmd {
+	case "zPING\x00":
+		reply("PONG")
+	case "zINSTREAM\x00":
+		var stream bytes.Buffer
+		size := make([]byte, 4)
+		for {
+			if _, err := io.ReadFull(r, size); err != nil {
+				return
+			}
+			n := binary.BigEndian.Uint32(size)
+			if n == 0 {
+				break
+			}
+			if _, err := io.CopyN(&stream, r, int64(n)); err != nil {
+				return
+			}
+			if s.MaxSize > 0 && stream.Len() > s.MaxSize {
+				reply("INSTREAM size limit exceeded. ERROR")
+				return
+			}
+		}
+
+		s.mux.Lock()
+		s.scans++
+		s.mux.Unlock()
+
+		if bytes.Contains(stream.Bytes(), []byte(EICAR)) {
+			reply("stream: " + Signature + " FOUND")
+		} else {
+			reply("stream: OK")
+		}
+	default:
+		reply("UNKNOWN COMMAND")
+	}
+}
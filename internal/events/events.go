@@ -0,0 +1,150 @@
+// Package events is an in-process bus broadcasting what happens to the
+// files, shares and users.
+package events
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	FileCreated     = "file.created"
+	FileModified    = "file.modified"
+	FileDeleted     = "file.deleted"
+	FileMoved       = "file.moved"
+	ShareCreated    = "share.created"
+	ShareAccessed   = "share.accessed"
+	UserCreated     = "user.created"
+	UploadCompleted = "upload.completed"
+	FileQuarantined = "file.quarantined"
+
+	// Ping is only sent to test a webhook.
+	Ping = "ping"
+)
+
+// Types lists the event types that can be subscribed to.
+var Types = []string{
+	FileCreated, FileModified, FileDeleted, FileMoved,
+	ShareCreated, ShareAccessed, UserCreated, UploadCompleted, FileQuarantined,
+}
+
+// SourceWatcher is the source of the changes made outside of the app.
+const SourceWatcher = "watcher"
+
+// Event is something that happened to a file, a share or a user. Paths are
+// relative to the scope of the user.
+type Event struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	Time        int64  `json:"time"`
+	UserID      uint   `json:"userID"`
+	Username    string `json:"username,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	Source      string `json:"source,omitempty"`
+
+	// Scope is the directory, relative to the server root, the paths are
+	// relative to.
+	Scope string `json:"-"`
+}
+
+// Stamp sets the ID and the time of the event if missing.
+func (e *Event) Stamp() {
+	if e.ID == "" {
+		e.ID = newID()
+	}
+	if e.Time == 0 {
+		e.Time = time.Now().Unix()
+	}
+}
+
+// Rooted returns the event with its paths relative to the server root.
+func (e Event) Rooted() Event {
+	for _, p := range []*string{&e.Path, &e.Destination} {
+		if *p != "" {
+			*p = path.Join("/", e.Scope, *p)
+		}
+	}
+	e.Scope = "/"
+	return e
+}
+
+// InPath checks if the event concerns the path or its content.
+func (e *Event) InPath(p string) bool {
+	p = strings.TrimSuffix(p, "/")
+	if p == "" {
+		return true
+	}
+
+	for _, name := range []string{e.Path, e.Destination} {
+		if name == p || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Bus broadcasts the published events to its subscribers.
+type Bus struct {
+	mux  sync.RWMutex
+	next int
+	subs map[int]chan Event
+}
+
+// NewBus creates an event bus without subscribers.
+func NewBus() *Bus {
+	return &Bus{subs: map[int]chan Event{}}
+}
+
+// Subscribe returns a channel receiving the events published from now on,
+// and the function to call to stop receiving them. Events are dropped when
+// the channel buffer is full, so a slow subscriber never blocks the others.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+
+	b.mux.Lock()
+	id := b.next
+	b.next++
+	b.subs[id] = ch
+	b.mux.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mux.Lock()
+			delete(b.subs, id)
+			b.mux.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Publish stamps an event and sends it to every subscriber.
+func (b *Bus) Publish(e Event) {
+	e.Stamp()
+
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	for _, ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			log.Printf("events: subscriber too slow, dropped %s %s", e.Type, e.ID)
+		}
+	}
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,174 @@
+package events
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long the changes of a path are collected before
+// being published, so writing a file publishes a single event.
+const watchDebounce = time.Second
+
+// Watch publishes the changes of the files under root, including the ones
+// made outside of the app, until ctx is done. The events have no user and
+// their paths are relative to root.
+func Watch(ctx context.Context, root string, bus *Bus) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watchTree(watcher, root); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(watchDebounce)
+	defer ticker.Stop()
+
+	pending := map[string]string{}
+	// A rename is reported as the rename of the old path, directly
+	// followed by the creation of the new one. renamed is the old path of
+	// the last event when it was a rename.
+	var moves [][2]string
+	renamed := ""
+	// movedDir is the last directory renamed, which then reports its own
+	// rename.
+	var movedDir [2]string
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("events: watcher error: %v", err)
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			typ := watchedType(ev)
+			if typ == "" {
+				continue
+			}
+
+			// The rename of the directory itself, under its old or new
+			// name, stops watching it, so it is watched again.
+			if ev.Has(fsnotify.Rename) && movedDir[0] != "" && (ev.Name == movedDir[0] || ev.Name == movedDir[1]) {
+				if err := watchTree(watcher, movedDir[1]); err != nil {
+					log.Printf("events: failed to watch %s: %v", movedDir[1], err)
+				}
+				movedDir = [2]string{}
+				continue
+			}
+			movedDir = [2]string{}
+
+			isDir := false
+			if typ == FileCreated {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					isDir = true
+					if err := watchTree(watcher, ev.Name); err != nil {
+						log.Printf("events: failed to watch %s: %v", ev.Name, err)
+					}
+				}
+			}
+
+			if typ == FileCreated && renamed != "" {
+				moves = append(moves, [2]string{renamed, ev.Name})
+				if pending[renamed] == FileDeleted {
+					delete(pending, renamed)
+				}
+				if isDir {
+					movedDir = [2]string{renamed, ev.Name}
+				}
+				renamed = ""
+				continue
+			}
+			renamed = ""
+			if ev.Has(fsnotify.Rename) {
+				renamed = ev.Name
+			}
+
+			// A created file stays created while it is being written.
+			if pending[ev.Name] != FileCreated || typ == FileDeleted {
+				pending[ev.Name] = typ
+			}
+		case <-ticker.C:
+			for _, m := range moves {
+				src, ok := watchedPath(root, m[0])
+				if !ok {
+					continue
+				}
+				e := Event{Type: FileDeleted, Path: src, Source: SourceWatcher, Scope: "/"}
+				if dst, ok := watchedPath(root, m[1]); ok {
+					e.Type, e.Destination = FileMoved, dst
+				}
+				bus.Publish(e)
+			}
+			for name, typ := range pending {
+				rel, ok := watchedPath(root, name)
+				if !ok {
+					continue
+				}
+				bus.Publish(Event{
+					Type:   typ,
+					Path:   rel,
+					Source: SourceWatcher,
+					Scope:  "/",
+				})
+			}
+			clear(pending)
+			// A rename isn't paired with a creation seen after the
+			// changes were published.
+			moves, renamed = moves[:0], ""
+		}
+	}
+}
+
+// watchedPath returns the path of name relative to root, and false when
+// it's outside of it.
+func watchedPath(root, name string) (string, bool) {
+	rel, err := filepath.Rel(root, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return "/" + filepath.ToSlash(rel), true
+}
+
+// watchTree watches dir and its subdirectories.
+func watchTree(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// The directory may be gone already.
+			if name != dir {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return watcher.Add(name)
+	})
+}
+
+func watchedType(ev fsnotify.Event) string {
+	switch {
+	case ev.Has(fsnotify.Remove), ev.Has(fsnotify.Rename):
+		return FileDeleted
+	case ev.Has(fsnotify.Create):
+		return FileCreated
+	case ev.Has(fsnotify.Write):
+		return FileModified
+	}
+	return ""
+}
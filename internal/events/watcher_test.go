@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchMoves(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "d", "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	bus := NewBus()
+	ch, _ := bus.Subscribe(16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Watch(ctx, root, bus)
+	time.Sleep(100 * time.Millisecond)
+
+	steps := []func() error{
+		func() error { return os.Rename(filepath.Join(root, "d"), filepath.Join(root, "e")) },
+		func() error { return os.Rename(filepath.Join(root, "a"), filepath.Join(root, "e", "b")) },
+		// The renamed directory is still watched.
+		func() error { return os.WriteFile(filepath.Join(root, "e", "sub", "c"), []byte("c"), 0o644) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	want := map[string]string{
+		"/d":       FileMoved + " /e",
+		"/a":       FileMoved + " /e/b",
+		"/e/sub/c": FileCreated + " ",
+	}
+	got := map[string]string{}
+	timeout := time.After(5 * time.Second)
+	for len(got) < len(want) {
+		select {
+		case e := <-ch:
+			if e.Source != SourceWatcher {
+				t.Errorf("event %+v has source %q", e, e.Source)
+			}
+			got[e.Path] = e.Type + " " + e.Destination
+		case <-timeout:
+			t.Fatalf("got events %v, want %v", got, want)
+		}
+	}
+	for name, w := range want {
+		if got[name] != w {
+			t.Errorf("event of %s = %q, want %q", name, got[name], w)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package files
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/mholt/archives"
+)
+
+// ArchiveDir writes a zip archive of the directory src to dst. The archive
+// is written to a temporary file first so dst is never left incomplete.
+func ArchiveDir(ctx context.Context, src, dst string) error {
+	list, err := archives.FilesFromDisk(ctx, nil, map[string]string{src: filepath.Base(src)})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = archives.Zip{}.Archive(ctx, out, list)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
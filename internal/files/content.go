@@ -0,0 +1,190 @@
+package files
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/nulnl/nulyun/internal/index"
+)
+
+// ContentMaxSize is the size beyond which the content of the files isn't
+// searched.
+var ContentMaxSize int64 = 1 << 20
+
+const (
+	// maxSnippets is the number of snippets returned by file.
+	maxSnippets = 3
+	// snippetContext is the number of characters around a match in its
+	// snippet.
+	snippetContext = 40
+)
+
+// IsText checks if a file is a text file from its name and the first 512
+// bytes of its content, as detectType does.
+func IsText(name string, head []byte) bool {
+	mimetype := mime.TypeByExtension(filepath.Ext(name))
+	if mimetype == "" {
+		mimetype = http.DetectContentType(head)
+	}
+
+	switch {
+	case strings.HasPrefix(mimetype, "video"),
+		strings.HasPrefix(mimetype, "audio"),
+		strings.HasPrefix(mimetype, "image"),
+		strings.HasSuffix(mimetype, "pdf"):
+		return false
+	default:
+		return strings.HasPrefix(mimetype, "text") || !isBinary(head)
+	}
+}
+
+// Snippet is a line of a file matching a content search, split around the
+// match.
+type Snippet struct {
+	Line   int    `json:"line"`
+	Before string `json:"before"`
+	Match  string `json:"match"`
+	After  string `json:"after"`
+}
+
+// contentNode matches the text files containing a phrase, at the start of
+// a word.
+type contentNode struct {
+	phrase        string
+	caseSensitive bool
+	// indexed are the paths of the files which may contain the phrase,
+	// relative to the root of the index, or nil without index.
+	indexed map[string]bool
+}
+
+func newContentNode(phrase string, caseSensitive bool) *contentNode {
+	return &contentNode{
+		phrase:        strings.TrimSpace(index.Fold(phrase, caseSensitive)),
+		caseSensitive: caseSensitive,
+	}
+}
+
+func (n *contentNode) match(c *candidate) bool {
+	if c.open == nil || !c.info.Mode().IsRegular() || c.info.Size() > ContentMaxSize {
+		return false
+	}
+	if n.indexed != nil && !n.indexed[c.key] {
+		return false
+	}
+
+	f, err := c.open()
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	snippets := n.find(f, c.path)
+	c.snippets = append(c.snippets, snippets...)
+	return len(snippets) > 0
+}
+
+// find returns the snippets of the first lines of the text file name
+// containing the phrase.
+func (n *contentNode) find(r io.Reader, name string) []Snippet {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(512)
+	if !IsText(name, head) {
+		return nil
+	}
+
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(nil, int(ContentMaxSize)+1)
+
+	var snippets []Snippet
+	for line := 1; scanner.Scan() && len(snippets) < maxSnippets; line++ {
+		if s, ok := n.findLine(scanner.Text()); ok {
+			s.Line = line
+			snippets = append(snippets, s)
+		}
+	}
+	return snippets
+}
+
+// findLine looks for the phrase in a line, folded as the phrase is. Each
+// normalization segment of the line is folded on its own, so the match can
+// be located in the line.
+func (n *contentNode) findLine(line string) (Snippet, bool) {
+	if n.phrase == "" {
+		return Snippet{}, false
+	}
+
+	var folded strings.Builder
+	// starts and ends are the bounds, in the line, of the segment of each
+	// byte of folded.
+	var starts, ends []int
+	for i := 0; i < len(line); {
+		size := norm.NFKC.NextBoundaryInString(line[i:], true)
+		if size <= 0 {
+			size = len(line) - i
+		}
+		segment := line[i : i+size]
+		switch {
+		case strings.TrimSpace(segment) != "":
+			segment = index.Fold(segment, n.caseSensitive)
+		case strings.HasSuffix(folded.String(), " "):
+			segment = ""
+		default:
+			segment = " "
+		}
+		folded.WriteString(segment)
+		for range len(segment) {
+			starts = append(starts, i)
+			ends = append(ends, i+size)
+		}
+		i += size
+	}
+
+	text := folded.String()
+	first, _ := utf8.DecodeRuneInString(n.phrase)
+	for from := 0; from < len(text); {
+		i := strings.Index(text[from:], n.phrase)
+		if i < 0 {
+			break
+		}
+		i += from
+
+		prev, _ := utf8.DecodeLastRuneInString(text[:i])
+		if i == 0 || index.IsWordStart(prev, first) {
+			start, end := starts[i], ends[i+len(n.phrase)-1]
+			return Snippet{
+				Before: trimStart(line[:start]),
+				Match:  line[start:end],
+				After:  trimEnd(line[end:]),
+			}, true
+		}
+
+		_, size := utf8.DecodeRuneInString(text[i:])
+		from = i + size
+	}
+	return Snippet{}, false
+}
+
+// trimStart keeps the end of the context before a match.
+func trimStart(s string) string {
+	runes := []rune(strings.TrimLeft(s, " \t"))
+	if len(runes) <= snippetContext {
+		return string(runes)
+	}
+	return "…" + string(runes[len(runes)-snippetContext:])
+}
+
+// trimEnd keeps the start of the context after a match.
+func trimEnd(s string) string {
+	runes := []rune(strings.TrimRight(s, " \t\r"))
+	if len(runes) <= snippetContext {
+		return string(runes)
+	}
+	return string(runes[:snippetContext]) + "…"
+}
@@ -0,0 +1,61 @@
+package files
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+type allowAll struct{}
+
+func (allowAll) Check(string) bool { return true }
+
+func TestContentSearch(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	for name, content := range map[string]string{
+		"/notes.txt":  "Meeting notes\n\tThe ﬁnal REPORT is due   on Friday.\nNothing else.",
+		"/report.md":  "# Reports\nreporting period",
+		"/ja.txt":     "昨日、東京タワーに行きました。",
+		"/photo.jpg":  "final report",
+		"/binary.dat": "\x00\x01\x02final report",
+	} {
+		if err := afero.WriteFile(fs, name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(query string) map[string][]Snippet {
+		t.Helper()
+		found := map[string][]Snippet{}
+		err := Search(context.Background(), fs, "/", query, "", allowAll{}, nil, func(path string, _ os.FileInfo, snippets []Snippet) error {
+			found[path] = snippets
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Search(%q) error = %v", query, err)
+		}
+		return found
+	}
+
+	found := search(`content:"final report is"`)
+	want := Snippet{Line: 2, Before: "The ", Match: "ﬁnal REPORT is", After: " due   on Friday."}
+	if len(found) != 1 || !slices.Equal(found["notes.txt"], []Snippet{want}) {
+		t.Errorf("content search = %+v, want notes.txt with %+v", found, want)
+	}
+
+	if found := search("content:report"); len(found) != 2 || found["report.md"][0].Match != "Report" {
+		t.Errorf("prefix search = %+v, want notes.txt and report.md", found)
+	}
+	if found := search("content:eport"); len(found) != 0 {
+		t.Errorf("search within words = %+v, want nothing", found)
+	}
+	if found := search("content:report case:sensitive"); len(found) != 1 {
+		t.Errorf("case sensitive search = %+v, want report.md", found)
+	}
+	if found := search("content:東京 -ext:md"); len(found) != 1 || found["ja.txt"][0].Before != "昨日、" {
+		t.Errorf("han search = %+v, want ja.txt", found)
+	}
+}
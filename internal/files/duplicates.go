@@ -0,0 +1,189 @@
+package files
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/spf13/afero"
+)
+
+// partialHashSize is the length of the start of the files hashed to tell
+// apart the files of the same size, before hashing the whole of them.
+const partialHashSize = 64 << 10
+
+// Stages of a search for duplicates.
+const (
+	DuplicateStageScan    = "scan"
+	DuplicateStagePartial = "partial"
+	DuplicateStageFull    = "full"
+)
+
+// DuplicateProgress tells how far a search for duplicates is. Total is the
+// number of files of the stage, unknown while scanning.
+type DuplicateProgress struct {
+	Stage string `json:"stage"`
+	Done  int    `json:"done"`
+	Total int    `json:"total,omitempty"`
+}
+
+// DuplicateSet is a set of files with the same content. Wasted is the size
+// taken by the copies beyond the first, not counting the hard links to the
+// same content.
+type DuplicateSet struct {
+	Hash   string   `json:"hash"`
+	Size   int64    `json:"size"`
+	Paths  []string `json:"paths"`
+	Wasted int64    `json:"wasted"`
+}
+
+type duplicateFile struct {
+	path string
+	info os.FileInfo
+	hash string
+}
+
+// HashFile returns the SHA-256 of the content of the file name, or of its
+// first limit bytes when limit is positive.
+func HashFile(afs afero.Fs, name string, limit int64) (string, error) {
+	f, err := afs.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if limit > 0 {
+		r = io.LimitReader(f, limit)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FindDuplicates finds the files of scope in a fs with the same content,
+// until ctx is done. Only the regular files of minSize bytes or more are
+// compared, and empty files never are. The files are grouped by size, then
+// by the hash of their start, then by the hash of their content, so only
+// the files which may be duplicates are read. progress is called as the
+// files are scanned and hashed. The sets wasting the most come first.
+func FindDuplicates(ctx context.Context, afs afero.Fs, scope string, minSize int64, checker Checker, progress func(DuplicateProgress)) ([]*DuplicateSet, error) {
+	minSize = max(minSize, 1)
+
+	bySize := map[int64][]*duplicateFile{}
+	scanned := 0
+	err := walkFs(ctx, afs, scope, "", checker, nil, func(c *candidate) error {
+		if !c.info.Mode().IsRegular() || c.info.Size() < minSize {
+			return nil
+		}
+		bySize[c.info.Size()] = append(bySize[c.info.Size()], &duplicateFile{path: c.path, info: c.info})
+
+		scanned++
+		progress(DuplicateProgress{Stage: DuplicateStageScan, Done: scanned})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([][]*duplicateFile, 0, len(bySize))
+	for _, group := range bySize {
+		if len(group) > 1 {
+			groups = append(groups, group)
+		}
+	}
+
+	// The files smaller than partialHashSize are hashed whole at once.
+	groups, err = hashDuplicates(ctx, afs, groups, DuplicateStagePartial, partialHashSize, progress)
+	if err != nil {
+		return nil, err
+	}
+	var partial, whole [][]*duplicateFile
+	for _, group := range groups {
+		if group[0].info.Size() > partialHashSize {
+			partial = append(partial, group)
+		} else {
+			whole = append(whole, group)
+		}
+	}
+	partial, err = hashDuplicates(ctx, afs, partial, DuplicateStageFull, 0, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	sets := []*DuplicateSet{}
+	for _, group := range append(whole, partial...) {
+		if set := newDuplicateSet(group); set.Wasted > 0 {
+			sets = append(sets, set)
+		}
+	}
+	slices.SortFunc(sets, func(a, b *DuplicateSet) int {
+		if c := cmp.Compare(b.Wasted, a.Wasted); c != 0 {
+			return c
+		}
+		return ComparePaths(a.Paths[0], b.Paths[0])
+	})
+	return sets, nil
+}
+
+// hashDuplicates hashes the files of groups, up to limit bytes, and splits
+// the groups by hash. The files which can't be read are left out.
+func hashDuplicates(ctx context.Context, afs afero.Fs, groups [][]*duplicateFile, stage string, limit int64, progress func(DuplicateProgress)) ([][]*duplicateFile, error) {
+	p := DuplicateProgress{Stage: stage}
+	for _, group := range groups {
+		p.Total += len(group)
+	}
+	progress(p)
+
+	var res [][]*duplicateFile
+	for _, group := range groups {
+		byHash := map[string][]*duplicateFile{}
+		var hashes []string
+		for _, f := range group {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			hash, err := HashFile(afs, f.path, limit)
+			p.Done++
+			progress(p)
+			if err != nil {
+				continue
+			}
+
+			f.hash = hash
+			if _, ok := byHash[hash]; !ok {
+				hashes = append(hashes, hash)
+			}
+			byHash[hash] = append(byHash[hash], f)
+		}
+
+		for _, hash := range hashes {
+			if len(byHash[hash]) > 1 {
+				res = append(res, byHash[hash])
+			}
+		}
+	}
+	return res, nil
+}
+
+func newDuplicateSet(group []*duplicateFile) *DuplicateSet {
+	slices.SortFunc(group, func(a, b *duplicateFile) int { return ComparePaths(a.path, b.path) })
+
+	set := &DuplicateSet{Hash: group[0].hash, Size: group[0].info.Size(), Paths: []string{}}
+	for i, f := range group {
+		set.Paths = append(set.Paths, f.path)
+		linked := slices.ContainsFunc(group[:i], func(o *duplicateFile) bool { return os.SameFile(o.info, f.info) })
+		if i > 0 && !linked {
+			set.Wasted += set.Size
+		}
+	}
+	return set
+}
@@ -0,0 +1,66 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	root := t.TempDir()
+	big := bytes.Repeat([]byte("a"), partialHashSize+10)
+	// Same size and start as big, but another content.
+	other := append(bytes.Repeat([]byte("a"), partialHashSize), bytes.Repeat([]byte("b"), 10)...)
+	for name, content := range map[string][]byte{
+		"big.bin":      big,
+		"copy/big.bin": big,
+		"other.bin":    other,
+		"a.txt":        []byte("same"),
+		"b.txt":        []byte("same"),
+		"c.txt":        []byte("diff"),
+		"empty1":       nil,
+		"empty2":       nil,
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Hard links don't waste space.
+	if err := os.Link(filepath.Join(root, "a.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	var stages []string
+	afs := afero.NewBasePathFs(afero.NewOsFs(), root)
+	sets, err := FindDuplicates(context.Background(), afs, "/", 0, allowAll{}, func(p DuplicateProgress) {
+		if !slices.Contains(stages, p.Stage) {
+			stages = append(stages, p.Stage)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sets) != 2 {
+		t.Fatalf("found %d sets, want 2: %+v", len(sets), sets)
+	}
+	if want := []string{"/big.bin", "/copy/big.bin"}; !slices.Equal(sets[0].Paths, want) || sets[0].Wasted != int64(len(big)) {
+		t.Errorf("first set = %+v, want %v wasting %d", sets[0], want, len(big))
+	}
+	if want := []string{"/a.txt", "/b.txt", "/link.txt"}; !slices.Equal(sets[1].Paths, want) || sets[1].Wasted != 4 {
+		t.Errorf("second set = %+v, want %v wasting 4", sets[1], want)
+	}
+
+	if want := []string{DuplicateStageScan, DuplicateStagePartial, DuplicateStageFull}; !slices.Equal(stages, want) {
+		t.Errorf("stages = %v, want %v", stages, want)
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/spf13/afero"
 
+	"github.com/nulnl/nulyun/internal/media"
 	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
 )
 
@@ -31,6 +32,10 @@ type Checker interface {
 	Check(path string) bool
 }
 
+// TagsFunc returns the tags of the user on a file, for the tag: field of
+// the searches.
+type TagsFunc func(path string) []string
+
 var (
 	reSubDirs = regexp.MustCompile("(?i)^sub(s|titles)$")
 	reSubExts = regexp.MustCompile("(?i)(.vtt|.srt|.ass|.ssa)$")
@@ -55,6 +60,10 @@ type FileInfo struct {
 	Token      string            `json:"token,omitempty"`
 	currentDir []os.FileInfo     `json:"-"`
 	Resolution *ImageResolution  `json:"resolution,omitempty"`
+	Metadata   *media.Metadata   `json:"metadata,omitempty"`
+	Tags       []string          `json:"tags,omitempty"`
+	Favorite   bool              `json:"favorite,omitempty"`
+	Comments   int               `json:"comments,omitempty"`
 }
 
 // FileOptions are the options when getting a file info.
@@ -104,6 +113,13 @@ func NewFileInfo(opts *FileOptions) (*FileInfo, error) {
 		if err != nil {
 			return nil, err
 		}
+
+		// The metadata is only read for a single file, not for listings.
+		if file.Type == "image" {
+			file.Metadata = readMetadata(file.Path, func() (io.ReadSeekCloser, error) {
+				return file.Fs.Open(file.Path)
+			})
+		}
 	}
 
 	return file, err
@@ -287,6 +303,27 @@ func (i *FileInfo) detectType(modify, saveContent, readHeader bool) error {
 	return nil
 }
 
+// TypeByExtension returns the type of a file from its extension only, as
+// detectType does without reading its header: video, audio, image, pdf,
+// text or blob.
+func TypeByExtension(name string) string {
+	mimetype := mime.TypeByExtension(filepath.Ext(name))
+	switch {
+	case strings.HasPrefix(mimetype, "video"):
+		return "video"
+	case strings.HasPrefix(mimetype, "audio"):
+		return "audio"
+	case strings.HasPrefix(mimetype, "image"):
+		return "image"
+	case strings.HasSuffix(mimetype, "pdf"):
+		return "pdf"
+	case strings.HasPrefix(mimetype, "text"):
+		return "text"
+	default:
+		return "blob"
+	}
+}
+
 func calculateImageResolution(fSys afero.Fs, filePath string) (*ImageResolution, error) {
 	file, err := fSys.Open(filePath)
 	if err != nil {
@@ -1,11 +1,14 @@
 package files
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/afero"
 )
@@ -29,6 +32,41 @@ func MoveFile(afs afero.Fs, src, dst string, fileMode, dirMode fs.FileMode) erro
 	return nil
 }
 
+// ErrLinkNotSupported is returned when a fs can't make hard links.
+var ErrLinkNotSupported = errors.New("hard links are not supported")
+
+// LinkFile replaces dst with a hard link to src, which must be on the same
+// volume. dst is replaced at once, so it never goes missing.
+func LinkFile(afs afero.Fs, src, dst string) error {
+	realPath := func(name string) (string, error) { return name, nil }
+	switch v := afs.(type) {
+	case *afero.BasePathFs:
+		realPath = v.RealPath
+	case *afero.OsFs:
+	default:
+		return ErrLinkNotSupported
+	}
+
+	src, err := realPath(src)
+	if err != nil {
+		return err
+	}
+	dst, err = realPath(dst)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(dst), fmt.Sprintf(".%s.%d.link", filepath.Base(dst), time.Now().UnixNano()))
+	if err := os.Link(src, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
 // CopyFile copies a file from source to dest and returns
 // an error if any.
 func CopyFile(afs afero.Fs, source, dest string, fileMode, dirMode fs.FileMode) error {
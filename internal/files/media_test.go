@@ -0,0 +1,82 @@
+package files
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestMediaSearch(t *testing.T) {
+	fs := afero.NewReadOnlyFs(afero.NewBasePathFs(afero.NewOsFs(), "testdata"))
+
+	file, err := NewFileInfo(&FileOptions{Fs: fs, Path: "/IMG_2578.JPG", Expand: true, Checker: allowAll{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := file.Metadata
+	if m == nil || m.Make != "Apple" || m.Model != "iPhone 6 Plus" || m.Taken.Year() != 2015 || m.Width != 2448 || m.Location == nil {
+		t.Fatalf("Metadata = %+v", m)
+	}
+
+	testCases := map[string]struct {
+		query string
+		want  []string
+	}{
+		"camera":        {`camera:"iphone 6"`, []string{"IMG_2578.JPG"}},
+		"camera make":   {"camera:samsung", []string{"20130612_142406.jpg"}},
+		"lens":          {"lens:4.15mm", []string{"IMG_2578.JPG"}},
+		"taken month":   {"taken:2015-10", []string{"IMG_2578.JPG"}},
+		"taken year":    {"taken:2013", []string{"20130612_142406.jpg"}},
+		"taken before":  {"taken:<2010-01-01", []string{"gray-sample.jpg"}},
+		"taken since":   {"taken:>=2013-06-12", []string{"20130612_142406.jpg", "IMG_2578.JPG"}},
+		"near":          {"near:13.7563,100.5018,5km", []string{"IMG_2578.JPG"}},
+		"not near":      {"near:48.8566,2.3522,100", nil},
+		"near in miles": {"near:13.7,100.5,4mi", []string{"IMG_2578.JPG"}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			err := Search(context.Background(), fs, "/", tc.query, "", allowAll{}, nil, func(path string, _ os.FileInfo, _ []Snippet) error {
+				got = append(got, path)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Search(%q) error = %v", tc.query, err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("Search(%q) = %q, want %q", tc.query, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	fs := afero.NewReadOnlyFs(afero.NewBasePathFs(afero.NewOsFs(), "testdata"))
+
+	photos, err := Photos(context.Background(), fs, "/", allowAll{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range photos {
+		if !p.Taken {
+			t.Errorf("%s is dated by its modification time", p.Path)
+		}
+		got = append(got, p.Path)
+	}
+	if want := []string{"/IMG_2578.JPG", "/20130612_142406.jpg", "/gray-sample.jpg"}; !slices.Equal(got, want) {
+		t.Fatalf("Photos() = %q, want %q", got, want)
+	}
+
+	timeline := NewTimeline(photos, 2013, 6)
+	if len(timeline.Years) != 3 || timeline.Years[0].Year != 2015 || timeline.Years[0].Months[0].Month != 10 || timeline.Years[0].Count != 1 {
+		t.Errorf("Years = %+v", timeline.Years)
+	}
+	if len(timeline.Photos) != 1 || timeline.Photos[0].Path != "/20130612_142406.jpg" {
+		t.Errorf("Photos = %+v", timeline.Photos)
+	}
+}
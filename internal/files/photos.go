@@ -0,0 +1,161 @@
+package files
+
+import (
+	"context"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/nulnl/nulyun/internal/index"
+	"github.com/nulnl/nulyun/internal/media"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+// Photo is an image dated by the EXIF date it was taken, or else by its
+// modification time.
+type Photo struct {
+	Path string    `json:"path"`
+	Date time.Time `json:"date"`
+	// Taken is set when Date is the date the photo was taken.
+	Taken    bool            `json:"taken"`
+	Size     int64           `json:"size"`
+	Metadata *media.Metadata `json:"metadata,omitempty"`
+}
+
+// newPhoto returns the photo of c, nil if it isn't an image.
+func newPhoto(c *candidate) *Photo {
+	if c.info.IsDir() || TypeByExtension(c.path) != "image" {
+		return nil
+	}
+
+	p := &Photo{
+		Path:     c.path,
+		Date:     c.info.ModTime(),
+		Size:     c.info.Size(),
+		Metadata: c.metadata(),
+	}
+	if p.Metadata != nil && !p.Metadata.Taken.IsZero() {
+		p.Date, p.Taken = p.Metadata.Taken, true
+	}
+	return p
+}
+
+// ReadPhoto returns the photo name of a fs, with its metadata.
+// fberrors.ErrNotExist is returned when it isn't an image.
+func ReadPhoto(afs afero.Fs, name string) (*Photo, error) {
+	name = path.Join("/", name)
+	info, err := afs.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newPhoto(&candidate{
+		path: name,
+		info: info,
+		readMedia: func() *media.Metadata {
+			return readMetadata(name, func() (io.ReadSeekCloser, error) { return afs.Open(name) })
+		},
+	})
+	if p == nil {
+		return nil, fberrors.ErrNotExist
+	}
+	return p, nil
+}
+
+// Photos returns the photos of scope in a fs, newest first.
+func Photos(ctx context.Context, afs afero.Fs, scope string, checker Checker) ([]*Photo, error) {
+	var photos []*Photo
+	err := walkFs(ctx, afs, scope, "", checker, nil, func(c *candidate) error {
+		if p := newPhoto(c); p != nil {
+			photos = append(photos, p)
+		}
+		return nil
+	})
+	sortPhotos(photos)
+	return photos, err
+}
+
+// PhotosIndex returns the photos of scope in ix, like Photos does on the
+// disk. base is the scope of the user, relative to the root of the index.
+func PhotosIndex(ctx context.Context, ix *index.Index, base, scope string, checker Checker) ([]*Photo, error) {
+	var photos []*Photo
+	err := walkIndex(ctx, ix, base, scope, "", checker, nil, func(c *candidate) error {
+		if p := newPhoto(c); p != nil {
+			photos = append(photos, p)
+		}
+		return nil
+	})
+	sortPhotos(photos)
+	return photos, err
+}
+
+func sortPhotos(photos []*Photo) {
+	slices.SortStableFunc(photos, func(a, b *Photo) int {
+		if c := b.Date.Compare(a.Date); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Path, b.Path)
+	})
+}
+
+// MonthBucket counts the photos of a month.
+type MonthBucket struct {
+	Month int `json:"month"`
+	Count int `json:"count"`
+}
+
+// YearBucket counts the photos of a year, and of each of its months.
+type YearBucket struct {
+	Year   int            `json:"year"`
+	Count  int            `json:"count"`
+	Months []*MonthBucket `json:"months"`
+}
+
+// Timeline is the photos grouped by year and month, newest first.
+type Timeline struct {
+	Years  []*YearBucket `json:"years"`
+	Photos []*Photo      `json:"photos"`
+}
+
+// NewTimeline groups photos by the year and the month of their date, in
+// the time zone they were taken in. Only the photos of year and month are
+// kept, when they're set, but all of them are counted.
+func NewTimeline(photos []*Photo, year, month int) *Timeline {
+	t := &Timeline{Years: []*YearBucket{}, Photos: []*Photo{}}
+
+	years := map[int]*YearBucket{}
+	months := map[[2]int]*MonthBucket{}
+	for _, p := range photos {
+		y, m, _ := p.Date.Date()
+		yb, ok := years[y]
+		if !ok {
+			yb = &YearBucket{Year: y}
+			years[y] = yb
+			t.Years = append(t.Years, yb)
+		}
+		mb, ok := months[[2]int{y, int(m)}]
+		if !ok {
+			mb = &MonthBucket{Month: int(m)}
+			months[[2]int{y, int(m)}] = mb
+			yb.Months = append(yb.Months, mb)
+		}
+		yb.Count++
+		mb.Count++
+
+		if (year == 0 || y == year) && (month == 0 || int(m) == month) {
+			t.Photos = append(t.Photos, p)
+		}
+	}
+
+	// The dates in different time zones may not be in the order of their
+	// buckets.
+	slices.SortFunc(t.Years, func(a, b *YearBucket) int { return b.Year - a.Year })
+	for _, yb := range t.Years {
+		slices.SortFunc(yb.Months, func(a, b *MonthBucket) int { return b.Month - a.Month })
+	}
+	return t
+}
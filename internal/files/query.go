@@ -0,0 +1,327 @@
+package files
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// QueryError is a syntax error in a search query.
+type QueryError struct {
+	// Pos is the position of the error in the query, in characters from 0.
+	Pos int
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query at position %d: %s", e.Pos, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenField
+	tokenOpen
+	tokenClose
+	tokenAnd
+	tokenOr
+	tokenNot
+)
+
+// token is a part of a query. A field has a name and a value, quoted or
+// regular expressions included.
+type token struct {
+	kind   tokenKind
+	pos    int
+	text   string
+	field  string
+	quoted bool
+}
+
+func (t *token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of query"
+	case tokenOpen:
+		return `"("`
+	case tokenClose:
+		return `")"`
+	case tokenField:
+		return fmt.Sprintf("%q", t.field+":"+t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// lexer splits a query into tokens. Positions are counted in characters.
+type lexer struct {
+	input  string
+	offset int // in bytes
+}
+
+func (l *lexer) pos() int {
+	return utf8.RuneCountInString(l.input[:l.offset])
+}
+
+func (l *lexer) peek() rune {
+	r, _ := utf8.DecodeRuneInString(l.input[l.offset:])
+	return r
+}
+
+func (l *lexer) done() bool {
+	return l.offset >= len(l.input)
+}
+
+func (l *lexer) next() (*token, error) {
+	for !l.done() && unicode.IsSpace(l.peek()) {
+		l.offset += utf8.RuneLen(l.peek())
+	}
+	if l.done() {
+		return &token{kind: tokenEOF, pos: l.pos()}, nil
+	}
+
+	pos := l.pos()
+	switch l.peek() {
+	case '(':
+		l.offset++
+		return &token{kind: tokenOpen, pos: pos, text: "("}, nil
+	case ')':
+		l.offset++
+		return &token{kind: tokenClose, pos: pos, text: ")"}, nil
+	case '-':
+		l.offset++
+		if l.done() || unicode.IsSpace(l.peek()) {
+			return nil, &QueryError{Pos: pos, Msg: `expected a term after "-"`}
+		}
+		return &token{kind: tokenNot, pos: pos, text: "-"}, nil
+	case '"':
+		text, err := l.delimited('"')
+		if err != nil {
+			return nil, err
+		}
+		return &token{kind: tokenWord, pos: pos, text: text, quoted: true}, nil
+	}
+
+	word := l.word()
+	if name, _, ok := strings.Cut(word, ":"); ok && isFieldName(name) {
+		// The value starts after the colon, and can be quoted or a
+		// regular expression containing spaces.
+		l.offset -= len(word) - len(name) - 1
+		t := &token{kind: tokenField, pos: pos, field: strings.ToLower(name)}
+		switch {
+		case !l.done() && l.peek() == '"':
+			text, err := l.delimited('"')
+			if err != nil {
+				return nil, err
+			}
+			t.text, t.quoted = text, true
+		case !l.done() && l.peek() == '/':
+			text, err := l.delimited('/')
+			if err != nil {
+				return nil, err
+			}
+			t.text = "/" + text + "/"
+		default:
+			t.text = l.word()
+		}
+		return t, nil
+	}
+
+	switch word {
+	case "AND":
+		return &token{kind: tokenAnd, pos: pos, text: word}, nil
+	case "OR":
+		return &token{kind: tokenOr, pos: pos, text: word}, nil
+	case "NOT":
+		return &token{kind: tokenNot, pos: pos, text: word}, nil
+	}
+	return &token{kind: tokenWord, pos: pos, text: word}, nil
+}
+
+// word reads until a space or a parenthesis.
+func (l *lexer) word() string {
+	start := l.offset
+	for !l.done() {
+		r, size := utf8.DecodeRuneInString(l.input[l.offset:])
+		if unicode.IsSpace(r) || r == '(' || r == ')' {
+			break
+		}
+		l.offset += size
+	}
+	return l.input[start:l.offset]
+}
+
+// delimited reads a value between two delim, in which a backslash escapes
+// the delimiter. Other escapes are kept, for the regular expressions.
+func (l *lexer) delimited(delim rune) (string, error) {
+	pos := l.pos()
+	l.offset++
+
+	var b strings.Builder
+	for !l.done() {
+		r, size := utf8.DecodeRuneInString(l.input[l.offset:])
+		l.offset += size
+		switch {
+		case r == delim:
+			return b.String(), nil
+		case r == '\\' && !l.done() && l.peek() == delim:
+			b.WriteRune(delim)
+			l.offset++
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return "", &QueryError{Pos: pos, Msg: fmt.Sprintf("unterminated %c", delim)}
+}
+
+func isFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// parser builds the tree of a query:
+//
+//	or      = and { "OR" and }
+//	and     = unary { [ "AND" ] unary }
+//	unary   = ( "-" | "NOT" ) unary | primary
+//	primary = "(" or ")" | field | term
+type parser struct {
+	lex  *lexer
+	tok  *token
+	opts *searchOptions
+}
+
+func (p *parser) advance() error {
+	t, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = t
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	return &QueryError{Pos: p.tok.pos, Msg: "unexpected " + p.tok.String()}
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.tok.kind == tokenOr {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch p.tok.kind {
+		case tokenAnd:
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		case tokenWord, tokenField, tokenOpen, tokenNot:
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.tok.kind != tokenNot {
+		return p.parsePrimary()
+	}
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	n, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	return notNode{n}, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.tok
+	switch t.kind {
+	case tokenOpen:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokenClose {
+			return nil, &QueryError{Pos: t.pos, Msg: "empty parentheses"}
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != tokenClose {
+			return nil, &QueryError{Pos: t.pos, Msg: "unclosed parenthesis"}
+		}
+		return n, p.advance()
+	case tokenWord:
+		return termNode{term: t.text, caseSensitive: p.opts.CaseSensitive}, p.advance()
+	case tokenField:
+		n, err := parseField(t, p.opts)
+		if err != nil {
+			return nil, err
+		}
+		return n, p.advance()
+	default:
+		return nil, p.unexpected()
+	}
+}
+
+// parseQuery parses a query into its tree, nil when the query has no
+// conditions.
+func parseQuery(query string, opts *searchOptions) (node, error) {
+	p := &parser{lex: &lexer{input: query}, opts: opts}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenEOF {
+		return nil, nil
+	}
+
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokenEOF {
+		return nil, p.unexpected()
+	}
+	return n, nil
+}
@@ -0,0 +1,127 @@
+package files
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"slices"
+	"testing"
+	"time"
+)
+
+type testFileInfo struct {
+	name  string
+	size  int64
+	mod   time.Time
+	isDir bool
+}
+
+func (f testFileInfo) Name() string       { return f.name }
+func (f testFileInfo) Size() int64        { return f.size }
+func (f testFileInfo) Mode() fs.FileMode  { return 0o644 }
+func (f testFileInfo) ModTime() time.Time { return f.mod }
+func (f testFileInfo) IsDir() bool        { return f.isDir }
+func (f testFileInfo) Sys() any           { return nil }
+
+func TestParseSearch(t *testing.T) {
+	now := time.Now()
+	day := 24 * time.Hour
+	tree := []struct {
+		path  string
+		size  int64
+		age   time.Duration
+		isDir bool
+	}{
+		{"/docs", 0, day, true},
+		{"/docs/Report.PDF", 2 << 20, 2 * day, false},
+		{"/docs/notes.txt", 100, 10 * day, false},
+		{"/docs/2024/draft report.txt", 5 << 10, 400 * day, false},
+		{"/photos", 0, day, true},
+		{"/photos/cat.jpg", 300 << 20, 3 * day, false},
+		{"/photos/song.mp3", 4 << 20, 30 * day, false},
+	}
+
+	testCases := map[string]struct {
+		query string
+		want  []string
+	}{
+		"everything":           {"", []string{"/docs", "/docs/Report.PDF", "/docs/notes.txt", "/docs/2024/draft report.txt", "/photos", "/photos/cat.jpg", "/photos/song.mp3"}},
+		"term":                 {"report", []string{"/docs/Report.PDF", "/docs/2024/draft report.txt"}},
+		"case sensitive":       {"report case:sensitive", []string{"/docs/2024/draft report.txt"}},
+		"terms are and'ed":     {"draft report", []string{"/docs/2024/draft report.txt"}},
+		"or":                   {"cat OR song", []string{"/photos/cat.jpg", "/photos/song.mp3"}},
+		"quoted":               {`"t report"`, []string{"/docs/2024/draft report.txt"}},
+		"negation":             {"report -draft", []string{"/docs/Report.PDF"}},
+		"not":                  {"type:txt NOT notes", []string{"/docs/2024/draft report.txt"}},
+		"parentheses":          {"(type:image OR type:audio) -song", []string{"/photos/cat.jpg"}},
+		"explicit and":         {"type:txt AND notes", []string{"/docs/notes.txt"}},
+		"ext":                  {"ext:pdf", []string{"/docs/Report.PDF"}},
+		"size greater":         {"size:>1M", []string{"/docs/Report.PDF", "/photos/cat.jpg", "/photos/song.mp3"}},
+		"size range":           {"size:>=2MiB size:<100M", []string{"/docs/Report.PDF", "/photos/song.mp3"}},
+		"size bytes":           {"size:<=100", []string{"/docs/notes.txt"}},
+		"modified recently":    {"modified:7d -dir:", []string{"/docs/Report.PDF", "/photos/cat.jpg"}},
+		"modified older":       {"modified:>1y", []string{"/docs/2024/draft report.txt"}},
+		"modified before date": {"modified:<" + now.AddDate(0, 0, -100).Format(time.DateOnly), []string{"/docs/2024/draft report.txt"}},
+		"dirs":                 {"dir:", []string{"/docs", "/photos"}},
+		"named dirs":           {"dir:pho", []string{"/photos"}},
+		"files":                {"file:t -type:txt", []string{"/docs/Report.PDF", "/photos/cat.jpg"}},
+		"name regex":           {`name:/^(cat|song)\./`, []string{"/photos/cat.jpg", "/photos/song.mp3"}},
+		"name with spaces":     {`name:"draft r"`, []string{"/docs/2024/draft report.txt"}},
+		"path glob":            {"path:docs/*.txt", []string{"/docs/notes.txt"}},
+		"path deep glob":       {"path:**/*.txt", []string{"/docs/notes.txt", "/docs/2024/draft report.txt"}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			search, err := parseSearch(tc.query)
+			if err != nil {
+				t.Fatalf("parseSearch(%q) error = %v", tc.query, err)
+			}
+
+			var got []string
+			for _, f := range tree {
+				info := testFileInfo{name: path.Base(f.path), size: f.size, mod: now.Add(-f.age), isDir: f.isDir}
+				if search.match(&candidate{path: f.path, rel: f.path[1:], info: info}) {
+					got = append(got, f.path)
+				}
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("parseSearch(%q) matches %q, want %q", tc.query, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchErrors(t *testing.T) {
+	testCases := map[string]struct {
+		query string
+		pos   int
+	}{
+		"unclosed parenthesis": {"a (b OR c", 2},
+		"unopened parenthesis": {"a b)", 3},
+		"empty parentheses":    {"a ()", 2},
+		"dangling or":          {"a OR", 4},
+		"dangling negation":    {"a - b", 2},
+		"unterminated quote":   {`a "b c`, 2},
+		"unterminated regex":   {"name:/a(b", 5},
+		"invalid regex":        {"x name:/a(b/", 2},
+		"invalid size":         {"size:>big", 0},
+		"invalid date":         {"modified:2025-13-01", 0},
+		"missing value":        {"type:", 0},
+		"unknown field":        {"été sise:>1M", 4},
+		"invalid position":     {"near:48.8,2.3", 0},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseSearch(tc.query)
+			var queryErr *QueryError
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("parseSearch(%q) error = %v, want a QueryError", tc.query, err)
+			}
+			if queryErr.Pos != tc.pos {
+				t.Errorf("parseSearch(%q) error at %d, want %d: %v", tc.query, queryErr.Pos, tc.pos, err)
+			}
+		})
+	}
+}
@@ -1,70 +1,194 @@
 package files
 
 import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/spf13/afero"
+
+	"github.com/nulnl/nulyun/internal/index"
+	"github.com/nulnl/nulyun/internal/media"
 )
 
-type searchOptions struct {
-	CaseSensitive bool
-	Conditions    []condition
-	Terms         []string
+// FoundFunc is called for each file found by a search, with the snippets of
+// its content matching the query. It can return fs.SkipAll to stop the
+// search.
+type FoundFunc func(path string, f os.FileInfo, snippets []Snippet) error
+
+// ComparePaths compares two relative paths in the order the searches find
+// them: by their elements, so the content of a directory comes right after
+// it.
+func ComparePaths(a, b string) int {
+	for a != "" && b != "" {
+		var ea, eb string
+		ea, a, _ = strings.Cut(a, "/")
+		eb, b, _ = strings.Cut(b, "/")
+		if c := strings.Compare(ea, eb); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// skipBefore checks if the file rel is found before after, or is after, by
+// a search, and returns fs.SkipDir when its content is found before too.
+func skipBefore(rel string, isDir bool, after string) (bool, error) {
+	if after == "" || ComparePaths(rel, after) > 0 {
+		return false, nil
+	}
+	if isDir && rel != after && !strings.HasPrefix(after, rel+"/") {
+		return true, fs.SkipDir
+	}
+	return true, nil
 }
 
-// Search searches for a query in a fs.
-func Search(fs afero.Fs, scope, query string, checker Checker, found func(path string, f os.FileInfo) error) error {
-	search := parseSearch(query)
+// tagsOf returns the function returning the tags of the file name, nil
+// without tags.
+func tagsOf(tags TagsFunc, name string) func() []string {
+	if tags == nil {
+		return nil
+	}
+	return func() []string { return tags(name) }
+}
+
+// Search searches for a query in a fs, until ctx is done. Only the files
+// found after the relative path after, if set, are found, so a search can
+// be resumed. tags, if set, returns the tags of the files for the tag:
+// field. A *QueryError is returned when the query is invalid.
+func Search(ctx context.Context, afs afero.Fs, scope, query, after string, checker Checker, tags TagsFunc, found FoundFunc) error {
+	search, err := parseSearch(query)
+	if err != nil {
+		return err
+	}
+
+	return walkFs(ctx, afs, scope, after, checker, tags, func(c *candidate) error {
+		if !search.match(c) {
+			return nil
+		}
+		return found(c.rel, c.info, c.snippets)
+	})
+}
 
+// walkFs calls fn with the files of scope in a fs, in the order of
+// ComparePaths, after the relative path after, with their tags if tags is
+// set. fn can return fs.SkipAll to stop the walk.
+func walkFs(ctx context.Context, afs afero.Fs, scope, after string, checker Checker, tags TagsFunc, fn func(c *candidate) error) error {
 	scope = filepath.ToSlash(filepath.Clean(scope))
 	scope = path.Join("/", scope)
 
-	return afero.Walk(fs, scope, func(fPath string, f os.FileInfo, _ error) error {
+	err := afero.Walk(afs, scope, func(fPath string, f os.FileInfo, _ error) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
 		fPath = filepath.ToSlash(filepath.Clean(fPath))
 		fPath = path.Join("/", fPath)
 		relativePath := strings.TrimPrefix(fPath, scope)
 		relativePath = strings.TrimPrefix(relativePath, "/")
 
-		if fPath == scope {
+		if fPath == scope || f == nil {
 			return nil
 		}
 
+		if skip, err := skipBefore(relativePath, f.IsDir(), after); skip {
+			return err
+		}
+
 		if !checker.Check(fPath) {
 			return nil
 		}
 
-		if len(search.Conditions) > 0 {
-			match := false
-
-			for _, t := range search.Conditions {
-				if t(fPath) {
-					match = true
-					break
+		return fn(&candidate{
+			path: fPath,
+			rel:  relativePath,
+			info: f,
+			tags: tagsOf(tags, fPath),
+			open: func() (io.ReadCloser, error) { return afs.Open(fPath) },
+			readMedia: func() *media.Metadata {
+				if f.IsDir() {
+					return nil
 				}
-			}
+				return readMetadata(fPath, func() (io.ReadSeekCloser, error) { return afs.Open(fPath) })
+			},
+		})
+	})
+	if errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// SearchIndex searches for a query in the files of ix, like Search does on
+// the disk. base is the scope of the user, relative to the root of the
+// index, and scope the searched directory, relative to base.
+func SearchIndex(ctx context.Context, ix *index.Index, base, scope, query, after string, checker Checker, tags TagsFunc, found FoundFunc) error {
+	search, err := parseSearch(query)
+	if err != nil {
+		return err
+	}
 
-			if !match {
-				return nil
+	// Once the content is indexed, only the files which may contain the
+	// phrases are read.
+	if ix.ContentReady() {
+		for _, n := range search.contents {
+			if n.indexed, err = ix.ContentCandidates(n.phrase); err != nil {
+				return err
 			}
 		}
+	}
 
-		if len(search.Terms) > 0 {
-			for _, term := range search.Terms {
-				_, fileName := path.Split(fPath)
-				if !search.CaseSensitive {
-					fileName = strings.ToLower(fileName)
-					term = strings.ToLower(term)
-				}
-				if strings.Contains(fileName, term) {
-					return found(relativePath, f)
-				}
-			}
+	return walkIndex(ctx, ix, base, scope, after, checker, tags, func(c *candidate) error {
+		if !search.match(c) {
 			return nil
 		}
+		return found(c.rel, c.info, c.snippets)
+	})
+}
+
+// walkIndex calls fn with the files of scope in ix, as walkFs does on the
+// disk.
+func walkIndex(ctx context.Context, ix *index.Index, base, scope, after string, checker Checker, tags TagsFunc, fn func(c *candidate) error) error {
+	base = path.Join("/", filepath.ToSlash(base))
+	scope = path.Join("/", filepath.ToSlash(scope))
 
-		return found(relativePath, f)
+	err := ix.Walk(path.Join(base, scope), func(e *index.Entry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		fPath := path.Join("/", strings.TrimPrefix(e.Path, base))
+		relativePath := strings.TrimPrefix(strings.TrimPrefix(fPath, scope), "/")
+		if skip, err := skipBefore(relativePath, e.Dir, after); skip {
+			return err
+		}
+		if !checker.Check(fPath) {
+			return nil
+		}
+
+		return fn(&candidate{
+			path: fPath,
+			rel:  relativePath,
+			info: e.Info(),
+			key:  e.Path,
+			tags: tagsOf(tags, fPath),
+			open: func() (io.ReadCloser, error) { return ix.OpenFile(e.Path) },
+			readMedia: func() *media.Metadata {
+				// The images not read yet by the index are read now.
+				if !e.MediaStale() {
+					return e.Media
+				}
+				return readMetadata(e.Path, func() (io.ReadSeekCloser, error) { return ix.OpenFile(e.Path) })
+			},
+		})
 	})
+	if errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
 }
@@ -1,102 +1,532 @@
 package files
 
 import (
+	"fmt"
+	"io"
+	"math"
 	"mime"
+	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
-)
+	"time"
 
-var (
-	typeRegexp = regexp.MustCompile(`type:(\w+)`)
+	"github.com/nulnl/nulyun/internal/media"
 )
 
-type condition func(path string) bool
+// candidate is a file matched against a search.
+type candidate struct {
+	// path is relative to the scope of the user, rel to the searched
+	// directory.
+	path string
+	rel  string
+	info os.FileInfo
+	// key is the path relative to the root of the index, when searched in
+	// it, and open opens the file to search its content.
+	key  string
+	open func() (io.ReadCloser, error)
+	// snippets are the matches of the content conditions.
+	snippets []Snippet
+	// tags returns the tags of the user on the file, if known.
+	tags func() []string
+	// readMedia reads the metadata of the image, once.
+	readMedia func() *media.Metadata
+	media     *media.Metadata
+	mediaRead bool
+}
+
+// metadata returns the metadata of the image of c, nil if it isn't an image
+// or has none.
+func (c *candidate) metadata() *media.Metadata {
+	if !c.mediaRead && c.readMedia != nil {
+		c.media = c.readMedia()
+	}
+	c.mediaRead = true
+	return c.media
+}
+
+// readMetadata reads the metadata of the image name, opened by open.
+func readMetadata(name string, open func() (io.ReadSeekCloser, error)) *media.Metadata {
+	if !strings.HasPrefix(mime.TypeByExtension(filepath.Ext(name)), "image") {
+		return nil
+	}
+
+	f, err := open()
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	m, _ := media.Read(f)
+	return m
+}
+
+// node is a part of a search query.
+type node interface {
+	match(c *candidate) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) match(c *candidate) bool { return n.left.match(c) && n.right.match(c) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) match(c *candidate) bool { return n.left.match(c) || n.right.match(c) }
+
+type notNode struct{ n node }
+
+func (n notNode) match(c *candidate) bool { return !n.n.match(c) }
+
+// termNode matches the files whose name contains a term.
+type termNode struct {
+	term          string
+	caseSensitive bool
+}
+
+func (n termNode) match(c *candidate) bool {
+	return containsFold(path.Base(c.path), n.term, n.caseSensitive)
+}
+
+func containsFold(s, substr string, caseSensitive bool) bool {
+	if !caseSensitive {
+		s, substr = strings.ToLower(s), strings.ToLower(substr)
+	}
+	return strings.Contains(s, substr)
+}
+
+type condition func(c *candidate) bool
+
+func (cond condition) match(c *candidate) bool { return cond(c) }
+
+func anyCondition(*candidate) bool { return true }
 
 func extensionCondition(extension string) condition {
-	return func(path string) bool {
-		return filepath.Ext(path) == "."+extension
+	return func(c *candidate) bool {
+		return filepath.Ext(c.path) == "."+extension
 	}
 }
 
-func imageCondition(path string) bool {
-	extension := filepath.Ext(path)
+func imageCondition(c *candidate) bool {
+	extension := filepath.Ext(c.path)
 	mimetype := mime.TypeByExtension(extension)
 
 	return strings.HasPrefix(mimetype, "image")
 }
 
-func audioCondition(path string) bool {
-	extension := filepath.Ext(path)
+func audioCondition(c *candidate) bool {
+	extension := filepath.Ext(c.path)
 	mimetype := mime.TypeByExtension(extension)
 
 	return strings.HasPrefix(mimetype, "audio")
 }
 
-func videoCondition(path string) bool {
-	extension := filepath.Ext(path)
+func videoCondition(c *candidate) bool {
+	extension := filepath.Ext(c.path)
 	mimetype := mime.TypeByExtension(extension)
 
 	return strings.HasPrefix(mimetype, "video")
 }
 
-func parseSearch(value string) *searchOptions {
-	opts := &searchOptions{
-		CaseSensitive: strings.Contains(value, "case:sensitive"),
-		Conditions:    []condition{},
-		Terms:         []string{},
+func dirCondition(c *candidate) bool  { return c.info.IsDir() }
+func fileCondition(c *candidate) bool { return !c.info.IsDir() }
+
+// typeCondition returns the condition of a type: a kind of media, or an
+// extension.
+func typeCondition(value string) condition {
+	switch value {
+	case "image":
+		return imageCondition
+	case "audio", "music":
+		return audioCondition
+	case "video":
+		return videoCondition
+	default:
+		return extensionCondition(value)
+	}
+}
+
+// kindCondition matches the directories or the files, whose name contains
+// value if set.
+func kindCondition(kind condition, value string, caseSensitive bool) condition {
+	if value == "" {
+		return kind
+	}
+	return func(c *candidate) bool {
+		return kind(c) && containsFold(path.Base(c.path), value, caseSensitive)
+	}
+}
+
+// nameCondition matches the names containing value, or matching it when
+// it is a regular expression between slashes.
+func nameCondition(t *token, caseSensitive bool) (condition, error) {
+	value := t.text
+	if t.quoted || len(value) < 2 || !strings.HasPrefix(value, "/") || !strings.HasSuffix(value, "/") {
+		term := termNode{term: value, caseSensitive: caseSensitive}
+		return term.match, nil
+	}
+
+	expr := value[1 : len(value)-1]
+	if !caseSensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, &QueryError{Pos: t.pos, Msg: "invalid regular expression: " + err.Error()}
+	}
+
+	return func(c *candidate) bool {
+		return re.MatchString(path.Base(c.path))
+	}, nil
+}
+
+// pathCondition matches the paths relative to the searched directory with
+// a glob pattern, in which * and ? don't match slashes and ** matches
+// anything.
+func pathCondition(t *token, caseSensitive bool) (condition, error) {
+	pattern := strings.TrimPrefix(t.text, "/")
+
+	var b strings.Builder
+	if !caseSensitive {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case pattern[i] == '*':
+			b.WriteString("[^/]*")
+		case pattern[i] == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, &QueryError{Pos: t.pos, Msg: "invalid pattern"}
+	}
+	return func(c *candidate) bool {
+		return re.MatchString(c.rel)
+	}, nil
+}
+
+var (
+	comparisonRegexp = regexp.MustCompile(`^(<=|>=|<|>|=)?(.+)$`)
+	sizeRegexp       = regexp.MustCompile(`(?i)^(\d+(?:\.\d+)?)\s*(b|k|kb|kib|m|mb|mib|g|gb|gib|t|tb|tib)?$`)
+	ageRegexp        = regexp.MustCompile(`^(\d+)(h|d|w|mo|y)$`)
+)
+
+var sizeUnits = map[byte]float64{
+	'b': 1,
+	'k': 1 << 10,
+	'm': 1 << 20,
+	'g': 1 << 30,
+	't': 1 << 40,
+}
+
+var ageUnits = map[string]time.Duration{
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"mo": 30 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// compare checks a value against a bound with a comparison operator.
+func compare[T int64 | time.Duration](op string, v, bound T) bool {
+	switch op {
+	case "<":
+		return v < bound
+	case "<=":
+		return v <= bound
+	case ">":
+		return v > bound
+	case ">=":
+		return v >= bound
+	default:
+		return v == bound
 	}
+}
 
-	// removes the options from the value
-	value = strings.ReplaceAll(value, "case:insensitive", "")
-	value = strings.ReplaceAll(value, "case:sensitive", "")
-	value = strings.TrimSpace(value)
+// sizeCondition compares the sizes of the files with a size such as
+// >100M, in units of 1024 bytes.
+func sizeCondition(t *token) (condition, error) {
+	m := comparisonRegexp.FindStringSubmatch(t.text)
+	s := sizeRegexp.FindStringSubmatch(m[2])
+	if s == nil {
+		return nil, &QueryError{Pos: t.pos, Msg: fmt.Sprintf("invalid size %q", t.text)}
+	}
 
-	types := typeRegexp.FindAllStringSubmatch(value, -1)
-	for _, t := range types {
-		if len(t) == 1 {
-			continue
+	n, _ := strconv.ParseFloat(s[1], 64)
+	unit := byte('b')
+	if s[2] != "" {
+		unit = strings.ToLower(s[2])[0]
+	}
+	op, size := m[1], int64(n*sizeUnits[unit])
+
+	return func(c *candidate) bool {
+		return !c.info.IsDir() && compare(op, c.info.Size(), size)
+	}, nil
+}
+
+// periodLayouts are the layouts of the periods of the dates, and the
+// length of the periods in years, months and days.
+var periodLayouts = []struct {
+	layout              string
+	years, months, days int
+}{
+	{time.DateOnly, 0, 0, 1},
+	{"2006-01", 0, 1, 0},
+	{"2006", 1, 0, 0},
+}
+
+// parsePeriod parses a day, a month or a year into its start and end.
+func parsePeriod(value string) (start, end time.Time, ok bool) {
+	for _, p := range periodLayouts {
+		if t, err := time.ParseInLocation(p.layout, value, time.Local); err == nil {
+			return t, t.AddDate(p.years, p.months, p.days), true
 		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// timeCondition compares the times returned by get with a period such as
+// 2024-06, or their age with a duration such as 7d. A period alone matches
+// the times within it and a duration alone the ones younger than it. The
+// files without a time don't match.
+func timeCondition(t *token, now time.Time, get func(c *candidate) (time.Time, bool)) (condition, error) {
+	m := comparisonRegexp.FindStringSubmatch(t.text)
+	op, value := m[1], m[2]
+
+	if a := ageRegexp.FindStringSubmatch(value); a != nil {
+		n, _ := strconv.Atoi(a[1])
+		age := time.Duration(n) * ageUnits[a[2]]
+		if op == "" || op == "=" {
+			op = "<"
+		}
+		return func(c *candidate) bool {
+			v, ok := get(c)
+			return ok && compare(op, now.Sub(v), age)
+		}, nil
+	}
+
+	startTime, endTime, ok := parsePeriod(value)
+	if !ok {
+		return nil, &QueryError{Pos: t.pos, Msg: fmt.Sprintf("invalid date %q, expected YYYY-MM-DD, YYYY-MM, YYYY or a duration such as 7d", t.text)}
+	}
+	start, end := startTime.Unix(), endTime.Unix()
 
-		switch t[1] {
-		case "image":
-			opts.Conditions = append(opts.Conditions, imageCondition)
-		case "audio", "music":
-			opts.Conditions = append(opts.Conditions, audioCondition)
-		case "video":
-			opts.Conditions = append(opts.Conditions, videoCondition)
+	return func(c *candidate) bool {
+		v, ok := get(c)
+		if !ok {
+			return false
+		}
+		sec := v.Unix()
+		switch op {
+		case "<":
+			return sec < start
+		case "<=":
+			return sec < end
+		case ">":
+			return sec >= end
+		case ">=":
+			return sec >= start
 		default:
-			opts.Conditions = append(opts.Conditions, extensionCondition(t[1]))
+			return sec >= start && sec < end
 		}
+	}, nil
+}
+
+// modifiedCondition compares the modification times of the files.
+func modifiedCondition(t *token, now time.Time) (condition, error) {
+	return timeCondition(t, now, func(c *candidate) (time.Time, bool) {
+		return c.info.ModTime(), true
+	})
+}
+
+// takenCondition compares the dates the photos were taken.
+func takenCondition(t *token, now time.Time) (condition, error) {
+	return timeCondition(t, now, func(c *candidate) (time.Time, bool) {
+		m := c.metadata()
+		if m == nil || m.Taken.IsZero() {
+			return time.Time{}, false
+		}
+		return m.Taken, true
+	})
+}
+
+// metadataCondition matches the photos whose metadata returned by get
+// contains value.
+func metadataCondition(value string, caseSensitive bool, get func(m *media.Metadata) string) condition {
+	return func(c *candidate) bool {
+		m := c.metadata()
+		return m != nil && containsFold(get(m), value, caseSensitive)
 	}
+}
+
+var distanceUnits = map[string]float64{
+	"":   1000,
+	"km": 1000,
+	"m":  1,
+	"mi": 1609.344,
+}
 
-	if len(types) > 0 {
-		// Remove the fields from the search value.
-		value = typeRegexp.ReplaceAllString(value, "")
+var radiusRegexp = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(km|m|mi)?$`)
+
+// nearCondition matches the photos taken within a radius of a position,
+// given as lat,lon,radius, the radius in kilometers by default.
+func nearCondition(t *token) (condition, error) {
+	invalid := &QueryError{Pos: t.pos, Msg: fmt.Sprintf("invalid position %q, expected latitude,longitude,radius such as 48.85,2.35,5km", t.text)}
+
+	parts := strings.Split(t.text, ",")
+	if len(parts) != 3 {
+		return nil, invalid
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lon, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	r := radiusRegexp.FindStringSubmatch(strings.ToLower(strings.TrimSpace(parts[2])))
+	if err1 != nil || err2 != nil || r == nil || math.Abs(lat) > 90 || math.Abs(lon) > 180 {
+		return nil, invalid
 	}
+	n, _ := strconv.ParseFloat(r[1], 64)
+	radius := n * distanceUnits[r[2]]
+	center := &media.Location{Latitude: lat, Longitude: lon}
 
-	// If it's case insensitive, put everything in lowercase.
-	if !opts.CaseSensitive {
-		value = strings.ToLower(value)
+	return func(c *candidate) bool {
+		m := c.metadata()
+		return m != nil && m.Location != nil && m.Location.Distance(center) <= radius
+	}, nil
+}
+
+// tagCondition matches the files the user tagged with tag, in any case.
+func tagCondition(tag string) condition {
+	tag = strings.Join(strings.Fields(tag), " ")
+	return func(c *candidate) bool {
+		if c.tags == nil {
+			return false
+		}
+		return slices.ContainsFunc(c.tags(), func(t string) bool { return strings.EqualFold(t, tag) })
 	}
+}
 
-	// Remove the spaces from the search value.
-	value = strings.TrimSpace(value)
+// parseField returns the condition of a field of the query.
+func parseField(t *token, opts *searchOptions) (node, error) {
+	switch t.field {
+	case "case", "dir", "file":
+	default:
+		if t.text == "" {
+			return nil, &QueryError{Pos: t.pos, Msg: fmt.Sprintf("missing value of %s:", t.field)}
+		}
+	}
 
-	if value == "" {
-		return opts
+	switch t.field {
+	case "case":
+		// Read beforehand by caseSensitive.
+		return condition(anyCondition), nil
+	case "type":
+		return typeCondition(t.text), nil
+	case "ext":
+		ext := "." + strings.TrimPrefix(t.text, ".")
+		return condition(func(c *candidate) bool {
+			return strings.EqualFold(filepath.Ext(c.path), ext)
+		}), nil
+	case "dir":
+		return kindCondition(dirCondition, t.text, opts.CaseSensitive), nil
+	case "file":
+		return kindCondition(fileCondition, t.text, opts.CaseSensitive), nil
+	case "name":
+		return wrap(nameCondition(t, opts.CaseSensitive))
+	case "path":
+		return wrap(pathCondition(t, opts.CaseSensitive))
+	case "size":
+		return wrap(sizeCondition(t))
+	case "modified":
+		return wrap(modifiedCondition(t, opts.now))
+	case "taken":
+		return wrap(takenCondition(t, opts.now))
+	case "camera":
+		return metadataCondition(t.text, opts.CaseSensitive, func(m *media.Metadata) string {
+			return m.Make + " " + m.Model
+		}), nil
+	case "lens":
+		return metadataCondition(t.text, opts.CaseSensitive, func(m *media.Metadata) string {
+			return m.Lens
+		}), nil
+	case "near":
+		return wrap(nearCondition(t))
+	case "tag":
+		return tagCondition(t.text), nil
+	case "content":
+		n := newContentNode(t.text, opts.CaseSensitive)
+		opts.contents = append(opts.contents, n)
+		return n, nil
+	default:
+		return nil, &QueryError{Pos: t.pos, Msg: fmt.Sprintf(`unknown field "%s:", quote the term to search it`, t.field)}
 	}
+}
+
+func wrap(cond condition, err error) (node, error) {
+	if err != nil {
+		return nil, err
+	}
+	return cond, nil
+}
+
+// caseSensitive reads the case option of a query, case:sensitive or
+// case:insensitive, before it is parsed.
+func caseSensitive(query string) bool {
+	sensitive := false
+	l := &lexer{input: query}
+	for {
+		t, err := l.next()
+		if err != nil || t.kind == tokenEOF {
+			return sensitive
+		}
+		if t.kind == tokenField && t.field == "case" {
+			sensitive = t.text == "sensitive"
+		}
+	}
+}
 
-	// if the value starts with " and finishes what that character, we will
-	// only search for that term
-	if value[0] == '"' && value[len(value)-1] == '"' {
-		unique := strings.TrimPrefix(value, "\"")
-		unique = strings.TrimSuffix(unique, "\"")
+type searchOptions struct {
+	CaseSensitive bool
+	// now is the time the ages of the files are computed from.
+	now  time.Time
+	root node
+	// contents are the content conditions of the query.
+	contents []*contentNode
+}
 
-		opts.Terms = []string{unique}
-		return opts
+// parseSearch parses a search query. Terms match the names containing
+// them and fields such as type:image or size:>1M other properties of the
+// files. Terms and fields are combined with AND, the default, OR, negated
+// with a leading - or NOT, and grouped with parentheses.
+func parseSearch(query string) (*searchOptions, error) {
+	opts := &searchOptions{
+		CaseSensitive: caseSensitive(query),
+		now:           time.Now(),
 	}
 
-	opts.Terms = strings.Split(value, " ")
-	return opts
+	root, err := parseQuery(query, opts)
+	if err != nil {
+		return nil, err
+	}
+	opts.root = root
+	return opts, nil
+}
+
+// match checks if a file matches the search.
+func (s *searchOptions) match(c *candidate) bool {
+	return s.root == nil || s.root.match(c)
 }
@@ -0,0 +1,121 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/nulnl/nulyun/internal/index"
+)
+
+func TestSearchResume(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a/b.txt", "a/c/d.txt", "a-b/e.txt", "a.txt", "b/f.txt"} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ix, err := index.Open(filepath.Join(t.TempDir(), "search.db"), root, time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	if err := ix.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	afs := afero.NewBasePathFs(afero.NewOsFs(), root)
+	searches := map[string]func(after string, found FoundFunc) error{
+		"disk": func(after string, found FoundFunc) error {
+			return Search(context.Background(), afs, "/", "", after, allowAll{}, nil, found)
+		},
+		"index": func(after string, found FoundFunc) error {
+			return SearchIndex(context.Background(), ix, "/", "/", "", after, allowAll{}, nil, found)
+		},
+	}
+
+	want := []string{"a", "a/b.txt", "a/c", "a/c/d.txt", "a-b", "a-b/e.txt", "a.txt", "b", "b/f.txt"}
+	for name, search := range searches {
+		t.Run(name, func(t *testing.T) {
+			// Pages of 2 results, each resumed after the last result of
+			// the previous one, find everything in order.
+			var got []string
+			after := ""
+			for range len(want) {
+				var page []string
+				err := search(after, func(path string, _ os.FileInfo, _ []Snippet) error {
+					page = append(page, path)
+					if len(page) == 2 {
+						return fs.SkipAll
+					}
+					return nil
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(page) == 0 {
+					break
+				}
+				got = append(got, page...)
+				after = page[len(page)-1]
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("resumed search = %q, want %q", got, want)
+			}
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = Search(ctx, afs, "/", "", "", allowAll{}, nil, func(string, os.FileInfo, []Snippet) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Search() with a canceled context error = %v", err)
+	}
+}
+
+func TestTagSearch(t *testing.T) {
+	afs := afero.NewMemMapFs()
+	for _, name := range []string{"/a.txt", "/b.txt", "/docs/c.txt"} {
+		if err := afero.WriteFile(afs, name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tags := func(name string) []string {
+		if name == "/a.txt" || name == "/docs/c.txt" {
+			return []string{"Work Notes"}
+		}
+		return nil
+	}
+
+	search := func(tags TagsFunc) []string {
+		t.Helper()
+		var found []string
+		err := Search(context.Background(), afs, "/", `tag:"work  notes"`, "", allowAll{}, tags, func(path string, _ os.FileInfo, _ []Snippet) error {
+			found = append(found, path)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return found
+	}
+
+	if got, want := search(tags), []string{"a.txt", "docs/c.txt"}; !slices.Equal(got, want) {
+		t.Errorf("tag search = %v, want %v", got, want)
+	}
+	if got := search(nil); len(got) != 0 {
+		t.Errorf("tag search without tags = %v, want nothing", got)
+	}
+}
@@ -0,0 +1,217 @@
+package fbhttp
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/nulnl/nulyun/internal/files"
+	"github.com/nulnl/nulyun/internal/model/activity"
+	"github.com/nulnl/nulyun/internal/model/audit"
+	storage "github.com/nulnl/nulyun/internal/repository"
+)
+
+// auditActivity are the activity actions of the audited actions.
+var auditActivity = map[string]string{
+	audit.ActionFileCreate:   activity.ActionCreated,
+	audit.ActionFileModify:   activity.ActionModified,
+	audit.ActionFileDelete:   activity.ActionDeleted,
+	audit.ActionFileMove:     activity.ActionMoved,
+	audit.ActionFileCopy:     activity.ActionCreated,
+	audit.ActionFileDownload: activity.ActionOpened,
+}
+
+// webdavActivity are the activity actions of the WebDAV writes.
+var webdavActivity = map[string]string{
+	http.MethodPut:    activity.ActionModified,
+	"MKCOL":           activity.ActionCreated,
+	http.MethodDelete: activity.ActionDeleted,
+	"MOVE":            activity.ActionMoved,
+	"COPY":            activity.ActionCreated,
+}
+
+// recordActivity records the activity of a successful audited action,
+// whose paths are relative to scope.
+func recordActivity(store *storage.Storage, e *audit.Entry, scope string) {
+	if e.Outcome != audit.OutcomeSuccess || e.UserID == 0 {
+		return
+	}
+
+	action, ok := auditActivity[e.Action]
+	if e.Action == audit.ActionWebDAVWrite {
+		action, ok = webdavActivity[e.Method]
+	}
+	if !ok {
+		return
+	}
+
+	a := &activity.Item{
+		UserID:   e.UserID,
+		Username: e.Username,
+		Action:   action,
+		Path:     path.Join("/", scope, e.Path),
+		Time:     e.Time,
+	}
+	switch {
+	case action == activity.ActionMoved:
+		a.Destination = path.Join("/", scope, e.Destination)
+	case e.Action == audit.ActionFileCopy || (e.Action == audit.ActionWebDAVWrite && e.Method == "COPY"):
+		// A copy is the creation of its destination.
+		a.Path = path.Join("/", scope, e.Destination)
+	}
+
+	if err := store.Activity.Record(a); err != nil {
+		log.Printf("failed to record the activity %s %s: %v", a.Action, a.Path, err)
+	}
+}
+
+// recordOpened records that the user opened the file name, unless it's
+// already the last thing they did, to spare a write to the reloads.
+func recordOpened(d *data, name string) {
+	p := d.rootPath(name)
+	if last, err := d.store.Activity.Last(d.user.ID); err == nil && last.Action == activity.ActionOpened && last.Path == p {
+		return
+	}
+
+	err := d.store.Activity.Record(&activity.Item{
+		UserID:   d.user.ID,
+		Username: d.user.Username,
+		Action:   activity.ActionOpened,
+		Path:     p,
+	})
+	if err != nil {
+		log.Printf("failed to record the activity %s %s: %v", activity.ActionOpened, name, err)
+	}
+}
+
+// parseActivityLimit reads the number of items asked, 50 by default.
+func parseActivityLimit(r *http.Request) (int, error) {
+	v := r.URL.Query().Get("limit")
+	if v == "" {
+		return 50, nil
+	}
+	limit, err := strconv.Atoi(v)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("invalid limit %q", v)
+	}
+	return min(limit, activity.MaxItems), nil
+}
+
+type recentFile struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+	Time   int64  `json:"time"`
+	IsDir  bool   `json:"isDir"`
+	Size   int64  `json:"size"`
+	Type   string `json:"type"`
+}
+
+// recentHandler returns the files the user recently created, modified,
+// moved or opened, newest first. The files deleted or hidden since then
+// are left out.
+var recentHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	limit, err := parseActivityLimit(r)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	items, err := d.store.Activity.FindByUserID(d.user.ID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	res := []*recentFile{}
+	// seen are the paths of the newer items, the older ones of the same
+	// files being outdated.
+	seen := map[string]bool{}
+	for _, i := range items {
+		if len(res) == limit {
+			break
+		}
+
+		if i.Action == activity.ActionMoved {
+			seen[i.Path] = true
+		}
+		name, ok := d.scopePath(i.Name())
+		if !ok || seen[i.Name()] {
+			continue
+		}
+		seen[i.Name()] = true
+		if i.Action == activity.ActionDeleted || !d.Check(name) {
+			continue
+		}
+
+		info, err := d.user.Fs.Stat(name)
+		if err != nil {
+			continue
+		}
+		res = append(res, &recentFile{
+			Path:   name,
+			Action: i.Action,
+			Time:   i.Time,
+			IsDir:  info.IsDir(),
+			Size:   info.Size(),
+			Type:   files.TypeByExtension(name),
+		})
+	}
+
+	return renderJSON(w, r, res)
+})
+
+type activityChange struct {
+	Action      string `json:"action"`
+	Username    string `json:"username"`
+	Path        string `json:"path,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	Time        int64  `json:"time"`
+}
+
+// activityHandler returns the changes made by the other users to the files
+// the user can see, newest first. The paths the user can't see are left
+// out, such as the source of a file moved into its scope.
+var activityHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	limit, err := parseActivityLimit(r)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	items, err := d.store.Activity.FindOthers(d.user.ID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	visible := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		if name, ok := d.scopePath(name); ok && d.Check(name) {
+			return name
+		}
+		return ""
+	}
+
+	res := []*activityChange{}
+	for _, i := range items {
+		if len(res) == limit {
+			break
+		}
+		if i.Action == activity.ActionOpened {
+			continue
+		}
+
+		c := &activityChange{
+			Action:      i.Action,
+			Username:    i.Username,
+			Path:        visible(i.Path),
+			Destination: visible(i.Destination),
+			Time:        i.Time,
+		}
+		if c.Path != "" || c.Destination != "" {
+			res = append(res, c)
+		}
+	}
+
+	return renderJSON(w, r, res)
+})
@@ -0,0 +1,98 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/nulnl/nulyun/internal/model/activity"
+	"github.com/nulnl/nulyun/internal/model/users"
+)
+
+func TestRecentFiles(t *testing.T) {
+	s := newTestServer(t, nil, &users.User{
+		Username: "alice",
+		Perm:     users.Permissions{Rename: true},
+	})
+	tk := s.token(t, 1)
+	s.writeFile(t, "a.txt", "a")
+
+	for range 2 {
+		if rec := s.do(t, http.MethodGet, "/api/resources/a.txt", tk, ""); rec.Code != http.StatusOK {
+			t.Fatalf("open: %d %s", rec.Code, rec.Body)
+		}
+	}
+	items, err := s.store.Activity.FindByUserID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []uint64
+	for _, i := range items {
+		ids = append(ids, i.ID)
+	}
+	if !slices.Equal(ids, []uint64{1}) {
+		t.Errorf("items after opening twice = %v, want the first open only", ids)
+	}
+
+	if rec := s.do(t, http.MethodPatch, "/api/resources/a.txt?action=rename&destination=%2Fb.txt", tk, ""); rec.Code != http.StatusOK {
+		t.Fatalf("move: %d %s", rec.Code, rec.Body)
+	}
+
+	rec := s.do(t, http.MethodGet, "/api/recent", tk, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("recent: %d %s", rec.Code, rec.Body)
+	}
+	var recent []*recentFile
+	if err := json.NewDecoder(rec.Body).Decode(&recent); err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != 1 || recent[0].Path != "/b.txt" || recent[0].Action != activity.ActionMoved {
+		t.Errorf("recent files = %+v, want the move to /b.txt only", recent)
+	}
+}
+
+func TestActivityFeed(t *testing.T) {
+	s := newTestServer(t, nil,
+		&users.User{Username: "alice"},
+		&users.User{Username: "bob", Scope: "/bob", HideDotfiles: true},
+	)
+	for _, name := range []string{"bob/x.txt", "bob/.secret", "bob/in.txt", "other.txt"} {
+		s.writeFile(t, name, name)
+	}
+
+	for _, i := range []*activity.Item{
+		{UserID: 1, Action: activity.ActionCreated, Path: "/bob/x.txt"},
+		{UserID: 1, Action: activity.ActionOpened, Path: "/bob/x.txt"},
+		{UserID: 1, Action: activity.ActionCreated, Path: "/bob/.secret"},
+		{UserID: 1, Action: activity.ActionCreated, Path: "/other.txt"},
+		{UserID: 1, Action: activity.ActionMoved, Path: "/outside.txt", Destination: "/bob/in.txt"},
+		{UserID: 2, Action: activity.ActionCreated, Path: "/bob/own.txt"},
+	} {
+		i.Username = map[uint]string{1: "alice", 2: "bob"}[i.UserID]
+		if err := s.store.Activity.Record(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := s.do(t, http.MethodGet, "/api/activity", s.token(t, 2), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("activity: %d %s", rec.Code, rec.Body)
+	}
+	var changes []*activityChange
+	if err := json.NewDecoder(rec.Body).Decode(&changes); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Username+" "+c.Action+" "+c.Path+" "+c.Destination)
+	}
+	want := []string{
+		"alice moved  /in.txt",
+		"alice created /x.txt ",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("activity = %q, want %q", got, want)
+	}
+}
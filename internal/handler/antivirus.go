@@ -0,0 +1,66 @@
+package fbhttp
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/nulnl/nulyun/internal/model/audit"
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/users"
+	storage "github.com/nulnl/nulyun/internal/repository"
+	"github.com/nulnl/nulyun/internal/service"
+)
+
+// scanTimeout limits the scan of an upload and the notifications sent
+// when it is infected.
+const scanTimeout = 10 * time.Minute
+
+// antivirus scans the uploads. It is nil when no clamd daemon is set.
+var antivirus *service.Antivirus
+
+// scanUpload scans in the background a file uploaded by u. An infected
+// file is quarantined, which is audited and published to the clients, and
+// the user and the admins are emailed. Files that can't be scanned are
+// kept, the error being logged.
+func scanUpload(store *storage.Storage, server *settings.Server, u *users.User, name string) {
+	if antivirus == nil {
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), scanTimeout)
+		defer cancel()
+
+		inf, err := antivirus.ScanUpload(ctx, server.Root, u, name)
+		if err != nil {
+			log.Printf("failed to scan %s of %q: %v", name, u.Username, err)
+		}
+		if inf == nil {
+			return
+		}
+
+		e := &audit.Entry{
+			Action:    audit.ActionFileQuarantine,
+			UserID:    u.ID,
+			Username:  u.Username,
+			Path:      name,
+			Signature: inf.Signature,
+			Outcome:   audit.OutcomeSuccess,
+		}
+		// The infected file is still in place when it couldn't be moved.
+		if err != nil {
+			e.Outcome = audit.OutcomeFailure
+		} else {
+			followDelete(store, u.Scope, name)
+		}
+		saveAudit(store, e, u.Scope)
+
+		if !server.SMTP.Configured() {
+			return
+		}
+		if err := service.NotifyInfection(ctx, store, server.Root, &server.SMTP, inf); err != nil {
+			log.Printf("failed to notify the quarantine of %s of %q: %v", name, u.Username, err)
+		}
+	}()
+}
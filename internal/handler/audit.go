@@ -0,0 +1,191 @@
+package fbhttp
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/tomasen/realip"
+
+	"github.com/nulnl/nulyun/internal/model/audit"
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/users"
+	"github.com/nulnl/nulyun/internal/model/webdav"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+	storage "github.com/nulnl/nulyun/internal/repository"
+)
+
+type auditResponse struct {
+	Total   int            `json:"total"`
+	Offset  int            `json:"offset"`
+	Limit   int            `json:"limit"`
+	Entries []*audit.Entry `json:"entries"`
+}
+
+// recordAudit completes an entry with the request, the current user and
+// the impersonating admin, then saves it. Failing to record an entry never
+// fails the request.
+func recordAudit(r *http.Request, d *data, e *audit.Entry) {
+	e.IP = realip.FromRequest(r)
+	e.Method = r.Method
+	if e.UserID == 0 && e.Username == "" && d.user != nil {
+		e.UserID = d.user.ID
+		e.Username = d.user.Username
+	}
+	if d.actor != nil {
+		e.Actor = d.actor.Username
+	}
+
+	scope := ""
+	if d.user != nil {
+		scope = d.user.Scope
+	}
+	saveAudit(d.store, e, scope)
+}
+
+// saveAudit records an entry and the activity of the user, and publishes
+// the event of the action, if any. The paths of the entry are relative to
+// scope.
+func saveAudit(store *storage.Storage, e *audit.Entry, scope string) {
+	if err := store.Audit.Record(e); err != nil {
+		log.Printf("failed to record the audit entry %s %s: %v", e.Action, e.Path, err)
+	}
+
+	recordActivity(store, e, scope)
+	publishAudited(e, scope)
+}
+
+// recordLogin records a login attempt of username.
+func recordLogin(r *http.Request, d *data, username string, status int) {
+	action := audit.ActionLogin
+	if audit.OutcomeOf(status) == audit.OutcomeFailure {
+		action = audit.ActionLoginFailed
+	}
+
+	recordAudit(r, d, &audit.Entry{Action: action, Username: username, Status: status})
+}
+
+// withAudit records the requests served by fn under action. Requests
+// rejected before the user is known are only logged by handle.
+func withAudit(action string, fn handleFunc) handleFunc {
+	return func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		status, err := fn(w, r, d)
+		if d.user == nil {
+			return status, err
+		}
+
+		// Copies share the route of moves.
+		e := &audit.Entry{Action: action}
+		if action == audit.ActionFileMove && r.URL.Query().Get("action") == "copy" {
+			e.Action = audit.ActionFileCopy
+		}
+
+		e.Path = r.URL.Path
+		e.Destination = d.destination
+		if e.Destination == "" {
+			e.Destination, _ = url.QueryUnescape(r.URL.Query().Get("destination"))
+		}
+		e.Status = max(status, http.StatusOK)
+		recordAudit(r, d, e)
+
+		return status, err
+	}
+}
+
+// webdavObserver records the WebDAV requests modifying files, starts the
+// hooks run after them and scans the uploaded files.
+func webdavObserver(store *storage.Storage, server *settings.Server) webdav.Observer {
+	return func(r *http.Request, u *users.User, name, dst string, err error) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND", "LOCK", "UNLOCK":
+			return
+		}
+
+		e := &audit.Entry{
+			Action:      audit.ActionWebDAVWrite,
+			UserID:      u.ID,
+			Username:    u.Username,
+			IP:          realip.FromRequest(r),
+			Method:      r.Method,
+			Path:        name,
+			Destination: dst,
+			Outcome:     audit.OutcomeSuccess,
+		}
+		if err != nil {
+			e.Outcome = audit.OutcomeFailure
+		}
+
+		if err == nil {
+			switch r.Method {
+			case "MOVE":
+				followMove(store, u.Scope, name, dst)
+			case http.MethodDelete:
+				followDelete(store, u.Scope, name)
+			}
+		}
+
+		saveAudit(store, e, u.Scope)
+		if err == nil {
+			webdavAfterHooks(store, server, r, u, name, dst)
+			if r.Method == http.MethodPut {
+				scanUpload(store, server, u, name)
+			}
+		}
+	}
+}
+
+// parseAuditTime reads a time given as a Unix timestamp or in RFC 3339.
+func parseAuditTime(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, fberrors.ErrInvalidRequestParams
+	}
+	return t.Unix(), nil
+}
+
+var auditGetHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	query := r.URL.Query()
+	f := &audit.Filter{
+		Action:   query.Get("action"),
+		Username: query.Get("user"),
+		Path:     query.Get("path"),
+		Outcome:  query.Get("outcome"),
+	}
+
+	var err error
+	if f.Since, err = parseAuditTime(query.Get("since")); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if f.Until, err = parseAuditTime(query.Get("until")); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	for name, v := range map[string]*int{"offset": &f.Offset, "limit": &f.Limit} {
+		if s := query.Get(name); s != "" {
+			if *v, err = strconv.Atoi(s); err != nil || *v < 0 {
+				return http.StatusBadRequest, fberrors.ErrInvalidRequestParams
+			}
+		}
+	}
+
+	entries, total, err := d.store.Audit.Query(f)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, &auditResponse{
+		Total:   total,
+		Offset:  f.Offset,
+		Limit:   f.Limit,
+		Entries: entries,
+	})
+})
@@ -5,15 +5,21 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"net/mail"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/golang-jwt/jwt/v5/request"
+	"github.com/tomasen/realip"
 
 	fbAuth "github.com/nulnl/nulyun/internal/auth"
+	"github.com/nulnl/nulyun/internal/events"
+	"github.com/nulnl/nulyun/internal/model/audit"
 	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/session"
+	"github.com/nulnl/nulyun/internal/model/signup"
 	"github.com/nulnl/nulyun/internal/model/users"
 	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
 )
@@ -42,10 +48,14 @@ type userInfo struct {
 	AceEditorTheme    string            `json:"aceEditorTheme"`
 	OTPEnabled        bool              `json:"otpEnabled"`
 	OTPPending        bool              `json:"otpPending"`
+	OTPRequired       bool              `json:"otpRequired"`
+	OTPDeadline       int64             `json:"otpDeadline,omitempty"`
+	AccountExpiresAt  int64             `json:"accountExpiresAt,omitempty"`
 }
 
 type authToken struct {
-	User userInfo `json:"user"`
+	User  userInfo    `json:"user"`
+	Actor *actorClaim `json:"act,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -89,17 +99,28 @@ func renewableErr(err error, d *data) bool {
 
 func withUser(fn handleFunc) handleFunc {
 	return func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-		keyFunc := func(_ *jwt.Token) (interface{}, error) {
-			return d.settings.Key, nil
+		ring, err := d.store.Keyring.Get()
+		if err != nil {
+			return http.StatusInternalServerError, err
 		}
 
 		var tk authToken
-		p := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
-		token, err := request.ParseFromRequest(r, &extractor{}, keyFunc, request.WithClaims(&tk), request.WithParser(p))
+		p := jwt.NewParser(jwt.WithValidMethods(ring.Methods()), jwt.WithExpirationRequired())
+		token, err := request.ParseFromRequest(r, &extractor{}, ring.Keyfunc(d.settings.Key), request.WithClaims(&tk), request.WithParser(p))
 		if (err != nil || !token.Valid) && !renewableErr(err, d) {
 			return http.StatusUnauthorized, nil
 		}
 
+		// Tokens are bound to a server-side session so they can be revoked.
+		d.session, err = d.store.Sessions.Get(tk.ID)
+		if err != nil || d.session.UserID != tk.User.ID || d.session.Revoked {
+			return http.StatusUnauthorized, nil
+		}
+
+		if err := d.store.Sessions.Touch(d.session, realip.FromRequest(r)); err != nil {
+			log.Printf("failed to update session activity: %v", err)
+		}
+
 		expiresSoon := tk.ExpiresAt != nil && time.Until(tk.ExpiresAt.Time) < time.Hour
 		updated := tk.IssuedAt != nil && tk.IssuedAt.Unix() < d.store.Users.LastUpdate(tk.User.ID)
 
@@ -111,6 +132,26 @@ func withUser(fn handleFunc) handleFunc {
 		if err != nil {
 			return http.StatusInternalServerError, err
 		}
+
+		if status := d.user.Status(); status != users.StatusActive {
+			w.Header().Set("X-Account-Status", status)
+			return http.StatusUnauthorized, nil
+		}
+		if d.user.ExpiresAt != 0 {
+			w.Header().Set("X-Account-Expires", time.Unix(d.user.ExpiresAt, 0).UTC().Format(http.TimeFormat))
+		}
+
+		// Once the grace period is over, users missing a required second
+		// factor can only reach the enrollment endpoints.
+		if !d.enrollment && twoFactorRestricted(d, d.user) {
+			w.Header().Set("X-OTP-Required", "true")
+			return http.StatusForbidden, nil
+		}
+
+		if d.session.ActorID != 0 {
+			return serveImpersonated(fn, w, r, d)
+		}
+
 		return fn(w, r, d)
 	}
 }
@@ -132,19 +173,41 @@ func loginHandler(totpLoginTokenExpireTime, tokenExpireTime time.Duration) handl
 			return http.StatusInternalServerError, err
 		}
 
+		// Only credentials based methods can be brute-forced.
+		ip, username := realip.FromRequest(r), ""
+		if auther.LoginPage() {
+			username = peekUsername(r)
+			if wait := checkAuthAttempt(d, ip, username); wait > 0 {
+				return tooManyAttempts(w, wait)
+			}
+		}
+
 		user, err := auther.Auth(r, d.store.Users, d.settings, d.server)
 		switch {
 		case errors.Is(err, os.ErrPermission):
+			recordAuthFailure(d, "login", ip, username)
+			recordLogin(r, d, username, http.StatusForbidden)
 			return http.StatusForbidden, nil
 		case err != nil:
 			return http.StatusInternalServerError, err
 		}
 
+		if status := user.Status(); status != users.StatusActive {
+			w.Header().Set("X-Account-Status", status)
+			recordLogin(r, d, user.Username, http.StatusForbidden)
+			return http.StatusForbidden, nil
+		}
+
 		// Check if TOTP is enabled at all levels (server, settings, user)
-		if d.server.EnableTOTP && d.settings.TOTPEnabled && user.TOTPEnabled && user.TOTPSecret != "" && user.TOTPVerified {
+		totpEnabled := user.TOTPEnabled || twoFactorRequired(d, user)
+		if d.server.EnableTOTP && d.settings.TOTPEnabled && totpEnabled && user.TOTPSecret != "" && user.TOTPVerified {
 			return printTOTPToken(w, r, d, user, totpLoginTokenExpireTime)
 		}
 
+		// The failures are only forgotten once the whole login succeeded,
+		// so the password can't clear the TOTP failures.
+		authLimiter.Reset("user:" + username)
+		recordLogin(r, d, user.Username, http.StatusOK)
 		return printToken(w, r, d, user, tokenExpireTime)
 	}
 }
@@ -152,9 +215,11 @@ func loginHandler(totpLoginTokenExpireTime, tokenExpireTime time.Duration) handl
 type signupBody struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Email    string `json:"email"`
+	Invite   string `json:"invite"`
 }
 
-var signupHandler = func(_ http.ResponseWriter, r *http.Request, d *data) (int, error) {
+var signupHandler = func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
 	if !d.settings.Signup {
 		return http.StatusMethodNotAllowed, nil
 	}
@@ -173,11 +238,39 @@ var signupHandler = func(_ http.ResponseWriter, r *http.Request, d *data) (int,
 		return http.StatusBadRequest, nil
 	}
 
+	policy := d.settings.SignupPolicy
+	if policy.VerifyEmail && d.server.PublicURL == "" {
+		return http.StatusInternalServerError, errNoPublicURL
+	}
+	if info.Email != "" || policy.VerifyEmail {
+		addr, err := mail.ParseAddress(info.Email)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		info.Email = addr.Address
+	}
+
+	// The invitation is only consumed once the user is created.
+	var invite *signup.Invite
+	if info.Invite != "" || policy.InviteOnly {
+		invite, err = d.store.Signup.GetInvite(info.Invite)
+		if err != nil || !invite.Usable() {
+			return http.StatusForbidden, nil
+		}
+	}
+
 	user := &users.User{
 		Username: info.Username,
+		Email:    info.Email,
+		// Invited users were already vetted by an admin.
+		AwaitingApproval:     policy.Approval && invite == nil,
+		AwaitingVerification: policy.VerifyEmail,
 	}
 
 	d.settings.Defaults.Apply(user)
+	if invite != nil {
+		invite.Apply(user)
+	}
 
 	pwd, err := users.ValidateAndHashPwd(info.Password, d.settings.MinimumPasswordLength)
 	if err != nil {
@@ -204,7 +297,33 @@ var signupHandler = func(_ http.ResponseWriter, r *http.Request, d *data) (int,
 		return http.StatusInternalServerError, err
 	}
 
-	return http.StatusOK, nil
+	if invite != nil {
+		if _, err := d.store.Signup.UseInvite(invite.Code); err != nil {
+			// Another signup used the last slot of the invitation.
+			if err := d.store.Users.Delete(user.ID); err != nil {
+				log.Printf("failed to delete user %q: %v", user.Username, err)
+			}
+			return http.StatusForbidden, nil
+		}
+	}
+
+	if user.AwaitingVerification {
+		if err := sendVerification(r, d, user); err != nil {
+			log.Printf("failed to send the verification email to %q: %v", user.Username, err)
+			if err := d.store.Users.Delete(user.ID); err != nil {
+				log.Printf("failed to delete user %q: %v", user.Username, err)
+			}
+			return http.StatusInternalServerError, err
+		}
+	}
+
+	recordAudit(r, d, &audit.Entry{Action: audit.ActionUserCreate, UserID: user.ID, Username: user.Username, Path: r.URL.Path})
+	eventBus.Publish(events.Event{Type: events.UserCreated, UserID: user.ID, Username: user.Username})
+
+	return renderJSON(w, r, signupResponse{
+		AwaitingApproval:     user.AwaitingApproval,
+		AwaitingVerification: user.AwaitingVerification,
+	})
 }
 
 func renewHandler(tokenExpireTime time.Duration) handleFunc {
@@ -214,7 +333,12 @@ func renewHandler(tokenExpireTime time.Duration) handleFunc {
 	})
 }
 
-func printToken(w http.ResponseWriter, _ *http.Request, d *data, user *users.User, tokenExpirationTime time.Duration) (int, error) {
+func printToken(w http.ResponseWriter, r *http.Request, d *data, user *users.User, tokenExpirationTime time.Duration) (int, error) {
+	sess, err := issueSession(r, d, user, tokenExpirationTime)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
 	claims := &authToken{
 		User: userInfo{
 			ID:           user.ID,
@@ -226,19 +350,54 @@ func printToken(w http.ResponseWriter, _ *http.Request, d *data, user *users.Use
 			HideDotfiles: user.HideDotfiles, HideHiddenFolders: user.HideHiddenFolders, DateFormat: user.DateFormat,
 			Username:       user.Username,
 			AceEditorTheme: user.AceEditorTheme,
-			OTPEnabled:     user.TOTPSecret != "" && user.TOTPVerified, OTPPending: user.TOTPSecret != "" && !user.TOTPVerified},
+			OTPEnabled:     user.TOTPSecret != "" && user.TOTPVerified, OTPPending: user.TOTPSecret != "" && !user.TOTPVerified,
+			OTPRequired: twoFactorRequired(d, user), AccountExpiresAt: user.ExpiresAt},
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenExpirationTime)),
 			Issuer:    "Nul Yun",
+			ID:        sess.ID,
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString(d.settings.Key)
+	if claims.User.OTPRequired && !user.TwoFactorEnrolled() {
+		claims.User.OTPDeadline = d.settings.TwoFactor.Deadline().Unix()
+	}
+	if sess.ActorID != 0 {
+		claims.Actor = &actorClaim{ID: sess.ActorID, Subject: sess.Actor}
+	}
+
+	signed, err := signToken(d, claims)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
 
 	return renderJSON(w, nil, loginResponse{Token: signed, OTP: false})
 }
+
+// issueSession returns the session the new token will be bound to. Renewing
+// a token keeps its session, any other login starts a new one.
+func issueSession(r *http.Request, d *data, user *users.User, tokenExpirationTime time.Duration) (*session.Session, error) {
+	sess := d.session
+	if sess == nil || sess.UserID != user.ID {
+		var err error
+		sess, err = session.New(user.ID, r.UserAgent(), realip.FromRequest(r), tokenExpirationTime)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		sess.ExpiresAt = time.Now().Add(tokenExpirationTime).Unix()
+	}
+
+	return sess, d.store.Sessions.Save(sess)
+}
+
+// signToken signs the claims with the active key of the keyring.
+func signToken(d *data, claims jwt.Claims) (string, error) {
+	ring, err := d.store.Keyring.Get()
+	if err != nil {
+		return "", err
+	}
+
+	return ring.Sign(claims)
+}
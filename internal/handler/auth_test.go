@@ -0,0 +1,42 @@
+package fbhttp
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/users"
+)
+
+func TestLoginKeepsTOTPFailures(t *testing.T) {
+	pwd, err := users.HashPwd("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The limiter is shared by the tests, so the user is only used here.
+	const username = "totp-failures"
+	bf := settings.BruteForce{Enabled: true, MaxAttempts: 5, BaseDelay: 1, MaxDelay: 60}
+	s := newTestServer(t, &settings.Settings{TOTPEnabled: true, BruteForce: &bf}, &users.User{
+		Username:     username,
+		Password:     pwd,
+		TOTPEnabled:  true,
+		TOTPSecret:   "secret",
+		TOTPVerified: true,
+	})
+	s.server.EnableTOTP = true
+
+	for range 3 {
+		authLimiter.Fail(bf, "user:"+username)
+	}
+
+	body := `{"username":"` + username + `","password":"password"}`
+	rec := s.do(t, http.MethodPost, "/api/login", "", body)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"otp":true`) {
+		t.Fatalf("login: %d %s", rec.Code, rec.Body)
+	}
+
+	if failures := authLimiter.Fail(bf, "user:"+username); failures != 4 {
+		t.Errorf("the password reset the TOTP failures: %d failures, want 4", failures)
+	}
+}
@@ -0,0 +1,119 @@
+package fbhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nulnl/nulyun/internal/auth"
+)
+
+// maxPeekBodySize limits how much of a login body is buffered to find the username.
+const maxPeekBodySize = 1 << 20
+
+// Tracks failed logins, TOTP codes and share passwords
+var authLimiter = auth.NewLimiter()
+
+// tooManyAttempts rejects a request that must wait before trying again.
+func tooManyAttempts(w http.ResponseWriter, wait time.Duration) (int, error) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return http.StatusTooManyRequests, nil
+}
+
+// peekUsername reads the username of a login request without consuming
+// the body, which is still needed by the auther.
+func peekUsername(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxPeekBodySize))
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var cred struct {
+		Username string `json:"username"`
+	}
+	if err := json.Unmarshal(body, &cred); err != nil {
+		return ""
+	}
+	return cred.Username
+}
+
+// checkAuthAttempt returns how long the client must wait before it's
+// allowed to authenticate as username, taking account locks into account.
+func checkAuthAttempt(d *data, ip, username string) time.Duration {
+	keys := []string{"ip:" + ip}
+	if username != "" {
+		keys = append(keys, "user:"+username)
+	}
+
+	wait := authLimiter.Check(*d.settings.BruteForce, keys...)
+	if username == "" || !d.settings.BruteForce.Enabled {
+		return wait
+	}
+
+	if u, err := d.store.Users.Get(d.server.Root, username); err == nil {
+		if lock, locked := u.Locked(); locked {
+			wait = max(wait, lock)
+		}
+	}
+
+	return wait
+}
+
+// recordAuthFailure logs a failed attempt in a fail2ban friendly format and
+// locks the account once it reaches the lockout threshold.
+func recordAuthFailure(d *data, kind, ip, username string) {
+	log.Printf("authentication failure: type=%s ip=%s user=%q", kind, ip, username)
+
+	cfg := *d.settings.BruteForce
+	authLimiter.Fail(cfg, "ip:"+ip)
+	if username == "" {
+		return
+	}
+
+	failures := authLimiter.Fail(cfg, "user:"+username)
+	if cfg.LockoutThreshold == 0 || failures < cfg.LockoutThreshold {
+		return
+	}
+
+	u, err := d.store.Users.Get(d.server.Root, username)
+	if err != nil {
+		return
+	}
+
+	u.LockedUntil = time.Now().Add(time.Duration(cfg.LockoutDuration) * time.Second).Unix()
+	if err := d.store.Users.Update(u, "LockedUntil"); err != nil {
+		log.Printf("failed to lock account %q: %v", username, err)
+		return
+	}
+	log.Printf("account locked: ip=%s user=%q until=%s", ip, username, time.Unix(u.LockedUntil, 0).Format(time.RFC3339))
+}
+
+var userUnlockHandler = withAdmin(func(_ http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	id, err := getUserID(r)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	u, err := d.store.Users.Get(d.server.Root, id)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	u.LockedUntil = 0
+	if err := d.store.Users.Update(u, "LockedUntil"); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	authLimiter.Reset("user:" + u.Username)
+
+	return http.StatusOK, nil
+})
@@ -0,0 +1,193 @@
+package fbhttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/nulnl/nulyun/internal/model/audit"
+	"github.com/nulnl/nulyun/internal/runner"
+)
+
+// defaultCommandTimeout stops the commands when the settings set no timeout.
+const defaultCommandTimeout = time.Minute
+
+var errCrossOrigin = errors.New("cross-origin request")
+
+// commandMessage is sent to the client running a command: its output, as
+// it is written, then its exit code or the error stopping it.
+type commandMessage struct {
+	Type string `json:"type"` // stdout, stderr, exit or error
+	Data string `json:"data,omitempty"`
+	Code *int   `json:"code,omitempty"`
+}
+
+// commandStream sends the messages of a command, from the goroutines
+// copying its output.
+type commandStream struct {
+	mux sync.Mutex
+	ws  *websocket.Conn
+}
+
+func (s *commandStream) send(m commandMessage) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return websocket.JSON.Send(s.ws, m)
+}
+
+// output returns a writer sending what the command writes as messages of
+// type typ.
+func (s *commandStream) output(typ string) *commandOutput {
+	return &commandOutput{stream: s, typ: typ}
+}
+
+type commandOutput struct {
+	stream *commandStream
+	typ    string
+}
+
+func (o *commandOutput) Write(p []byte) (int, error) {
+	if err := o.stream.send(commandMessage{Type: o.typ, Data: string(p)}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// sameOrigin refuses the WebSocket connections opened by the pages of other
+// sites, which would be authenticated by the cookie of the user.
+// Non-browser clients send no Origin header.
+func sameOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin != nil && origin.Host != r.Host {
+		return errCrossOrigin
+	}
+
+	config.Origin = origin
+	return nil
+}
+
+// commandsHandler runs a command of the user in the requested directory.
+// The client sends the command line as the first message and receives its
+// output until the connection is closed.
+var commandsHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.user.Perm.Execute {
+		return http.StatusForbidden, nil
+	}
+	// Commands can modify anything, so admins acting as the user can't run
+	// them when impersonation is read-only.
+	if d.actor != nil && d.settings.ImpersonationReadOnly {
+		return http.StatusForbidden, nil
+	}
+
+	if !d.Check(r.URL.Path) {
+		return http.StatusForbidden, nil
+	}
+	info, err := d.user.Fs.Stat(r.URL.Path)
+	if err != nil {
+		return errToStatus(err), err
+	}
+	if !info.IsDir() {
+		return http.StatusBadRequest, nil
+	}
+
+	server := websocket.Server{
+		Handshake: sameOrigin,
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			var raw string
+			if err := websocket.Message.Receive(ws, &raw); err != nil {
+				return
+			}
+
+			status := runCommand(ws, d, d.user.FullPath(r.URL.Path), raw)
+			recordAudit(r, d, &audit.Entry{
+				Action:  audit.ActionCommandRun,
+				Path:    r.URL.Path,
+				Command: raw,
+				Status:  status,
+			})
+		},
+	}
+	server.ServeHTTP(w, r)
+
+	return 0, nil
+})
+
+// commandEnv returns the environment of the commands of a user whose scope
+// is home. The server's own variables, such as its signing keys, are never
+// passed to them.
+func commandEnv(home string) []string {
+	env := []string{"HOME=" + home}
+	for _, k := range []string{"PATH", "LANG"} {
+		if v, ok := os.LookupEnv(k); ok {
+			env = append(env, k+"="+v)
+		}
+	}
+	return env
+}
+
+// runCommand runs the command line raw in dir, streaming its output, and
+// returns the status recorded in the audit log.
+func runCommand(ws *websocket.Conn, d *data, dir, raw string) int {
+	stream := &commandStream{ws: ws}
+
+	name, args, err := runner.Parse(d.settings.Shell, raw)
+	if err != nil {
+		_ = stream.send(commandMessage{Type: "error", Data: err.Error()})
+		return http.StatusBadRequest
+	}
+	if !d.user.CanExecute(name) {
+		_ = stream.send(commandMessage{Type: "error", Data: "command not allowed: " + name})
+		return http.StatusForbidden
+	}
+
+	timeout := defaultCommandTimeout
+	if d.settings.CommandTimeout != 0 {
+		timeout = time.Duration(d.settings.CommandTimeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// The command is stopped when the client goes away.
+	go func() {
+		var ignored string
+		for websocket.Message.Receive(ws, &ignored) == nil {
+		}
+		cancel()
+	}()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = commandEnv(d.user.FullPath("/"))
+	cmd.Stdout = stream.output("stdout")
+	cmd.Stderr = stream.output("stderr")
+	// Children keeping the output open can't delay the end of the command.
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		_ = stream.send(commandMessage{Type: "error", Data: "command timed out after " + timeout.String()})
+		return http.StatusGatewayTimeout
+	case cmd.ProcessState != nil:
+		code := cmd.ProcessState.ExitCode()
+		_ = stream.send(commandMessage{Type: "exit", Code: &code})
+		if code != 0 {
+			return http.StatusInternalServerError
+		}
+		return http.StatusOK
+	default:
+		_ = stream.send(commandMessage{Type: "error", Data: err.Error()})
+		return http.StatusInternalServerError
+	}
+}
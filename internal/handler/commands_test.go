@@ -0,0 +1,75 @@
+package fbhttp
+
+import (
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/nulnl/nulyun/internal/model/users"
+)
+
+func TestCommandEnv(t *testing.T) {
+	if _, err := exec.LookPath("env"); err != nil {
+		t.Skip("env is not available")
+	}
+	t.Setenv("NULYUN_JWT_KEYS", "secret-keys")
+	t.Setenv("LANG", "C.UTF-8")
+
+	s := newTestServer(t, nil, &users.User{
+		Username: "alice",
+		Perm:     users.Permissions{Execute: true},
+		Commands: []string{"env"},
+	})
+	srv := httptest.NewServer(s.handler)
+	defer srv.Close()
+
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/command/"
+	config, err := websocket.NewConfig(url, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.Header.Set("X-Auth", s.token(t, 1))
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+
+	if err := websocket.Message.Send(ws, "env"); err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	for {
+		var m commandMessage
+		if err := websocket.JSON.Receive(ws, &m); err != nil {
+			t.Fatal(err)
+		}
+		if m.Type == "stdout" {
+			out.WriteString(m.Data)
+			continue
+		}
+		if m.Type != "exit" || *m.Code != 0 {
+			t.Fatalf("command failed: %+v", m)
+		}
+		break
+	}
+
+	env := strings.Fields(out.String())
+	for _, v := range env {
+		if strings.HasPrefix(v, "NULYUN_") {
+			t.Errorf("server variable passed to the command: %s", v)
+		}
+	}
+	for _, want := range []string{"HOME=" + s.root, "LANG=C.UTF-8"} {
+		found := false
+		for _, v := range env {
+			found = found || v == want
+		}
+		if !found {
+			t.Errorf("%s not in the environment %v", want, env)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/nulnl/nulyun/internal/files"
+	"github.com/nulnl/nulyun/internal/model/comment"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+// applyComments sets the number of comments of a file and of the items of
+// its listing.
+func applyComments(d *data, file *files.FileInfo) {
+	infos := []*files.FileInfo{file}
+	if file.Listing != nil {
+		infos = append(infos, file.Items...)
+	}
+
+	paths := make([]string, len(infos))
+	for i, f := range infos {
+		paths[i] = d.rootPath(f.Path)
+	}
+	ids, err := d.store.Files.Find(paths)
+	if err != nil {
+		log.Printf("failed to load the identities of %s: %v", file.Path, err)
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	fileIDs := make([]uint64, 0, len(ids))
+	for _, f := range ids {
+		fileIDs = append(fileIDs, f.ID)
+	}
+	counts, err := d.store.Comments.Count(fileIDs)
+	if err != nil {
+		log.Printf("failed to count the comments of %s: %v", file.Path, err)
+		return
+	}
+
+	for i, f := range infos {
+		if id, ok := ids[paths[i]]; ok {
+			f.Comments = counts[id.ID]
+		}
+	}
+}
+
+// commentsGetHandler returns the comments of a file, by thread.
+var commentsGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if !d.Check(r.URL.Path) {
+		return http.StatusNotFound, nil
+	}
+	if _, err := d.user.Fs.Stat(r.URL.Path); err != nil {
+		return errToStatus(err), err
+	}
+
+	file, err := d.store.Files.Get(d.rootPath(r.URL.Path))
+	if errors.Is(err, fberrors.ErrNotExist) {
+		return renderJSON(w, r, []*comment.Thread{})
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	comments, err := d.store.Comments.FindByFileID(file.ID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, comment.Threads(comments))
+})
+
+type commentRequest struct {
+	Body     string `json:"body"`
+	ParentID uint   `json:"parentID"`
+}
+
+// commentPostHandler comments a file, or replies to a comment on it when
+// parentID is set. Anyone who can see the file can comment it.
+var commentPostHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	var req commentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if !d.Check(r.URL.Path) {
+		return http.StatusNotFound, nil
+	}
+	if _, err := d.user.Fs.Stat(r.URL.Path); err != nil {
+		return errToStatus(err), err
+	}
+
+	c, err := comment.New(0, req.ParentID, d.user.ID, d.user.Username, req.Body)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	file, err := d.store.Files.Ensure(d.rootPath(r.URL.Path))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	c.FileID = file.ID
+
+	if req.ParentID != 0 {
+		parent, err := d.store.Comments.Get(req.ParentID)
+		if errors.Is(err, fberrors.ErrNotExist) || (err == nil && parent.FileID != file.ID) {
+			return http.StatusBadRequest, errors.New("the parent comment isn't on this file")
+		} else if err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+
+	if err := d.store.Comments.Save(c); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, c)
+})
+
+// withComment loads the comment of the request, which only its author and
+// the admins can change.
+func withComment(fn handleFunc) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 0)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		c, err := d.store.Comments.Get(uint(id))
+		if err != nil {
+			return errToStatus(err), err
+		}
+		if c.UserID != d.user.ID && !d.user.Perm.Admin {
+			return http.StatusForbidden, nil
+		}
+
+		d.raw = c
+		return fn(w, r, d)
+	})
+}
+
+var commentPutHandler = withComment(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	c := d.raw.(*comment.Comment)
+
+	var req commentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	c.Body = strings.TrimSpace(req.Body)
+	c.UpdatedAt = time.Now().Unix()
+	if err := c.Validate(); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if err := d.store.Comments.Save(c); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, c)
+})
+
+var commentDeleteHandler = withComment(func(_ http.ResponseWriter, _ *http.Request, d *data) (int, error) {
+	err := d.store.Comments.Delete(d.raw.(*comment.Comment).ID)
+	return errToStatus(err), err
+})
@@ -1,6 +1,7 @@
 package fbhttp
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"path/filepath"
@@ -10,7 +11,10 @@ import (
 	"github.com/tomasen/realip"
 
 	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/session"
+	"github.com/nulnl/nulyun/internal/model/tags"
 	"github.com/nulnl/nulyun/internal/model/users"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
 	storage "github.com/nulnl/nulyun/internal/repository"
 )
 
@@ -21,7 +25,20 @@ type data struct {
 	server   *settings.Server
 	store    *storage.Storage
 	user     *users.User
+	session  *session.Session
 	raw      interface{}
+
+	// enrollment allows users restricted by the two-factor policy
+	enrollment bool
+	// sessionOnly marks handlers that only manage the current session
+	sessionOnly bool
+	// actor is the admin impersonating the user, if any
+	actor *users.User
+	// labels are the tags of the user, loaded by userLabels
+	labels map[string]*tags.Labels
+	// destination is where a move or copy wrote, which a renaming request
+	// may choose instead of the requested one
+	destination string
 }
 
 // Check implements files.Checker.
@@ -84,7 +101,8 @@ func handle(fn handleFunc, prefix string, store *storage.Storage, server *settin
 
 		if status != 0 {
 			txt := http.StatusText(status)
-			if status == http.StatusBadRequest && err != nil {
+			// Vetoes tell the user why the operation was refused.
+			if err != nil && (status == http.StatusBadRequest || errors.Is(err, fberrors.ErrHookVetoed)) {
 				txt += " (" + err.Error() + ")"
 			}
 			http.Error(w, strconv.Itoa(status)+" "+txt, status)
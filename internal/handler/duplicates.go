@@ -0,0 +1,280 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/nulnl/nulyun/internal/files"
+	"github.com/nulnl/nulyun/internal/model/audit"
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+// duplicatesProgressInterval is how often the progress of a search for
+// duplicates is streamed, besides the start of each stage.
+const duplicatesProgressInterval = 500 * time.Millisecond
+
+// duplicatesReport is the result of a search for duplicates. Wasted is the
+// size taken by all the copies.
+type duplicatesReport struct {
+	Sets   []*files.DuplicateSet `json:"sets"`
+	Wasted int64                 `json:"wasted"`
+}
+
+// duplicatesEnd ends a streamed search for duplicates, with its result or
+// the error which stopped it.
+type duplicatesEnd struct {
+	End bool `json:"end"`
+	*duplicatesReport
+	Error string `json:"error,omitempty"`
+}
+
+// duplicatesGetHandler finds the files of a directory with the same
+// content. The progress is streamed with the ndjson and sse formats of the
+// searches.
+var duplicatesGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	format, err := parseSearchFormat(r)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	var minSize int64
+	if v := r.URL.Query().Get("minSize"); v != "" {
+		minSize, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || minSize < 0 {
+			return http.StatusBadRequest, fmt.Errorf("invalid minSize %q", v)
+		}
+	}
+
+	if _, err := d.user.Fs.Stat(r.URL.Path); err != nil {
+		return errToStatus(err), err
+	}
+
+	sw := &searchWriter{w: w, rc: http.NewResponseController(w), format: format}
+	var stage string
+	var last time.Time
+	var sendErr error
+	progress := func(p files.DuplicateProgress) {
+		if format == searchJSON || sendErr != nil {
+			return
+		}
+		if p.Stage == stage && time.Since(last) < duplicatesProgressInterval {
+			return
+		}
+		stage, last = p.Stage, time.Now()
+		sendErr = sw.send("progress", p)
+	}
+
+	// The search stops as soon as the client is gone.
+	ctx := r.Context()
+	sets, err := files.FindDuplicates(ctx, d.user.Fs, r.URL.Path, minSize, d, progress)
+	if ctx.Err() != nil {
+		return 0, nil
+	}
+
+	report := &duplicatesReport{Sets: sets}
+	for _, set := range sets {
+		report.Wasted += set.Wasted
+	}
+
+	if !sw.started {
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if format == searchJSON {
+			return renderJSON(w, r, report)
+		}
+	}
+
+	end := &duplicatesEnd{End: true}
+	if err != nil {
+		end.Error = err.Error()
+	} else {
+		end.duplicatesReport = report
+	}
+	if sendErr := sw.send("end", end); sendErr != nil && err == nil {
+		err = sendErr
+	}
+	return 0, err
+})
+
+const (
+	duplicatesDelete   = "delete"
+	duplicatesHardlink = "hardlink"
+)
+
+type duplicatesRequest struct {
+	Action string   `json:"action"`
+	Keep   string   `json:"keep"`
+	Paths  []string `json:"paths"`
+}
+
+// duplicatesResult lists the copies deleted or linked, and the size freed
+// on the disk.
+type duplicatesResult struct {
+	Paths []string `json:"paths"`
+	Freed int64    `json:"freed"`
+}
+
+// statDuplicate returns the info of a regular file of the user.
+func statDuplicate(d *data, name string) (os.FileInfo, int, error) {
+	if !d.Check(name) {
+		return nil, http.StatusForbidden, nil
+	}
+	info, err := d.user.Fs.Stat(name)
+	if err != nil {
+		return nil, errToStatus(err), err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, http.StatusBadRequest, fmt.Errorf("%s is not a regular file", name)
+	}
+	return info, 0, nil
+}
+
+// duplicatesPostHandler deletes the copies of a file, or replaces them
+// with hard links to it. The copies are hashed again first, and none is
+// changed unless all of them are still identical to the file kept.
+func duplicatesPostHandler(fileCache FileCache) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if !d.user.Perm.Delete {
+			return http.StatusForbidden, nil
+		}
+
+		var req duplicatesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return http.StatusBadRequest, err
+		}
+		if req.Action != duplicatesDelete && req.Action != duplicatesHardlink {
+			return http.StatusBadRequest, fberrors.ErrInvalidOption
+		}
+		if len(req.Paths) == 0 {
+			return http.StatusBadRequest, fberrors.ErrEmptyRequest
+		}
+
+		keep := path.Join("/", req.Keep)
+		keepInfo, status, err := statDuplicate(d, keep)
+		if status != 0 {
+			return status, err
+		}
+		hash, err := files.HashFile(d.user.Fs, keep, 0)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		infos := make([]os.FileInfo, len(req.Paths))
+		for i, name := range req.Paths {
+			name = path.Join("/", name)
+			if name == keep || slices.Contains(req.Paths[:i], name) {
+				return http.StatusBadRequest, fmt.Errorf("%s is given twice", name)
+			}
+
+			info, status, err := statDuplicate(d, name)
+			if status != 0 {
+				return status, err
+			}
+			if info.Size() == keepInfo.Size() {
+				h, err := files.HashFile(d.user.Fs, name, 0)
+				if err != nil {
+					return http.StatusInternalServerError, err
+				}
+				if h == hash {
+					req.Paths[i], infos[i] = name, info
+					continue
+				}
+			}
+			return http.StatusConflict, fmt.Errorf("%s is not a copy of %s", name, keep)
+		}
+
+		// The copies linked to each other free their data once, when the
+		// last of them is changed, and the ones linked to the file kept
+		// never do. first is the index of the first copy of each data.
+		first := make([]int, len(infos))
+		left := map[int]int{}
+		for i, info := range infos {
+			first[i] = slices.IndexFunc(infos, func(o os.FileInfo) bool { return os.SameFile(o, info) })
+			if !os.SameFile(keepInfo, info) {
+				left[first[i]]++
+			}
+		}
+
+		res := &duplicatesResult{Paths: []string{}}
+		for i, name := range req.Paths {
+			kept := os.SameFile(keepInfo, infos[i])
+			if kept && req.Action == duplicatesHardlink {
+				continue
+			}
+
+			if err := resolveDuplicate(r, d, fileCache, req.Action, keep, name); err != nil {
+				return errToStatus(err), err
+			}
+
+			res.Paths = append(res.Paths, name)
+			if kept {
+				continue
+			}
+			left[first[i]]--
+			if left[first[i]] == 0 {
+				res.Freed += infos[i].Size()
+			}
+		}
+
+		return renderJSON(w, r, res)
+	})
+}
+
+// resolveDuplicate deletes the copy name of keep, or replaces it with a
+// hard link to keep, as the requests doing it to a file would, with their
+// hooks and audit entries.
+func resolveDuplicate(r *http.Request, d *data, fileCache FileCache, action, keep, name string) error {
+	before, after, auditAction := settings.BeforeDelete, settings.AfterDelete, audit.ActionFileDelete
+	if action == duplicatesHardlink {
+		before, after, auditAction = settings.BeforeSave, settings.AfterSave, audit.ActionFileModify
+	}
+
+	if err := beforeHooks(r.Context(), d, before, name, ""); err != nil {
+		return err
+	}
+
+	file, err := files.NewFileInfo(&files.FileOptions{
+		Fs:      d.user.Fs,
+		Path:    name,
+		Checker: d,
+	})
+	if err != nil {
+		return err
+	}
+	if err := delThumbs(r.Context(), fileCache, file); err != nil {
+		return err
+	}
+
+	if action == duplicatesHardlink {
+		err = files.LinkFile(d.user.Fs, keep, name)
+	} else {
+		if err := d.store.Share.DeleteWithPathPrefix(name); err != nil {
+			log.Printf("WARNING: Error(s) occurred while deleting associated shares with file: %s", err)
+		}
+		err = d.user.Fs.Remove(name)
+		if err == nil {
+			followDelete(d.store, d.user.Scope, name)
+		}
+	}
+
+	status := http.StatusOK
+	if err != nil {
+		status = errToStatus(err)
+	}
+	recordAudit(r, d, &audit.Entry{Action: auditAction, Path: name, Status: status})
+	if err != nil {
+		return err
+	}
+
+	afterHooks(d, after, name, "")
+	return nil
+}
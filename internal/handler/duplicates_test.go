@@ -0,0 +1,70 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/nulnl/nulyun/internal/model/users"
+)
+
+func TestDuplicatesLinkedCopies(t *testing.T) {
+	tests := map[string]struct {
+		action    string
+		wantPaths []string
+	}{
+		"hardlink": {duplicatesHardlink, []string{"/a.txt", "/b.txt", "/c.txt"}},
+		"delete":   {duplicatesDelete, []string{"/a.txt", "/b.txt", "/c.txt", "/k.txt"}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			s := newTestServer(t, nil, &users.User{
+				Username: "alice",
+				Perm:     users.Permissions{Delete: true},
+			})
+			for _, name := range []string{"keep.txt", "a.txt", "c.txt"} {
+				s.writeFile(t, name, "same")
+			}
+			// a.txt and b.txt share their data, but not with keep.txt,
+			// which k.txt already is a link to.
+			for link, target := range map[string]string{"b.txt": "a.txt", "k.txt": "keep.txt"} {
+				if err := os.Link(filepath.Join(s.root, target), filepath.Join(s.root, link)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			body := `{"action":"` + tt.action + `","keep":"/keep.txt","paths":["/a.txt","/b.txt","/c.txt","/k.txt"]}`
+			rec := s.do(t, http.MethodPost, "/api/duplicates", s.token(t, 1), body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("resolve: %d %s", rec.Code, rec.Body)
+			}
+			var res duplicatesResult
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+
+			// The data of a.txt and b.txt is freed once, with the one of c.txt.
+			if !slices.Equal(res.Paths, tt.wantPaths) || res.Freed != 8 {
+				t.Errorf("result = %+v, want %v freeing 8", res, tt.wantPaths)
+			}
+
+			keep, err := os.Stat(filepath.Join(s.root, "keep.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"a.txt", "b.txt", "c.txt", "k.txt"} {
+				info, err := os.Stat(filepath.Join(s.root, name))
+				switch {
+				case tt.action == duplicatesDelete && !os.IsNotExist(err):
+					t.Errorf("%s: %v, want it deleted", name, err)
+				case tt.action == duplicatesHardlink && (err != nil || !os.SameFile(keep, info)):
+					t.Errorf("%s: %v, want it linked to keep.txt", name, err)
+				}
+			}
+		})
+	}
+}
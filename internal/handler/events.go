@@ -0,0 +1,224 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nulnl/nulyun/internal/events"
+	"github.com/nulnl/nulyun/internal/model/audit"
+)
+
+// eventBus broadcasts what happens to the files, shares and users.
+var eventBus = events.NewBus()
+
+// auditEvents are the events published by the audited actions.
+var auditEvents = map[string]string{
+	audit.ActionFileCreate:     events.FileCreated,
+	audit.ActionFileModify:     events.FileModified,
+	audit.ActionFileDelete:     events.FileDeleted,
+	audit.ActionFileMove:       events.FileMoved,
+	audit.ActionFileCopy:       events.FileCreated,
+	audit.ActionShareCreate:    events.ShareCreated,
+	audit.ActionShareAccess:    events.ShareAccessed,
+	audit.ActionFileQuarantine: events.FileQuarantined,
+}
+
+// webdavEvents are the events published by the WebDAV writes.
+var webdavEvents = map[string]string{
+	http.MethodPut:    events.FileModified,
+	"MKCOL":           events.FileCreated,
+	http.MethodDelete: events.FileDeleted,
+	"MOVE":            events.FileMoved,
+	"COPY":            events.FileCreated,
+}
+
+// publishEvent publishes an event of the current user on a path.
+func publishEvent(d *data, typ, name string) {
+	eventBus.Publish(events.Event{
+		Type:     typ,
+		UserID:   d.user.ID,
+		Username: d.user.Username,
+		Path:     name,
+		Scope:    d.user.Scope,
+	})
+}
+
+// publishAudited publishes the event of a successful audited action, whose
+// paths are relative to scope.
+func publishAudited(e *audit.Entry, scope string) {
+	if e.Outcome != audit.OutcomeSuccess {
+		return
+	}
+
+	typ, ok := auditEvents[e.Action]
+	if e.Action == audit.ActionWebDAVWrite {
+		typ, ok = webdavEvents[e.Method]
+	}
+	if !ok {
+		return
+	}
+
+	ev := events.Event{
+		Type:        typ,
+		UserID:      e.UserID,
+		Username:    e.Username,
+		Path:        e.Path,
+		Destination: e.Destination,
+		Scope:       scope,
+	}
+	// A copy is the creation of its destination.
+	if e.Action == audit.ActionFileCopy || (e.Action == audit.ActionWebDAVWrite && e.Method == "COPY") {
+		ev.Path, ev.Destination = e.Destination, ""
+	}
+
+	eventBus.Publish(ev)
+}
+
+const (
+	// notifyBufferSize is the number of events waiting to be sent to a
+	// client before new ones are dropped.
+	notifyBufferSize = 64
+	// notifyHeartbeat is how often an idle stream is kept alive and its
+	// session checked.
+	notifyHeartbeat = 30 * time.Second
+)
+
+// notifyTypes are the events changing the content of directories.
+var notifyTypes = []string{
+	events.FileCreated, events.FileModified, events.FileDeleted, events.FileMoved, events.UploadCompleted,
+	events.FileQuarantined,
+}
+
+// changeNotification tells a client the content of directories changed.
+// Paths are relative to the scope of the client.
+type changeNotification struct {
+	Type        string   `json:"type"`
+	Time        int64    `json:"time"`
+	Path        string   `json:"path,omitempty"`
+	Destination string   `json:"destination,omitempty"`
+	Dirs        []string `json:"dirs"`
+}
+
+// userPath returns the path of an event relative to the scope of the user,
+// if the user can see it.
+func userPath(d *data, scope, name string) (string, bool) {
+	if name == "" {
+		return "", false
+	}
+
+	name = path.Join("/", scope, name)
+	base := path.Join("/", d.user.Scope)
+	switch {
+	case base == "/":
+	case name == base:
+		name = "/"
+	case strings.HasPrefix(name, base+"/"):
+		name = strings.TrimPrefix(name, base)
+	default:
+		return "", false
+	}
+
+	// A hidden directory hides its content.
+	for p := name; p != "/"; p = path.Dir(p) {
+		if !d.Check(p) {
+			return "", false
+		}
+	}
+
+	return name, true
+}
+
+// notification returns the notification of an event for the user, if the
+// user can see any of its paths.
+func notification(d *data, e *events.Event) (*changeNotification, bool) {
+	if !slices.Contains(notifyTypes, e.Type) {
+		return nil, false
+	}
+
+	n := &changeNotification{Type: e.Type, Time: e.Time, Dirs: []string{}}
+	if p, ok := userPath(d, e.Scope, e.Path); ok {
+		n.Path = p
+		n.Dirs = append(n.Dirs, path.Dir(p))
+	}
+	if p, ok := userPath(d, e.Scope, e.Destination); ok {
+		n.Destination = p
+		if dir := path.Dir(p); !slices.Contains(n.Dirs, dir) {
+			n.Dirs = append(n.Dirs, dir)
+		}
+	}
+
+	return n, len(n.Dirs) > 0
+}
+
+// streamValid checks the session of a stream opened at since was neither
+// revoked nor expired, and the user not updated, which could change what
+// the user can see. The client reconnects to get a valid stream.
+func streamValid(d *data, since int64) bool {
+	if d.store.Users.LastUpdate(d.user.ID) > since {
+		return false
+	}
+	if d.session == nil {
+		return true
+	}
+
+	sess, err := d.store.Sessions.Get(d.session.ID)
+	return err == nil && !sess.Revoked && (sess.ExpiresAt == 0 || sess.ExpiresAt > time.Now().Unix())
+}
+
+// eventsHandler streams the changes of the files visible to the user as
+// Server-Sent Events.
+var eventsHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	rc := http.NewResponseController(w)
+	// The stream outlives the write timeout of the server, if any.
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	since := time.Now().Unix()
+	ch, unsubscribe := eventBus.Subscribe(notifyBufferSize)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 5000\n\n")
+	if err := rc.Flush(); err != nil {
+		return 0, err
+	}
+
+	ticker := time.NewTicker(notifyHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return 0, nil
+		case <-ticker.C:
+			if !streamValid(d, since) {
+				return 0, nil
+			}
+			fmt.Fprint(w, ": ping\n\n")
+		case e, ok := <-ch:
+			if !ok {
+				return 0, nil
+			}
+			n, ok := notification(d, &e)
+			if !ok {
+				continue
+			}
+			payload, err := json.Marshal(n)
+			if err != nil {
+				return 0, err
+			}
+			fmt.Fprintf(w, "id: %s\nevent: change\ndata: %s\n\n", e.ID, payload)
+		}
+
+		if err := rc.Flush(); err != nil {
+			return 0, nil
+		}
+	}
+})
@@ -0,0 +1,74 @@
+package fbhttp
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/users"
+	"github.com/nulnl/nulyun/internal/model/webdav"
+	storage "github.com/nulnl/nulyun/internal/repository"
+	"github.com/nulnl/nulyun/internal/runner"
+)
+
+// webdavHooks are the hook events of the WebDAV writes, before and after.
+var webdavHooks = map[string][2]string{
+	http.MethodPut:    {settings.BeforeUpload, settings.AfterUpload},
+	http.MethodDelete: {settings.BeforeDelete, settings.AfterDelete},
+	"MOVE":            {settings.BeforeRename, settings.AfterRename},
+	"COPY":            {settings.BeforeCopy, settings.AfterCopy},
+}
+
+// hookOperation describes an operation of u on name for the hooks.
+func hookOperation(root string, u *users.User, event, name, dst string) *runner.Operation {
+	return &runner.Operation{
+		Event:       event,
+		UserID:      u.ID,
+		Username:    u.Username,
+		Scope:       filepath.Join(root, filepath.Join("/", u.Scope)),
+		Path:        name,
+		Destination: dst,
+	}
+}
+
+// beforeHooks runs the hooks of event on an operation of the current user,
+// returning the error vetoing it if any.
+func beforeHooks(ctx context.Context, d *data, event, name, dst string) error {
+	return runner.RunBefore(ctx, d.settings, hookOperation(d.server.Root, d.user, event, name, dst))
+}
+
+// afterHooks starts the hooks of event on an operation of the current user.
+func afterHooks(d *data, event, name, dst string) {
+	runner.RunAfter(d.settings, hookOperation(d.server.Root, d.user, event, name, dst))
+}
+
+// webdavGuard runs the before hooks of the WebDAV writes.
+func webdavGuard(store *storage.Storage, server *settings.Server) webdav.Guard {
+	return func(r *http.Request, u *users.User, name, dst string) error {
+		events, ok := webdavHooks[r.Method]
+		if !ok {
+			return nil
+		}
+		set, err := store.Settings.Get()
+		if err != nil {
+			return err
+		}
+
+		return runner.RunBefore(r.Context(), set, hookOperation(server.Root, u, events[0], name, dst))
+	}
+}
+
+// webdavAfterHooks starts the after hooks of a successful WebDAV write.
+func webdavAfterHooks(store *storage.Storage, server *settings.Server, r *http.Request, u *users.User, name, dst string) {
+	events, ok := webdavHooks[r.Method]
+	if !ok {
+		return
+	}
+	set, err := store.Settings.Get()
+	if err != nil {
+		return
+	}
+
+	runner.RunAfter(set, hookOperation(server.Root, u, events[1], name, dst))
+}
@@ -1,15 +1,22 @@
 package fbhttp
 
 import (
+	"context"
 	"io/fs"
+	"log"
 	"net/http"
 	"strings"
 
 	"github.com/gorilla/mux"
 
+	"github.com/nulnl/nulyun/internal/clamd"
+	"github.com/nulnl/nulyun/internal/events"
+	"github.com/nulnl/nulyun/internal/files"
+	"github.com/nulnl/nulyun/internal/model/audit"
 	settings "github.com/nulnl/nulyun/internal/model/global"
 	"github.com/nulnl/nulyun/internal/model/webdav"
 	storage "github.com/nulnl/nulyun/internal/repository"
+	"github.com/nulnl/nulyun/internal/service"
 )
 
 type modifyRequest struct {
@@ -44,6 +51,42 @@ func NewHandler(
 		return handle(fn, prefix, store, server)
 	}
 
+	webhooks := service.NewWebhooks(store)
+	hookEvents, _ := eventBus.Subscribe(eventBufferSize)
+	go webhooks.Run(hookEvents)
+
+	if server.Clamd != "" {
+		client, err := clamd.New(server.Clamd)
+		if err != nil {
+			return nil, err
+		}
+		antivirus = service.NewAntivirus(client, server.QuarantineDir)
+		go func() {
+			if err := client.Ping(context.Background()); err != nil {
+				log.Printf("clamd at %s is not answering, the uploads can't be scanned: %v", server.Clamd, err)
+			}
+		}()
+	}
+
+	if server.ContentMaxSize > 0 {
+		files.ContentMaxSize = server.ContentMaxSize
+	}
+	if server.SearchIndex != "" {
+		if err := startSearchIndex(server); err != nil {
+			return nil, err
+		}
+	}
+
+	if server.WatchFiles {
+		watched, _ := eventBus.Subscribe(eventBufferSize)
+		go followWatched(store, server.Root, watched)
+		go func() {
+			if err := events.Watch(context.Background(), server.Root, eventBus); err != nil {
+				log.Printf("failed to watch %s for changes: %v", server.Root, err)
+			}
+		}()
+	}
+
 	r.HandleFunc("/health", healthHandler)
 	r.PathPrefix("/static").Handler(static)
 	r.NotFoundHandler = index
@@ -54,29 +97,43 @@ func NewHandler(
 	api.Handle("/login", monkey(loginHandler(tokenExpirationTime, totpExpTime), ""))
 	api.Handle("/login/otp", monkey(verifyTOTPHandler(tokenExpirationTime), ""))
 	api.Handle("/signup", monkey(signupHandler, ""))
-	api.Handle("/renew", monkey(renewHandler(tokenExpirationTime), ""))
+	api.Handle("/signup/verify", monkey(signupVerifyHandler, "")).Methods("GET")
+	api.Handle("/renew", monkey(withEnrollment(withSessionOnly(renewHandler(tokenExpirationTime))), ""))
+	api.Handle("/logout", monkey(withEnrollment(withSessionOnly(logoutHandler)), "")).Methods("POST")
+
+	api.Handle("/sessions", monkey(sessionListHandler, "")).Methods("GET")
+	api.Handle("/sessions/{id}", monkey(sessionDeleteHandler, "")).Methods("DELETE")
+
+	api.Handle("/invites", monkey(inviteListHandler, "")).Methods("GET")
+	api.Handle("/invites", monkey(invitePostHandler, "")).Methods("POST")
+	api.Handle("/invites/{code}", monkey(inviteDeleteHandler, "")).Methods("DELETE")
 
 	users := api.PathPrefix("/users").Subrouter()
 	users.Handle("", monkey(usersGetHandler, "")).Methods("GET")
-	users.Handle("", monkey(userPostHandler, "")).Methods("POST")
-	users.Handle("/{id:[0-9]+}", monkey(userPutHandler, "")).Methods("PUT")
-	users.Handle("/{id:[0-9]+}", monkey(userGetHandler, "")).Methods("GET")
-	users.Handle("/{id:[0-9]+}", monkey(userDeleteHandler, "")).Methods("DELETE")
-	users.Handle("/{id:[0-9]+}/otp", monkey(userEnableTOTPHandler, "")).Methods("POST")
-	users.Handle("/{id:[0-9]+}/otp", monkey(userGetTOTPHandler, "")).Methods("GET")
-	users.Handle("/{id:[0-9]+}/otp/check", monkey(userCheckTOTPHandler, "")).Methods("POST")
+	users.Handle("", monkey(withAudit(audit.ActionUserCreate, userPostHandler), "")).Methods("POST")
+	users.Handle("/2fa", monkey(twoFactorReportHandler, "")).Methods("GET")
+	users.Handle("/{id:[0-9]+}", monkey(withAudit(audit.ActionUserUpdate, userPutHandler), "")).Methods("PUT")
+	users.Handle("/{id:[0-9]+}", monkey(withEnrollment(userGetHandler), "")).Methods("GET")
+	users.Handle("/{id:[0-9]+}", monkey(withAudit(audit.ActionUserDelete, userDeleteHandler), "")).Methods("DELETE")
+	users.Handle("/{id:[0-9]+}/sessions", monkey(userSessionsDeleteHandler, "")).Methods("DELETE")
+	users.Handle("/{id:[0-9]+}/unlock", monkey(userUnlockHandler, "")).Methods("POST")
+	users.Handle("/{id:[0-9]+}/approve", monkey(userApproveHandler, "")).Methods("POST")
+	users.Handle("/{id:[0-9]+}/impersonate", monkey(withAudit(audit.ActionUserImpersonate, impersonateHandler(tokenExpirationTime)), "")).Methods("POST")
+	users.Handle("/{id:[0-9]+}/otp", monkey(withEnrollment(userEnableTOTPHandler), "")).Methods("POST")
+	users.Handle("/{id:[0-9]+}/otp", monkey(withEnrollment(userGetTOTPHandler), "")).Methods("GET")
+	users.Handle("/{id:[0-9]+}/otp/check", monkey(withEnrollment(userCheckTOTPHandler), "")).Methods("POST")
 	users.Handle("/{id:[0-9]+}/otp", monkey(userDisableTOTPHandler, "")).Methods("DELETE")
-	users.Handle("/{id:[0-9]+}/otp/reset", monkey(userResetTOTPHandler, "")).Methods("POST")
-	users.Handle("/{id:[0-9]+}/otp/recovery", monkey(userGenerateRecoveryCodesHandler, "")).Methods("POST")
+	users.Handle("/{id:[0-9]+}/otp/reset", monkey(withEnrollment(userResetTOTPHandler), "")).Methods("POST")
+	users.Handle("/{id:[0-9]+}/otp/recovery", monkey(withEnrollment(userGenerateRecoveryCodesHandler), "")).Methods("POST")
 	users.Handle("/{id:[0-9]+}/otp/toggle", monkey(userToggleTOTPHandler, "")).Methods("PUT")
 
 	api.PathPrefix("/resources").Handler(monkey(resourceGetHandler, "/api/resources")).Methods("GET")
-	api.PathPrefix("/resources").Handler(monkey(resourceDeleteHandler(fileCache), "/api/resources")).Methods("DELETE")
-	api.PathPrefix("/resources").Handler(monkey(resourcePostHandler(fileCache), "/api/resources")).Methods("POST")
-	api.PathPrefix("/resources").Handler(monkey(resourcePutHandler, "/api/resources")).Methods("PUT")
-	api.PathPrefix("/resources").Handler(monkey(resourcePatchHandler(fileCache), "/api/resources")).Methods("PATCH")
+	api.PathPrefix("/resources").Handler(monkey(withAudit(audit.ActionFileDelete, resourceDeleteHandler(fileCache)), "/api/resources")).Methods("DELETE")
+	api.PathPrefix("/resources").Handler(monkey(withAudit(audit.ActionFileCreate, resourcePostHandler(fileCache)), "/api/resources")).Methods("POST")
+	api.PathPrefix("/resources").Handler(monkey(withAudit(audit.ActionFileModify, resourcePutHandler), "/api/resources")).Methods("PUT")
+	api.PathPrefix("/resources").Handler(monkey(withAudit(audit.ActionFileMove, resourcePatchHandler(fileCache)), "/api/resources")).Methods("PATCH")
 
-	api.PathPrefix("/tus").Handler(monkey(tusPostHandler(), "/api/tus")).Methods("POST")
+	api.PathPrefix("/tus").Handler(monkey(withAudit(audit.ActionFileCreate, tusPostHandler()), "/api/tus")).Methods("POST")
 	api.PathPrefix("/tus").Handler(monkey(tusHeadHandler(), "/api/tus")).Methods("HEAD", "GET")
 	api.PathPrefix("/tus").Handler(monkey(tusPatchHandler(), "/api/tus")).Methods("PATCH")
 	api.PathPrefix("/tus").Handler(monkey(tusDeleteHandler(), "/api/tus")).Methods("DELETE")
@@ -85,21 +142,58 @@ func NewHandler(
 
 	api.Path("/shares").Handler(monkey(shareListHandler, "/api/shares")).Methods("GET")
 	api.PathPrefix("/share").Handler(monkey(shareGetsHandler, "/api/share")).Methods("GET")
-	api.PathPrefix("/share").Handler(monkey(sharePostHandler, "/api/share")).Methods("POST")
-	api.PathPrefix("/share").Handler(monkey(shareDeleteHandler, "/api/share")).Methods("DELETE")
+	api.PathPrefix("/share").Handler(monkey(withAudit(audit.ActionShareCreate, sharePostHandler), "/api/share")).Methods("POST")
+	api.PathPrefix("/share").Handler(monkey(withAudit(audit.ActionShareDelete, shareDeleteHandler), "/api/share")).Methods("DELETE")
+
+	api.Handle("/audit", monkey(auditGetHandler, "")).Methods("GET")
+
+	api.Handle("/events", monkey(eventsHandler, "")).Methods("GET")
+
+	api.Handle("/webhooks", monkey(webhookListHandler, "")).Methods("GET")
+	api.Handle("/webhooks", monkey(webhookPostHandler, "")).Methods("POST")
+	api.Handle("/webhooks/{id:[0-9]+}", monkey(webhookPutHandler, "")).Methods("PUT")
+	api.Handle("/webhooks/{id:[0-9]+}", monkey(webhookDeleteHandler, "")).Methods("DELETE")
+	api.Handle("/webhooks/{id:[0-9]+}/deliveries", monkey(webhookDeliveriesHandler, "")).Methods("GET")
+	api.Handle("/webhooks/{id:[0-9]+}/ping", monkey(webhookPingHandler(webhooks), "")).Methods("POST")
+
+	api.Handle("/albums", monkey(albumListHandler, "")).Methods("GET")
+	api.Handle("/albums", monkey(albumPostHandler, "")).Methods("POST")
+	api.Handle("/albums/{id:[0-9]+}", monkey(albumGetHandler, "")).Methods("GET")
+	api.Handle("/albums/{id:[0-9]+}", monkey(albumPutHandler, "")).Methods("PUT")
+	api.Handle("/albums/{id:[0-9]+}", monkey(albumDeleteHandler, "")).Methods("DELETE")
+	api.Handle("/albums/{id:[0-9]+}/share", monkey(albumShareHandler, "")).Methods("POST")
+
+	api.Handle("/tags", monkey(tagsGetHandler, "")).Methods("GET")
+	api.Handle("/tags", monkey(tagsPutHandler, "")).Methods("PUT")
+
+	api.Handle("/comments/{id:[0-9]+}", monkey(commentPutHandler, "")).Methods("PUT")
+	api.Handle("/comments/{id:[0-9]+}", monkey(commentDeleteHandler, "")).Methods("DELETE")
+	api.PathPrefix("/comments").Handler(monkey(commentsGetHandler, "/api/comments")).Methods("GET")
+	api.PathPrefix("/comments").Handler(monkey(commentPostHandler, "/api/comments")).Methods("POST")
+
+	api.Handle("/recent", monkey(recentHandler, "")).Methods("GET")
+	api.Handle("/activity", monkey(activityHandler, "")).Methods("GET")
 
 	api.Handle("/settings", monkey(settingsGetHandler, "")).Methods("GET")
-	api.Handle("/settings", monkey(settingsPutHandler, "")).Methods("PUT")
+	api.Handle("/settings", monkey(withAudit(audit.ActionSettingsUpdate, settingsPutHandler), "")).Methods("PUT")
 
-	api.PathPrefix("/raw").Handler(monkey(rawHandler, "/api/raw")).Methods("GET")
+	api.PathPrefix("/raw").Handler(monkey(withAudit(audit.ActionFileDownload, rawHandler), "/api/raw")).Methods("GET")
 	api.PathPrefix("/preview/{size}/{path:.*}").
 		Handler(monkey(previewHandler(imgSvc, fileCache, server.EnableThumbnails, server.ResizePreview), "/api/preview")).Methods("GET")
 	api.PathPrefix("/search").Handler(monkey(searchHandler, "/api/search")).Methods("GET")
+	api.PathPrefix("/photos").Handler(monkey(photosHandler, "/api/photos")).Methods("GET")
+	api.Handle("/duplicates", monkey(duplicatesPostHandler(fileCache), "")).Methods("POST")
+	api.PathPrefix("/duplicates").Handler(monkey(duplicatesGetHandler, "/api/duplicates")).Methods("GET")
+	api.PathPrefix("/command").Handler(monkey(commandsHandler, "/api/command")).Methods("GET")
 	api.PathPrefix("/subtitle").Handler(monkey(subtitleHandler, "/api/subtitle")).Methods("GET")
 
 	public := api.PathPrefix("/public").Subrouter()
 	public.PathPrefix("/dl").Handler(monkey(publicDlHandler, "/api/public/dl/")).Methods("GET")
 	public.PathPrefix("/share").Handler(monkey(publicShareHandler, "/api/public/share/")).Methods("GET")
+	public.Handle("/album/{hash}", monkey(publicAlbumHandler, "")).Methods("GET")
+	public.Handle("/album/{hash}/preview/{size}/{path:.*}",
+		monkey(publicAlbumPreviewHandler(imgSvc, fileCache, server.EnableThumbnails, server.ResizePreview), "")).Methods("GET")
+	public.Handle("/album/{hash}/dl/{path:.*}", monkey(publicAlbumDlHandler, "")).Methods("GET")
 
 	// WebDAV routes
 	setupWebDAVRoutes(api, store, server)
@@ -112,6 +206,8 @@ func NewHandler(
 		if strings.HasPrefix(req.URL.Path, davPath) {
 			// Handle WebDAV directly without stripPrefix
 			webdavHandler := webdav.NewHandler(store.WebDAV, store.Users, server)
+			webdavHandler.Guard = webdavGuard(store, server)
+			webdavHandler.Observer = webdavObserver(store, server)
 			webdavHandler.ServeHTTP(w, req)
 			return
 		}
@@ -0,0 +1,99 @@
+package fbhttp
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/tomasen/realip"
+
+	"github.com/nulnl/nulyun/internal/model/session"
+	"github.com/nulnl/nulyun/internal/model/users"
+)
+
+// actorClaim identifies the admin acting on behalf of the subject of a
+// token, like the act claim of RFC 8693.
+type actorClaim struct {
+	Subject string `json:"sub"`
+	ID      uint   `json:"id"`
+}
+
+// withSessionOnly marks a handler as only managing the current session, so
+// it stays reachable while impersonating in read-only mode.
+func withSessionOnly(fn handleFunc) handleFunc {
+	return func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		d.sessionOnly = true
+		return fn(w, r, d)
+	}
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// serveImpersonated runs a handler for a session opened by an admin on
+// behalf of d.user. The admin must still be an active admin, and every
+// request is logged with its identity.
+func serveImpersonated(fn handleFunc, w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	actor, err := d.store.Users.Get(d.server.Root, d.session.ActorID)
+	if err != nil || !actor.Perm.Admin || actor.Status() != users.StatusActive {
+		return http.StatusUnauthorized, nil
+	}
+
+	d.actor = actor
+	w.Header().Set("X-Impersonated-By", actor.Username)
+
+	status := http.StatusForbidden
+	if !d.settings.ImpersonationReadOnly || d.sessionOnly || isSafeMethod(r.Method) {
+		status, err = fn(w, r, d)
+	}
+
+	logged := status
+	if logged == 0 {
+		logged = http.StatusOK
+	}
+	log.Printf("impersonation: admin=%q user=%q %s %s status=%d",
+		actor.Username, d.user.Username, r.Method, r.URL.Path, logged)
+
+	return status, err
+}
+
+// impersonateHandler lets an admin sign in as another user. The token is
+// bound to a new session of that user that remembers the admin, and carries
+// it in its act claim.
+func impersonateHandler(tokenExpirationTime time.Duration) handleFunc {
+	return withAdmin(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		if d.actor != nil {
+			return http.StatusForbidden, nil
+		}
+
+		id, err := getUserID(r)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		u, err := d.store.Users.Get(d.server.Root, id)
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		if u.ID == d.user.ID || u.Perm.Admin {
+			return http.StatusForbidden, nil
+		}
+
+		if status := u.Status(); status != users.StatusActive {
+			w.Header().Set("X-Account-Status", status)
+			return http.StatusForbidden, nil
+		}
+
+		sess, err := session.New(u.ID, r.UserAgent(), realip.FromRequest(r), tokenExpirationTime)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		sess.ActorID = d.user.ID
+		sess.Actor = d.user.Username
+		d.session = sess
+
+		return printToken(w, r, d, u, tokenExpirationTime)
+	})
+}
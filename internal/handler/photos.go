@@ -0,0 +1,244 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/nulnl/nulyun/internal/files"
+	"github.com/nulnl/nulyun/internal/model/album"
+	"github.com/nulnl/nulyun/internal/model/audit"
+	"github.com/nulnl/nulyun/internal/model/share"
+)
+
+// parseBucket returns the year and the month of the photos of a timeline,
+// 0 for all of them.
+func parseBucket(r *http.Request) (year, month int, err error) {
+	for _, p := range []struct {
+		name   string
+		v      *int
+		lo, hi int
+	}{
+		{"year", &year, 1, 9999},
+		{"month", &month, 1, 12},
+	} {
+		s := r.URL.Query().Get(p.name)
+		if s == "" {
+			continue
+		}
+		*p.v, err = strconv.Atoi(s)
+		if err != nil || *p.v < p.lo || *p.v > p.hi {
+			return 0, 0, fmt.Errorf("invalid %s %q", p.name, s)
+		}
+	}
+	if month != 0 && year == 0 {
+		return 0, 0, fmt.Errorf("month %d without a year", month)
+	}
+	return year, month, nil
+}
+
+// photosHandler returns the timeline of the photos of a directory.
+var photosHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	year, month, err := parseBucket(r)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if _, err := d.user.Fs.Stat(r.URL.Path); err != nil {
+		return errToStatus(err), err
+	}
+
+	var photos []*files.Photo
+	ctx := r.Context()
+	if searchIndex != nil && searchIndex.Fresh() {
+		photos, err = files.PhotosIndex(ctx, searchIndex, d.user.Scope, r.URL.Path, d)
+	} else {
+		photos, err = files.Photos(ctx, d.user.Fs, r.URL.Path, d)
+	}
+	if ctx.Err() != nil {
+		return 0, nil
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, files.NewTimeline(photos, year, month))
+})
+
+type albumRequest struct {
+	Name *string `json:"name"`
+	// Paths replace the photos of the album, when set.
+	Paths  []string `json:"paths"`
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+// apply copies the request to an album. The added paths must be photos
+// the user can see.
+func (req *albumRequest) apply(d *data, a *album.Album) error {
+	for _, p := range append(req.Paths, req.Add...) {
+		p = path.Join("/", p)
+		info, err := d.user.Fs.Stat(p)
+		if err != nil || !d.Check(p) || info.IsDir() || files.TypeByExtension(p) != "image" {
+			return fmt.Errorf("%s is not a photo", p)
+		}
+	}
+
+	if req.Name != nil {
+		a.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Paths != nil {
+		a.Paths = []string{}
+		a.Add(req.Paths...)
+	}
+	a.Add(req.Add...)
+	a.Remove(req.Remove...)
+	a.UpdatedAt = time.Now().Unix()
+
+	return a.Validate()
+}
+
+// albumWithPhotos is an album with its photos. The photos which were
+// removed or can't be seen anymore are left out.
+type albumWithPhotos struct {
+	*album.Album
+	Photos []*files.Photo `json:"photos"`
+}
+
+func newAlbumWithPhotos(d *data, a *album.Album) *albumWithPhotos {
+	res := &albumWithPhotos{Album: a, Photos: []*files.Photo{}}
+	for _, p := range a.Paths {
+		if !d.Check(p) {
+			continue
+		}
+		if photo, err := files.ReadPhoto(d.user.Fs, p); err == nil {
+			res.Photos = append(res.Photos, photo)
+		}
+	}
+	return res
+}
+
+// withAlbum loads the album of the request, which must belong to the user
+// as its paths are relative to the scope of its owner.
+func withAlbum(fn handleFunc) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 0)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		a, err := d.store.Albums.Get(uint(id))
+		if err != nil {
+			return errToStatus(err), err
+		}
+		if a.UserID != d.user.ID {
+			return http.StatusNotFound, nil
+		}
+
+		d.raw = a
+		return fn(w, r, d)
+	})
+}
+
+var albumListHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	albums, err := d.store.Albums.FindByUserID(d.user.ID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, albums)
+})
+
+var albumPostHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	var req albumRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	var name string
+	if req.Name != nil {
+		name = *req.Name
+	}
+	a, err := album.New(d.user.ID, name)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err := req.apply(d, a); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if err := d.store.Albums.Save(a); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, a)
+})
+
+var albumGetHandler = withAlbum(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	return renderJSON(w, r, newAlbumWithPhotos(d, d.raw.(*album.Album)))
+})
+
+var albumPutHandler = withAlbum(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	a := d.raw.(*album.Album)
+
+	var req albumRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err := req.apply(d, a); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if err := d.store.Albums.Save(a); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, a)
+})
+
+var albumDeleteHandler = withAlbum(func(_ http.ResponseWriter, _ *http.Request, d *data) (int, error) {
+	err := d.store.Albums.Delete(d.raw.(*album.Album).ID)
+	return errToStatus(err), err
+})
+
+// albumShareHandler creates a share link of an album, as sharePostHandler
+// does for the files.
+var albumShareHandler = withAlbum(func(w http.ResponseWriter, r *http.Request, d *data) (status int, err error) {
+	if !d.user.Perm.Share {
+		return http.StatusForbidden, nil
+	}
+	a := d.raw.(*album.Album)
+
+	defer func() {
+		recordAudit(r, d, &audit.Entry{
+			Action: audit.ActionShareCreate,
+			Status: max(status, http.StatusOK),
+		})
+	}()
+
+	var body share.CreateBody
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return http.StatusBadRequest, fmt.Errorf("failed to decode body: %w", err)
+		}
+		defer r.Body.Close()
+	}
+
+	s, status, err := newShareLink(d, body)
+	if err != nil {
+		return status, err
+	}
+	s.Album = a.ID
+
+	if err := d.store.Share.Save(s); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, s)
+})
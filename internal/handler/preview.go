@@ -39,33 +39,45 @@ func previewHandler(imgSvc ImgService, fileCache FileCache, enableThumbnails, re
 			return http.StatusAccepted, nil
 		}
 		vars := mux.Vars(r)
+		return servePreview(w, r, d, imgSvc, fileCache, vars["size"], "/"+vars["path"], enableThumbnails, resizePreview)
+	})
+}
 
-		previewSize, err := ParsePreviewSize(vars["size"])
-		if err != nil {
-			return http.StatusBadRequest, err
-		}
+// servePreview writes the preview of the file name of the user at size.
+func servePreview(
+	w http.ResponseWriter,
+	r *http.Request,
+	d *data,
+	imgSvc ImgService,
+	fileCache FileCache,
+	size, name string,
+	enableThumbnails, resizePreview bool,
+) (int, error) {
+	previewSize, err := ParsePreviewSize(size)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
 
-		file, err := files.NewFileInfo(&files.FileOptions{
-			Fs:         d.user.Fs,
-			Path:       "/" + vars["path"],
-			Modify:     d.user.Perm.Modify,
-			Expand:     true,
-			ReadHeader: d.server.TypeDetectionByHeader,
-			Checker:    d,
-		})
-		if err != nil {
-			return errToStatus(err), err
-		}
+	file, err := files.NewFileInfo(&files.FileOptions{
+		Fs:         d.user.Fs,
+		Path:       name,
+		Modify:     d.user.Perm.Modify,
+		Expand:     true,
+		ReadHeader: d.server.TypeDetectionByHeader,
+		Checker:    d,
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
 
-		setContentDisposition(w, r, file)
+	setContentDisposition(w, r, file)
 
-		switch file.Type {
-		case "image":
-			return handleImagePreview(w, r, imgSvc, fileCache, file, previewSize, enableThumbnails, resizePreview)
-		default:
-			return http.StatusNotImplemented, fmt.Errorf("can't create preview for %s type", file.Type)
-		}
-	})
+	switch file.Type {
+	case "image":
+		return handleImagePreview(w, r, imgSvc, fileCache, file, previewSize, enableThumbnails, resizePreview)
+	default:
+		return http.StatusNotImplemented, fmt.Errorf("can't create preview for %s type", file.Type)
+	}
 }
 
 func handleImagePreview(
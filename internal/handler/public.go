@@ -2,28 +2,47 @@ package fbhttp
 
 import (
 	"errors"
+	"log"
 	"net/http"
 	"net/url"
 	"path"
 	"path/filepath"
 	"strings"
 
+	"github.com/gorilla/mux"
 	"github.com/spf13/afero"
+	"github.com/tomasen/realip"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/nulnl/nulyun/internal/files"
+	"github.com/nulnl/nulyun/internal/model/album"
+	"github.com/nulnl/nulyun/internal/model/audit"
 	"github.com/nulnl/nulyun/internal/model/share"
 )
 
 var withHashFile = func(fn handleFunc) handleFunc {
-	return func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	return func(w http.ResponseWriter, r *http.Request, d *data) (status int, err error) {
 		id, ifPath := ifPathWithName(r)
 		link, err := d.store.Share.GetByHash(id)
 		if err != nil {
 			return errToStatus(err), err
 		}
+		// The albums are only served by the album routes.
+		if link.Album != 0 {
+			return http.StatusNotFound, nil
+		}
+
+		// The visitor is anonymous, the entry belongs to the owner.
+		defer func() {
+			recordAudit(r, d, &audit.Entry{
+				Action: audit.ActionShareAccess,
+				UserID: link.UserID,
+				Path:   path.Join(link.Path, ifPath),
+				Status: max(status, http.StatusOK),
+			})
+		}()
 
-		status, err := authenticateShareRequest(r, link)
+		status, err = authenticateShareRequest(w, r, d, link)
 		if status != 0 || err != nil {
 			return status, err
 		}
@@ -121,12 +140,108 @@ var publicDlHandler = withHashFile(func(w http.ResponseWriter, r *http.Request,
 	return rawDirHandler(w, r, d, file)
 })
 
-func authenticateShareRequest(r *http.Request, l *share.Link) (int, error) {
+// withHashAlbum loads the shared album of the request, and the file of the
+// album at path, if any, for its visitor.
+func withHashAlbum(fn handleFunc) handleFunc {
+	return func(w http.ResponseWriter, r *http.Request, d *data) (status int, err error) {
+		vars := mux.Vars(r)
+		link, err := d.store.Share.GetByHash(vars["hash"])
+		if err != nil {
+			return errToStatus(err), err
+		}
+		if link.Album == 0 {
+			return http.StatusNotFound, nil
+		}
+
+		name := ""
+		if vars["path"] != "" {
+			name = path.Join("/", vars["path"])
+		}
+
+		defer func() {
+			recordAudit(r, d, &audit.Entry{
+				Action: audit.ActionShareAccess,
+				UserID: link.UserID,
+				Path:   name,
+				Status: max(status, http.StatusOK),
+			})
+		}()
+
+		status, err = authenticateShareRequest(w, r, d, link)
+		if status != 0 || err != nil {
+			return status, err
+		}
+
+		a, err := d.store.Albums.Get(link.Album)
+		if err != nil {
+			return errToStatus(err), err
+		}
+		if name != "" && !a.Contains(name) {
+			return http.StatusNotFound, nil
+		}
+
+		user, err := d.store.Users.Get(d.server.Root, link.UserID)
+		if err != nil {
+			return errToStatus(err), err
+		}
+
+		d.user = user
+		d.raw = &publicAlbum{Name: a.Name, Token: link.Token, album: a, path: name}
+		return fn(w, r, d)
+	}
+}
+
+// publicAlbum is a shared album as seen by its visitors.
+type publicAlbum struct {
+	Name   string         `json:"name"`
+	Token  string         `json:"token,omitempty"`
+	Photos []*files.Photo `json:"photos"`
+
+	album *album.Album
+	// path is the photo requested, if any.
+	path string
+}
+
+var publicAlbumHandler = withHashAlbum(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	res := d.raw.(*publicAlbum)
+	res.Photos = newAlbumWithPhotos(d, res.album).Photos
+	return renderJSON(w, r, res)
+})
+
+func publicAlbumPreviewHandler(imgSvc ImgService, fileCache FileCache, enableThumbnails, resizePreview bool) handleFunc {
+	return withHashAlbum(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		res := d.raw.(*publicAlbum)
+		return servePreview(w, r, d, imgSvc, fileCache, mux.Vars(r)["size"], res.path, enableThumbnails, resizePreview)
+	})
+}
+
+var publicAlbumDlHandler = withHashAlbum(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	file, err := files.NewFileInfo(&files.FileOptions{
+		Fs:         d.user.Fs,
+		Path:       d.raw.(*publicAlbum).path,
+		Expand:     false,
+		ReadHeader: d.server.TypeDetectionByHeader,
+		Checker:    d,
+	})
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	return rawFileHandler(w, r, file)
+})
+
+func authenticateShareRequest(w http.ResponseWriter, r *http.Request, d *data, l *share.Link) (int, error) {
 	if l.PasswordHash == "" {
 		return 0, nil
 	}
 
-	if r.URL.Query().Get("token") == l.Token {
+	ip := realip.FromRequest(r)
+	if wait := authLimiter.Check(*d.settings.BruteForce, "ip:"+ip, "share:"+l.Hash); wait > 0 {
+		return tooManyAttempts(w, wait)
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == l.Token {
 		return 0, nil
 	}
 
@@ -136,18 +251,28 @@ func authenticateShareRequest(r *http.Request, l *share.Link) (int, error) {
 		return 0, err
 	}
 	if password == "" {
+		if token != "" {
+			shareAuthFailure(d, ip, l.Hash)
+		}
 		return http.StatusUnauthorized, nil
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(l.PasswordHash), []byte(password)); err != nil {
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			shareAuthFailure(d, ip, l.Hash)
 			return http.StatusUnauthorized, nil
 		}
 		return 0, err
 	}
 
+	authLimiter.Reset("share:" + l.Hash)
 	return 0, nil
 }
 
+func shareAuthFailure(d *data, ip, hash string) {
+	log.Printf("authentication failure: type=share ip=%s share=%q", ip, hash)
+	authLimiter.Fail(*d.settings.BruteForce, "ip:"+ip, "share:"+hash)
+}
+
 func healthHandler(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	_, _ = w.Write([]byte(`{"status":"OK"}`))
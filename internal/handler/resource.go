@@ -17,6 +17,7 @@ import (
 	"github.com/spf13/afero"
 
 	"github.com/nulnl/nulyun/internal/files"
+	settings "github.com/nulnl/nulyun/internal/model/global"
 	"github.com/nulnl/nulyun/internal/model/users"
 	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
 )
@@ -34,6 +35,8 @@ var resourceGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d
 	if err != nil {
 		return errToStatus(err), err
 	}
+	applyLabels(d, file)
+	applyComments(d, file)
 
 	if file.IsDir {
 		file.Sorting = d.user.Sorting
@@ -51,6 +54,8 @@ var resourceGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d
 
 		// do not waste bandwidth if we just want the checksum
 		file.Content = ""
+	} else {
+		recordOpened(d, file.Path)
 	}
 
 	return renderJSON(w, r, file)
@@ -74,6 +79,10 @@ func resourceDeleteHandler(fileCache FileCache) handleFunc {
 			return errToStatus(err), err
 		}
 
+		if err := beforeHooks(r.Context(), d, settings.BeforeDelete, r.URL.Path, ""); err != nil {
+			return errToStatus(err), err
+		}
+
 		err = d.store.Share.DeleteWithPathPrefix(file.Path)
 		if err != nil {
 			log.Printf("WARNING: Error(s) occurred while deleting associated shares with file: %s", err)
@@ -90,7 +99,9 @@ func resourceDeleteHandler(fileCache FileCache) handleFunc {
 		if err != nil {
 			return errToStatus(err), err
 		}
+		followDelete(d.store, d.user.Scope, r.URL.Path)
 
+		afterHooks(d, settings.AfterDelete, r.URL.Path, "")
 		return http.StatusNoContent, nil
 	})
 }
@@ -147,6 +158,10 @@ func resourcePostHandler(fileCache FileCache) handleFunc {
 			}
 		}
 
+		if err := beforeHooks(r.Context(), d, settings.BeforeUpload, r.URL.Path, ""); err != nil {
+			return errToStatus(err), err
+		}
+
 		info, err := writeFile(d.user.Fs, r.URL.Path, r.Body, d.settings.FileMode, d.settings.DirMode)
 		if err != nil {
 			return errToStatus(err), err
@@ -155,6 +170,8 @@ func resourcePostHandler(fileCache FileCache) handleFunc {
 		etag := fmt.Sprintf(`"%x%x"`, info.ModTime().UnixNano(), info.Size())
 		w.Header().Set("ETag", etag)
 
+		afterHooks(d, settings.AfterUpload, r.URL.Path, "")
+		scanUpload(d.store, d.server, d.user, r.URL.Path)
 		return errToStatus(err), err
 	})
 }
@@ -177,6 +194,10 @@ var resourcePutHandler = withUser(func(w http.ResponseWriter, r *http.Request, d
 		return http.StatusNotFound, nil
 	}
 
+	if err := beforeHooks(r.Context(), d, settings.BeforeSave, r.URL.Path, ""); err != nil {
+		return errToStatus(err), err
+	}
+
 	info, err := writeFile(d.user.Fs, r.URL.Path, r.Body, d.settings.FileMode, d.settings.DirMode)
 	if err != nil {
 		return errToStatus(err), err
@@ -185,6 +206,8 @@ var resourcePutHandler = withUser(func(w http.ResponseWriter, r *http.Request, d
 	etag := fmt.Sprintf(`"%x%x"`, info.ModTime().UnixNano(), info.Size())
 	w.Header().Set("ETag", etag)
 
+	afterHooks(d, settings.AfterSave, r.URL.Path, "")
+	scanUpload(d.store, d.server, d.user, r.URL.Path)
 	return errToStatus(err), err
 })
 
@@ -219,6 +242,7 @@ func resourcePatchHandler(fileCache FileCache) handleFunc {
 		if rename {
 			dst = addVersionSuffix(dst, d.user.Fs)
 		}
+		d.destination = dst
 
 		// Permission for overwriting the file
 		if override && !d.user.Perm.Modify {
@@ -307,8 +331,16 @@ func patchAction(ctx context.Context, action, src, dst string, d *data, fileCach
 		if !d.user.Perm.Create {
 			return fberrors.ErrPermissionDenied
 		}
+		if err := beforeHooks(ctx, d, settings.BeforeCopy, src, dst); err != nil {
+			return err
+		}
 
-		return files.Copy(d.user.Fs, src, dst, d.settings.FileMode, d.settings.DirMode)
+		if err := files.Copy(d.user.Fs, src, dst, d.settings.FileMode, d.settings.DirMode); err != nil {
+			return err
+		}
+
+		afterHooks(d, settings.AfterCopy, src, dst)
+		return nil
 	case "rename":
 		if !d.user.Perm.Rename {
 			return fberrors.ErrPermissionDenied
@@ -328,13 +360,23 @@ func patchAction(ctx context.Context, action, src, dst string, d *data, fileCach
 			return err
 		}
 
+		if err := beforeHooks(ctx, d, settings.BeforeRename, src, dst); err != nil {
+			return err
+		}
+
 		// delete thumbnails
 		err = delThumbs(ctx, fileCache, file)
 		if err != nil {
 			return err
 		}
 
-		return files.MoveFile(d.user.Fs, src, dst, d.settings.FileMode, d.settings.DirMode)
+		if err := files.MoveFile(d.user.Fs, src, dst, d.settings.FileMode, d.settings.DirMode); err != nil {
+			return err
+		}
+		followMove(d.store, d.user.Scope, src, dst)
+
+		afterHooks(d, settings.AfterRename, src, dst)
+		return nil
 	default:
 		return fmt.Errorf("unsupported action %s: %w", action, fberrors.ErrInvalidRequestParams)
 	}
@@ -0,0 +1,71 @@
+package fbhttp
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/nulnl/nulyun/internal/model/activity"
+	"github.com/nulnl/nulyun/internal/model/audit"
+	"github.com/nulnl/nulyun/internal/model/users"
+)
+
+func TestResourcePatchRenameDestination(t *testing.T) {
+	s := newTestServer(t, nil, &users.User{
+		Username: "alice",
+		Perm:     users.Permissions{Create: true, Rename: true, Modify: true},
+	})
+	tk := s.token(t, 1)
+	s.writeFile(t, "a.txt", "a")
+	s.writeFile(t, "b.txt", "b")
+
+	for name, tag := range map[string]string{"/a.txt": "from-a", "/b.txt": "from-b"} {
+		body := `{"paths":["` + name + `"],"add":["` + tag + `"]}`
+		if rec := s.do(t, http.MethodPut, "/api/tags", tk, body); rec.Code != http.StatusOK {
+			t.Fatalf("tagging %s: %d %s", name, rec.Code, rec.Body)
+		}
+	}
+
+	rec := s.do(t, http.MethodPatch, "/api/resources/a.txt?action=rename&destination=%2Fb.txt&rename=true", tk, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("move: %d %s", rec.Code, rec.Body)
+	}
+	if _, err := os.Stat(filepath.Join(s.root, "b(1).txt")); err != nil {
+		t.Fatalf("moved file not found: %v", err)
+	}
+
+	for name, want := range map[string]string{"/b.txt": "from-b", "/b(1).txt": "from-a"} {
+		file, err := s.store.Files.Get(name)
+		if err != nil {
+			t.Fatalf("identity of %s: %v", name, err)
+		}
+		l, err := s.store.Tags.Get(1, file.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(l.Tags, []string{want}) {
+			t.Errorf("tags of %s = %v, want [%s]", name, l.Tags, want)
+		}
+	}
+	if _, err := s.store.Files.Get("/a.txt"); err == nil {
+		t.Errorf("identity of /a.txt was kept")
+	}
+
+	entries, _, err := s.store.Audit.Query(&audit.Filter{Action: audit.ActionFileMove})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Destination != "/b(1).txt" {
+		t.Errorf("audit entries = %+v, want a move to /b(1).txt", entries)
+	}
+
+	items, err := s.store.Activity.FindByUserID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) == 0 || items[0].Action != activity.ActionMoved || items[0].Destination != "/b(1).txt" {
+		t.Errorf("activity = %+v, want a move to /b(1).txt first", items)
+	}
+}
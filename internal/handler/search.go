@@ -1,28 +1,271 @@
 package fbhttp
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"net/http"
 	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/nulnl/nulyun/internal/files"
+	"github.com/nulnl/nulyun/internal/index"
+	settings "github.com/nulnl/nulyun/internal/model/global"
 )
 
+// indexBufferSize is the number of file events waiting to be applied to
+// the search index. The events dropped beyond it are caught up by the next
+// reconciliation.
+const indexBufferSize = 1024
+
+// searchIndex is the index of the files of the root. It is nil when
+// disabled, and searches walk the disk while it isn't fresh.
+var searchIndex *index.Index
+
+// startSearchIndex opens the search index of the server and keeps it
+// current with the file events.
+func startSearchIndex(server *settings.Server) error {
+	interval, err := time.ParseDuration(server.SearchIndexInterval)
+	if err != nil {
+		return fmt.Errorf("invalid search index interval: %w", err)
+	}
+
+	var content *index.ContentOptions
+	if server.IndexContent {
+		content = &index.ContentOptions{MaxSize: files.ContentMaxSize, IsText: files.IsText}
+	}
+
+	searchIndex, err = index.Open(server.SearchIndex, server.Root, interval, content)
+	if err != nil {
+		return fmt.Errorf("failed to open the search index: %w", err)
+	}
+
+	ch, _ := eventBus.Subscribe(indexBufferSize)
+	go searchIndex.Run(context.Background(), ch)
+	return nil
+}
+
+// searchResult is a file found by a search.
+type searchResult struct {
+	Path     string          `json:"path"`
+	Dir      bool            `json:"dir"`
+	Size     int64           `json:"size"`
+	Modified time.Time       `json:"modified"`
+	Type     string          `json:"type,omitempty"`
+	Tags     []string        `json:"tags,omitempty"`
+	Snippets []files.Snippet `json:"snippets,omitempty"`
+}
+
+// searchEnd ends streamed results. Cursor resumes the search when its limit
+// was reached, and Error tells why it failed.
+type searchEnd struct {
+	End    bool   `json:"end"`
+	Cursor string `json:"cursor,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type searchFormat int
+
+const (
+	searchJSON searchFormat = iota
+	searchNDJSON
+	searchSSE
+)
+
+// parseSearchFormat returns the format of the results, set by the format
+// parameter or else by the Accept header. The results are a JSON array by
+// default.
+func parseSearchFormat(r *http.Request) (searchFormat, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		accept := r.Header.Get("Accept")
+		switch {
+		case strings.Contains(accept, "application/x-ndjson"):
+			format = "ndjson"
+		case strings.Contains(accept, "text/event-stream"):
+			format = "sse"
+		}
+	}
+
+	switch format {
+	case "", "json":
+		return searchJSON, nil
+	case "ndjson":
+		return searchNDJSON, nil
+	case "sse":
+		return searchSSE, nil
+	default:
+		return 0, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// searchWriter writes the results of a search as they are found, or at the
+// end for the JSON arrays. The response starts with the first streamed
+// result, so an error found before it still sets the status.
+type searchWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	format  searchFormat
+	started bool
+	results []*searchResult
+}
+
+func (sw *searchWriter) start() {
+	if sw.started {
+		return
+	}
+	sw.started = true
+
+	// The stream outlives the write timeout of the server, if any.
+	_ = sw.rc.SetWriteDeadline(time.Time{})
+	if sw.format == searchSSE {
+		sw.w.Header().Set("Content-Type", "text/event-stream")
+		sw.w.Header().Set("Cache-Control", "no-cache")
+		sw.w.Header().Set("X-Accel-Buffering", "no")
+	} else {
+		sw.w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	sw.w.WriteHeader(http.StatusOK)
+}
+
+// send writes a line of the stream.
+func (sw *searchWriter) send(event string, v any) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	sw.start()
+	if sw.format == searchSSE {
+		_, err = fmt.Fprintf(sw.w, "event: %s\ndata: %s\n\n", event, payload)
+	} else {
+		_, err = fmt.Fprintf(sw.w, "%s\n", payload)
+	}
+	if err != nil {
+		return err
+	}
+	return sw.rc.Flush()
+}
+
+func (sw *searchWriter) write(res *searchResult) error {
+	if sw.format == searchJSON {
+		sw.results = append(sw.results, res)
+		return nil
+	}
+	return sw.send("result", res)
+}
+
+// end completes the results with the cursor of the next ones, if any.
+func (sw *searchWriter) end(r *http.Request, cursor string, err error) (int, error) {
+	if !sw.started {
+		var queryErr *files.QueryError
+		switch {
+		case errors.As(err, &queryErr):
+			return http.StatusBadRequest, err
+		case err != nil:
+			return http.StatusInternalServerError, err
+		}
+
+		if sw.format == searchJSON {
+			if cursor != "" {
+				sw.w.Header().Set("X-Next-Cursor", cursor)
+			}
+			return renderJSON(sw.w, r, sw.results)
+		}
+	}
+
+	end := &searchEnd{End: true, Cursor: cursor}
+	if err != nil {
+		end.Error = err.Error()
+	}
+	if sendErr := sw.send("end", end); sendErr != nil && err == nil {
+		err = sendErr
+	}
+	return 0, err
+}
+
+func encodeCursor(path string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(path))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	path, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", errors.New("invalid cursor")
+	}
+	return string(path), nil
+}
+
 var searchHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-	response := []map[string]interface{}{}
 	query := r.URL.Query().Get("query")
 
-	err := files.Search(d.user.Fs, r.URL.Path, query, d, func(path string, f os.FileInfo) error {
-		response = append(response, map[string]interface{}{
-			"dir":  f.IsDir(),
-			"path": path,
-		})
+	format, err := parseSearchFormat(r)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
 
-		return nil
-	})
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return http.StatusBadRequest, fmt.Errorf("invalid limit %q", v)
+		}
+	}
 
+	after, err := decodeCursor(r.URL.Query().Get("cursor"))
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return http.StatusBadRequest, err
+	}
+
+	sw := &searchWriter{
+		w:       w,
+		rc:      http.NewResponseController(w),
+		format:  format,
+		results: []*searchResult{},
+	}
+
+	var cursor string
+	count := 0
+	found := func(name string, f os.FileInfo, snippets []files.Snippet) error {
+		res := &searchResult{
+			Path:     name,
+			Dir:      f.IsDir(),
+			Size:     f.Size(),
+			Modified: f.ModTime(),
+			Tags:     d.Tags(path.Join(r.URL.Path, name)),
+			Snippets: snippets,
+		}
+		if !res.Dir {
+			res.Type = files.TypeByExtension(name)
+		}
+		if err := sw.write(res); err != nil {
+			return err
+		}
+
+		count++
+		if limit > 0 && count >= limit {
+			cursor = encodeCursor(name)
+			return fs.SkipAll
+		}
+		return nil
+	}
+
+	// The search stops as soon as the client is gone.
+	ctx := r.Context()
+	if searchIndex != nil && searchIndex.Fresh() {
+		err = files.SearchIndex(ctx, searchIndex, d.user.Scope, r.URL.Path, query, after, d, d.Tags, found)
+	} else {
+		err = files.Search(ctx, d.user.Fs, r.URL.Path, query, after, d, d.Tags, found)
+	}
+	if ctx.Err() != nil {
+		return 0, nil
 	}
 
-	return renderJSON(w, r, response)
+	return sw.end(r, cursor, err)
 })
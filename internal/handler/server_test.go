@@ -0,0 +1,133 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3"
+
+	"github.com/nulnl/nulyun/internal/auth"
+	"github.com/nulnl/nulyun/internal/files"
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/users"
+	storage "github.com/nulnl/nulyun/internal/repository"
+	"github.com/nulnl/nulyun/internal/repository/bolt"
+)
+
+// testServer is the whole API of a server storing its files in a temporary
+// directory, for the tests going through the routes.
+type testServer struct {
+	root    string
+	store   *storage.Storage
+	server  *settings.Server
+	handler http.Handler
+}
+
+// newTestServer starts a server with the users given, whose scope is the
+// root unless set.
+func newTestServer(t *testing.T, set *settings.Settings, us ...*users.User) *testServer {
+	t.Helper()
+
+	root := t.TempDir()
+	db, err := storm.Open(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	st, err := bolt.NewStorage(db)
+	if err != nil {
+		t.Fatalf("failed to get storage: %v", err)
+	}
+	if set == nil {
+		set = &settings.Settings{}
+	}
+	set.Key = []byte("key")
+	set.AuthMethod = auth.MethodJSONAuth
+	if err := st.Settings.Save(set); err != nil {
+		t.Fatalf("failed to save settings: %v", err)
+	}
+	if err := st.Auth.Save(&auth.JSONAuth{}); err != nil {
+		t.Fatalf("failed to save auther: %v", err)
+	}
+	if _, err := st.Keyring.Maintain("HS256", time.Hour, time.Hour); err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+	for _, u := range us {
+		if u.Scope == "" {
+			u.Scope = "."
+		}
+		if u.Password == "" {
+			u.Password = "pw"
+		}
+		if err := st.Users.Save(u); err != nil {
+			t.Fatalf("failed to save user: %v", err)
+		}
+	}
+
+	server := &settings.Server{Root: root}
+	handler, err := NewHandler(nil, files.NewNoOp(), st, server, os.DirFS("."))
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	return &testServer{root: root, store: st, server: server, handler: handler}
+}
+
+// token returns a token of the user id.
+func (s *testServer) token(t *testing.T, id uint) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handle(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		u, err := d.store.Users.Get(d.server.Root, id)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		return printToken(w, r, d, u, time.Hour)
+	}, "", s.store, s.server).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", http.NoBody))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("failed to get a token: %d", rec.Code)
+	}
+	var res loginResponse
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+	return res.Token
+}
+
+// do sends a request with the token tk, if any, and returns its response.
+func (s *testServer) do(t *testing.T, method, target, tk, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var r io.Reader = http.NoBody
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, r)
+	if tk != "" {
+		req.Header.Set("X-Auth", tk)
+	}
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// writeFile creates the file name, relative to the root, with its parents.
+func (s *testServer) writeFile(t *testing.T, name, content string) {
+	t.Helper()
+
+	p := filepath.Join(s.root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
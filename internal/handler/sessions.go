@@ -0,0 +1,78 @@
+package fbhttp
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+
+	"github.com/nulnl/nulyun/internal/model/session"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+type revokeSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+var logoutHandler = withUser(func(_ http.ResponseWriter, _ *http.Request, d *data) (int, error) {
+	err := d.store.Sessions.Revoke(d.session.ID)
+	return errToStatus(err), err
+})
+
+var sessionListHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	sessions, err := d.store.Sessions.FindByUserID(d.user.ID)
+	if errors.Is(err, fberrors.ErrNotExist) {
+		return renderJSON(w, r, []*session.Session{})
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	active := []*session.Session{}
+	for _, sess := range sessions {
+		if sess.Revoked {
+			continue
+		}
+		sess.Current = sess.ID == d.session.ID
+		active = append(active, sess)
+	}
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].LastActive > active[j].LastActive
+	})
+
+	return renderJSON(w, r, active)
+})
+
+var sessionDeleteHandler = withUser(func(_ http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	sess, err := d.store.Sessions.Get(mux.Vars(r)["id"])
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if sess.UserID != d.user.ID && !d.user.Perm.Admin {
+		return http.StatusForbidden, nil
+	}
+
+	err = d.store.Sessions.Revoke(sess.ID)
+	return errToStatus(err), err
+})
+
+// userSessionsDeleteHandler revokes all the sessions of a user. When users
+// revoke their own sessions, the current one is kept.
+var userSessionsDeleteHandler = withSelfOrAdmin(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	id := d.raw.(uint)
+
+	var keep []string
+	if id == d.user.ID {
+		keep = append(keep, d.session.ID)
+	}
+
+	n, err := d.store.Sessions.RevokeAll(id, keep...)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, revokeSessionsResponse{Revoked: n})
+})
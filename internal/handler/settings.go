@@ -2,22 +2,31 @@ package fbhttp
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
 	settings "github.com/nulnl/nulyun/internal/model/global"
 )
 
 type settingsData struct {
-	Signup                bool                  `json:"signup"`
-	HideLoginButton       bool                  `json:"hideLoginButton"`
-	CreateUserDir         bool                  `json:"createUserDir"`
-	MinimumPasswordLength uint                  `json:"minimumPasswordLength"`
-	UserHomeBasePath      string                `json:"userHomeBasePath"`
-	Defaults              settings.UserDefaults `json:"defaults"`
-	Branding              settings.Branding     `json:"branding"`
-	Tus                   settings.Tus          `json:"tus"`
-	Shell                 []string              `json:"shell"`
-	TOTPEnabled           bool                  `json:"totpEnabled"`
+	Signup                bool                     `json:"signup"`
+	HideLoginButton       bool                     `json:"hideLoginButton"`
+	CreateUserDir         bool                     `json:"createUserDir"`
+	MinimumPasswordLength uint                     `json:"minimumPasswordLength"`
+	UserHomeBasePath      string                   `json:"userHomeBasePath"`
+	ArchivePath           string                   `json:"archivePath"`
+	Defaults              settings.UserDefaults    `json:"defaults"`
+	Branding              settings.Branding        `json:"branding"`
+	Tus                   settings.Tus             `json:"tus"`
+	Shell                 []string                 `json:"shell"`
+	CommandTimeout        uint                     `json:"commandTimeout"`
+	Hooks                 []settings.Hook          `json:"hooks"`
+	TOTPEnabled           bool                     `json:"totpEnabled"`
+	BruteForce            *settings.BruteForce     `json:"bruteForce"`
+	TwoFactor             settings.TwoFactorPolicy `json:"twoFactor"`
+	SignupPolicy          settings.SignupPolicy    `json:"signupPolicy"`
+	ImpersonationReadOnly bool                     `json:"impersonationReadOnly"`
 }
 
 var settingsGetHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
@@ -27,11 +36,18 @@ var settingsGetHandler = withAdmin(func(w http.ResponseWriter, r *http.Request,
 		CreateUserDir:         d.settings.CreateUserDir,
 		MinimumPasswordLength: d.settings.MinimumPasswordLength,
 		UserHomeBasePath:      d.settings.UserHomeBasePath,
+		ArchivePath:           d.settings.ArchivePath,
 		Defaults:              d.settings.Defaults,
 		Branding:              d.settings.Branding,
 		Tus:                   d.settings.Tus,
 		Shell:                 d.settings.Shell,
+		CommandTimeout:        d.settings.CommandTimeout,
+		Hooks:                 d.settings.Hooks,
 		TOTPEnabled:           d.settings.TOTPEnabled,
+		BruteForce:            d.settings.BruteForce,
+		TwoFactor:             d.settings.TwoFactor,
+		SignupPolicy:          d.settings.SignupPolicy,
+		ImpersonationReadOnly: d.settings.ImpersonationReadOnly,
 	}
 
 	return renderJSON(w, r, data)
@@ -44,17 +60,41 @@ var settingsPutHandler = withAdmin(func(_ http.ResponseWriter, r *http.Request,
 		return http.StatusBadRequest, err
 	}
 
+	// The verification links are built from the public URL.
+	if req.SignupPolicy.VerifyEmail && d.server.PublicURL == "" {
+		return http.StatusBadRequest, errNoPublicURL
+	}
+
 	d.settings.Signup = req.Signup
 	d.settings.CreateUserDir = req.CreateUserDir
 	d.settings.MinimumPasswordLength = req.MinimumPasswordLength
 	d.settings.UserHomeBasePath = req.UserHomeBasePath
+	d.settings.ArchivePath = req.ArchivePath
 	d.settings.Defaults = req.Defaults
 	d.settings.Branding = req.Branding
 	d.settings.Tus = req.Tus
 	d.settings.Shell = req.Shell
+	d.settings.CommandTimeout = req.CommandTimeout
+	d.settings.Hooks = req.Hooks
 	d.settings.HideLoginButton = req.HideLoginButton
 	d.settings.TOTPEnabled = req.TOTPEnabled
+	if req.BruteForce != nil {
+		d.settings.BruteForce = req.BruteForce
+	}
+
+	// The grace period restarts whenever the enforced rules change.
+	if req.TwoFactor.Equal(d.settings.TwoFactor) {
+		req.TwoFactor.Since = d.settings.TwoFactor.Since
+	} else {
+		req.TwoFactor.Since = time.Now().Unix()
+	}
+	d.settings.TwoFactor = req.TwoFactor
+	d.settings.SignupPolicy = req.SignupPolicy
+	d.settings.ImpersonationReadOnly = req.ImpersonationReadOnly
 
 	err = d.store.Settings.Save(d.settings)
+	if errors.Is(err, settings.ErrInvalidHookEvent) || errors.Is(err, settings.ErrInvalidHookTarget) {
+		return http.StatusBadRequest, err
+	}
 	return errToStatus(err), err
 })
@@ -14,6 +14,7 @@ import (
 
 	"golang.org/x/crypto/bcrypt"
 
+	settings "github.com/nulnl/nulyun/internal/model/global"
 	"github.com/nulnl/nulyun/internal/model/share"
 	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
 )
@@ -91,7 +92,6 @@ var shareDeleteHandler = withPermShare(func(_ http.ResponseWriter, r *http.Reque
 })
 
 var sharePostHandler = withPermShare(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
-	var s *share.Link
 	var body share.CreateBody
 	if r.Body != nil {
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -100,10 +100,31 @@ var sharePostHandler = withPermShare(func(w http.ResponseWriter, r *http.Request
 		defer r.Body.Close()
 	}
 
+	s, status, err := newShareLink(d, body)
+	if err != nil {
+		return status, err
+	}
+	s.Path = r.URL.Path
+
+	if err := beforeHooks(r.Context(), d, settings.BeforeShare, r.URL.Path, ""); err != nil {
+		return errToStatus(err), err
+	}
+
+	if err := d.store.Share.Save(s); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	afterHooks(d, settings.AfterShare, r.URL.Path, "")
+	return renderJSON(w, r, s)
+})
+
+// newShareLink creates a share link of the user with a random hash, and the
+// expiration and the password of body.
+func newShareLink(d *data, body share.CreateBody) (*share.Link, int, error) {
 	bytes := make([]byte, 6)
 	_, err := rand.Read(bytes)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return nil, http.StatusInternalServerError, err
 	}
 
 	str := base64.URLEncoding.EncodeToString(bytes)
@@ -113,7 +134,7 @@ var sharePostHandler = withPermShare(func(w http.ResponseWriter, r *http.Request
 	if body.Expires != "" {
 		num, err := strconv.Atoi(body.Expires)
 		if err != nil {
-			return http.StatusInternalServerError, err
+			return nil, http.StatusInternalServerError, err
 		}
 
 		var add time.Duration
@@ -133,33 +154,26 @@ var sharePostHandler = withPermShare(func(w http.ResponseWriter, r *http.Request
 
 	hash, status, err := getSharePasswordHash(body)
 	if err != nil {
-		return status, err
+		return nil, status, err
 	}
 
 	var token string
 	if len(hash) > 0 {
 		tokenBuffer := make([]byte, 96)
 		if _, err := rand.Read(tokenBuffer); err != nil {
-			return http.StatusInternalServerError, err
+			return nil, http.StatusInternalServerError, err
 		}
 		token = base64.URLEncoding.EncodeToString(tokenBuffer)
 	}
 
-	s = &share.Link{
-		Path:         r.URL.Path,
+	return &share.Link{
 		Hash:         str,
 		Expire:       expire,
 		UserID:       d.user.ID,
 		PasswordHash: string(hash),
 		Token:        token,
-	}
-
-	if err := d.store.Share.Save(s); err != nil {
-		return http.StatusInternalServerError, err
-	}
-
-	return renderJSON(w, r, s)
-})
+	}, 0, nil
+}
 
 func getSharePasswordHash(body share.CreateBody) (data []byte, statuscode int, err error) {
 	if body.Password == "" {
@@ -0,0 +1,199 @@
+package fbhttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/nulnl/nulyun/internal/mail"
+	"github.com/nulnl/nulyun/internal/model/signup"
+	"github.com/nulnl/nulyun/internal/model/users"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+// mailTimeout bounds the delivery of an email to the mail server.
+const mailTimeout = 30 * time.Second
+
+// newMailer returns the mailer used to send emails. It can be replaced to
+// capture emails in tests.
+var newMailer = func(d *data) mail.Mailer {
+	return &d.server.SMTP
+}
+
+type signupResponse struct {
+	AwaitingApproval     bool `json:"awaitingApproval"`
+	AwaitingVerification bool `json:"awaitingVerification"`
+}
+
+type inviteBody struct {
+	MaxUses   uint               `json:"maxUses"`
+	ExpiresAt int64              `json:"expiresAt"`
+	Groups    []string           `json:"groups"`
+	Perm      *users.Permissions `json:"perm"`
+}
+
+// sendMail sends an email to a user with the configured mailer.
+func sendMail(ctx context.Context, d *data, to, subject, body string) error {
+	ctx, cancel := context.WithTimeout(ctx, mailTimeout)
+	defer cancel()
+
+	return newMailer(d).Send(ctx, &mail.Message{
+		To:      []string{to},
+		Subject: subject,
+		Body:    body,
+	})
+}
+
+// errNoPublicURL is returned when a link of the app is needed without the
+// public URL of the server. The host of the request is never used instead,
+// since anyone can forge it.
+var errNoPublicURL = errors.New("the public URL of the server is not set")
+
+// publicURL returns the absolute URL of a path of the app, from the
+// configured public URL.
+func publicURL(d *data, path string) (string, error) {
+	if d.server.PublicURL == "" {
+		return "", errNoPublicURL
+	}
+	return strings.TrimSuffix(d.server.PublicURL, "/") + path, nil
+}
+
+// sendVerification emails a verification link to a user that signed up.
+func sendVerification(r *http.Request, d *data, u *users.User) error {
+	v, err := signup.NewVerification(u.ID, u.Email)
+	if err != nil {
+		return err
+	}
+
+	link, err := publicURL(d, "/api/signup/verify?token="+url.QueryEscape(v.Token))
+	if err != nil {
+		return err
+	}
+
+	if err := d.store.Signup.SaveVerification(v); err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Hello %s,\n\nPlease confirm your email address by opening the following link:\n\n%s\n\nThe link expires in %s.\n",
+		u.Username, link, signup.VerificationExpiration)
+
+	return sendMail(r.Context(), d, u.Email, "Confirm your email address", body)
+}
+
+var signupVerifyHandler = func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	v, err := d.store.Signup.Verify(r.URL.Query().Get("token"))
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	u, err := d.store.Users.Get(d.server.Root, v.UserID)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	// The link only verifies the address it was sent to.
+	if u.Email != v.Email {
+		return http.StatusNotFound, nil
+	}
+
+	u.AwaitingVerification = false
+	if err := d.store.Users.Update(u, "AwaitingVerification"); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	http.Redirect(w, r, d.server.BaseURL+"/login", http.StatusFound)
+	return 0, nil
+}
+
+var userApproveHandler = withAdmin(func(_ http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	id, err := getUserID(r)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	u, err := d.store.Users.Get(d.server.Root, id)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	if !u.AwaitingApproval {
+		return http.StatusOK, nil
+	}
+
+	u.AwaitingApproval = false
+	if err := d.store.Users.Update(u, "AwaitingApproval"); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if u.Email != "" {
+		body := fmt.Sprintf("Hello %s,\n\nYour account has been approved, you can now log in.\n", u.Username)
+		if link, err := publicURL(d, "/login"); err == nil {
+			body = fmt.Sprintf("Hello %s,\n\nYour account has been approved, you can now log in:\n\n%s\n", u.Username, link)
+		}
+		if err := sendMail(r.Context(), d, u.Email, "Your account has been approved", body); err != nil {
+			log.Printf("failed to notify user %q of its approval: %v", u.Username, err)
+		}
+	}
+
+	return http.StatusOK, nil
+})
+
+var inviteListHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	invites, err := d.store.Signup.Invites()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	sort.Slice(invites, func(i, j int) bool {
+		return invites[i].CreatedAt > invites[j].CreatedAt
+	})
+
+	if invites == nil {
+		invites = []*signup.Invite{}
+	}
+
+	return renderJSON(w, r, invites)
+})
+
+var invitePostHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	if r.Body == nil {
+		return http.StatusBadRequest, fberrors.ErrEmptyRequest
+	}
+
+	var body inviteBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if body.Perm != nil && body.Perm.Admin {
+		return http.StatusBadRequest, fmt.Errorf("invitations can't grant administration rights: %w", fberrors.ErrInvalidRequestParams)
+	}
+
+	invite, err := signup.NewInvite(d.user.ID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	invite.MaxUses = body.MaxUses
+	invite.ExpiresAt = body.ExpiresAt
+	invite.Groups = body.Groups
+	invite.Perm = body.Perm
+
+	if err := d.store.Signup.SaveInvite(invite); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, invite)
+})
+
+var inviteDeleteHandler = withAdmin(func(_ http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	err := d.store.Signup.DeleteInvite(mux.Vars(r)["code"])
+	return errToStatus(err), err
+})
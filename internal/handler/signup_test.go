@@ -0,0 +1,71 @@
+package fbhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nulnl/nulyun/internal/mail"
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/users"
+)
+
+type fakeMailer struct {
+	sent []*mail.Message
+}
+
+func (m *fakeMailer) Send(_ context.Context, msg *mail.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestSignupVerificationLink(t *testing.T) {
+	mailer := &fakeMailer{}
+	orig := newMailer
+	newMailer = func(*data) mail.Mailer { return mailer }
+	t.Cleanup(func() { newMailer = orig })
+
+	s := newTestServer(t, &settings.Settings{
+		Signup:       true,
+		SignupPolicy: settings.SignupPolicy{VerifyEmail: true},
+	}, &users.User{Username: "admin", Perm: users.Permissions{Admin: true}})
+
+	signup := func(username string) int {
+		body := `{"username":"` + username + `","password":"password1234","email":"` + username + `@example.com"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/signup", strings.NewReader(body))
+		req.Host = "attacker.example"
+		rec := httptest.NewRecorder()
+		s.handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Without a public URL, no link is mailed.
+	if code := signup("bob"); code != http.StatusInternalServerError {
+		t.Errorf("signup without a public URL: %d, want 500", code)
+	}
+	if len(mailer.sent) != 0 {
+		t.Errorf("mails sent without a public URL: %+v", mailer.sent)
+	}
+	if _, err := s.store.Users.Get(s.root, "bob"); err == nil {
+		t.Errorf("user created without a public URL")
+	}
+
+	rec := s.do(t, http.MethodPut, "/api/settings", s.token(t, 1), `{"signup":true,"signupPolicy":{"verifyEmail":true}}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("enabling the verification without a public URL: %d, want 400", rec.Code)
+	}
+
+	s.server.PublicURL = "https://files.example.com/"
+	if code := signup("carol"); code != http.StatusOK {
+		t.Fatalf("signup: %d", code)
+	}
+	if len(mailer.sent) != 1 {
+		t.Fatalf("sent %d mails, want 1", len(mailer.sent))
+	}
+	body := mailer.sent[0].Body
+	if !strings.Contains(body, "https://files.example.com/api/signup/verify?token=") || strings.Contains(body, "attacker.example") {
+		t.Errorf("verification mail doesn't link to the public URL:\n%s", body)
+	}
+}
@@ -0,0 +1,273 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/nulnl/nulyun/internal/events"
+	"github.com/nulnl/nulyun/internal/files"
+	"github.com/nulnl/nulyun/internal/model/tags"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+	storage "github.com/nulnl/nulyun/internal/repository"
+)
+
+// rootPath returns the path of a file of the user relative to the root of
+// the server.
+func (d *data) rootPath(name string) string {
+	return path.Join("/", d.user.Scope, name)
+}
+
+// scopePath returns the path relative to the scope of the user of a file
+// of the root, and false when it's outside of it.
+func (d *data) scopePath(name string) (string, bool) {
+	scope := path.Join("/", d.user.Scope)
+	switch {
+	case scope == "/":
+		return name, true
+	case name == scope:
+		return "/", true
+	case strings.HasPrefix(name, scope+"/"):
+		return strings.TrimPrefix(name, scope), true
+	}
+	return "", false
+}
+
+// userLabels returns the labels of the user by the path of their files,
+// relative to its scope. They're loaded once by request.
+func (d *data) userLabels() map[string]*tags.Labels {
+	if d.labels != nil {
+		return d.labels
+	}
+	d.labels = map[string]*tags.Labels{}
+
+	all, err := d.store.Tags.FindByUserID(d.user.ID)
+	if err != nil {
+		log.Printf("failed to load the tags of %q: %v", d.user.Username, err)
+		return d.labels
+	}
+	if len(all) == 0 {
+		return d.labels
+	}
+
+	ids := make([]uint64, 0, len(all))
+	for _, l := range all {
+		ids = append(ids, l.FileID)
+	}
+	paths, err := d.store.Files.Paths(ids)
+	if err != nil {
+		log.Printf("failed to load the tagged files of %q: %v", d.user.Username, err)
+		return d.labels
+	}
+
+	for _, l := range all {
+		if name, ok := d.scopePath(paths[l.FileID]); ok {
+			d.labels[name] = l
+		}
+	}
+	return d.labels
+}
+
+// Tags returns the tags of the user on the file name, as a files.TagsFunc.
+func (d *data) Tags(name string) []string {
+	if l := d.userLabels()[path.Join("/", name)]; l != nil {
+		return l.Tags
+	}
+	return nil
+}
+
+// applyLabels sets the tags of the user on a file and on the items of its
+// listing.
+func applyLabels(d *data, file *files.FileInfo) {
+	labels := d.userLabels()
+	if len(labels) == 0 {
+		return
+	}
+
+	set := func(f *files.FileInfo) {
+		if l := labels[path.Join("/", f.Path)]; l != nil {
+			f.Tags, f.Favorite = l.Tags, l.Favorite
+		}
+	}
+	set(file)
+	if file.Listing != nil {
+		for _, item := range file.Items {
+			set(item)
+		}
+	}
+}
+
+// followMove moves the identities of the file src of a user, whose scope
+// is scope, and of its content to dst, so their records follow them.
+// Failing to do it never fails the move.
+func followMove(store *storage.Storage, scope, src, dst string) {
+	if err := store.Files.Move(path.Join(scope, src), path.Join(scope, dst)); err != nil {
+		log.Printf("failed to move the identity of %s: %v", src, err)
+	}
+}
+
+// followDelete removes the identities of the file name of a user, whose
+// scope is scope, and of its content, with their records.
+func followDelete(store *storage.Storage, scope, name string) {
+	if err := store.Files.Delete(path.Join(scope, name)); err != nil {
+		log.Printf("failed to delete the identity of %s: %v", name, err)
+	}
+}
+
+// followWatched follows the files moved and deleted outside of the app, as
+// seen by the watcher, whose paths are relative to root.
+func followWatched(store *storage.Storage, root string, ch <-chan events.Event) {
+	for e := range ch {
+		if e.Source != events.SourceWatcher {
+			continue
+		}
+		// The changes made through the app were followed already, and
+		// something may be at the path again since.
+		if _, err := os.Lstat(filepath.Join(root, filepath.FromSlash(e.Path))); err == nil {
+			continue
+		}
+
+		switch e.Type {
+		case events.FileMoved:
+			// The moves made through the app are seen once their
+			// identities were moved, which must not be taken again from
+			// their destination.
+			moved, err := store.Files.Exists(e.Path)
+			if err != nil {
+				log.Printf("failed to find the identity of %s: %v", e.Path, err)
+			} else if moved {
+				followMove(store, "/", e.Path, e.Destination)
+			}
+		case events.FileDeleted:
+			followDelete(store, "/", e.Path)
+		}
+	}
+}
+
+type tagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type tagsResponse struct {
+	Tags      []*tagCount `json:"tags"`
+	Favorites []string    `json:"favorites"`
+}
+
+// tagsGetHandler returns the tags of the user, with the number of files
+// having each of them, and its favorite files.
+var tagsGetHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	res := &tagsResponse{Tags: []*tagCount{}, Favorites: []string{}}
+	counts := map[string]*tagCount{}
+
+	for name, l := range d.userLabels() {
+		if !d.Check(name) {
+			continue
+		}
+		if l.Favorite {
+			res.Favorites = append(res.Favorites, name)
+		}
+		for _, tag := range l.Tags {
+			c, ok := counts[strings.ToLower(tag)]
+			if !ok {
+				c = &tagCount{Name: tag}
+				counts[strings.ToLower(tag)] = c
+				res.Tags = append(res.Tags, c)
+			}
+			c.Count++
+		}
+	}
+
+	slices.SortFunc(res.Tags, func(a, b *tagCount) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	slices.Sort(res.Favorites)
+	return renderJSON(w, r, res)
+})
+
+type tagsRequest struct {
+	Paths    []string `json:"paths"`
+	Add      []string `json:"add"`
+	Remove   []string `json:"remove"`
+	Favorite *bool    `json:"favorite"`
+}
+
+type fileLabels struct {
+	Path     string   `json:"path"`
+	Tags     []string `json:"tags"`
+	Favorite bool     `json:"favorite"`
+}
+
+func newFileLabels(name string, l *tags.Labels) *fileLabels {
+	return &fileLabels{Path: name, Tags: l.Tags, Favorite: l.Favorite}
+}
+
+// tagsPutHandler adds and removes tags, and sets the favorite mark, on a
+// selection of files.
+var tagsPutHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	var req tagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if len(req.Paths) == 0 {
+		return http.StatusBadRequest, fberrors.ErrEmptyRequest
+	}
+	for _, tag := range req.Add {
+		if _, err := tags.Clean(tag); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+
+	for i, name := range req.Paths {
+		name = path.Join("/", name)
+		if !d.Check(name) {
+			return http.StatusForbidden, nil
+		}
+		if _, err := d.user.Fs.Stat(name); err != nil {
+			return errToStatus(err), err
+		}
+		req.Paths[i] = name
+	}
+
+	// The files get an identity when they're labeled.
+	labeling := len(req.Add) > 0 || (req.Favorite != nil && *req.Favorite)
+
+	res := make([]*fileLabels, 0, len(req.Paths))
+	for _, name := range req.Paths {
+		file, err := d.store.Files.Get(d.rootPath(name))
+		if errors.Is(err, fberrors.ErrNotExist) && labeling {
+			file, err = d.store.Files.Ensure(d.rootPath(name))
+		}
+		if errors.Is(err, fberrors.ErrNotExist) {
+			res = append(res, newFileLabels(name, tags.New(d.user.ID, 0)))
+			continue
+		} else if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		l, err := d.store.Tags.Get(d.user.ID, file.ID)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if err := l.Add(req.Add...); err != nil {
+			return http.StatusBadRequest, err
+		}
+		l.Remove(req.Remove...)
+		if req.Favorite != nil {
+			l.Favorite = *req.Favorite
+		}
+
+		if err := d.store.Tags.Save(l); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		res = append(res, newFileLabels(name, l))
+	}
+
+	return renderJSON(w, r, res)
+})
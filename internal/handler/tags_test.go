@@ -0,0 +1,69 @@
+package fbhttp
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/nulnl/nulyun/internal/events"
+	"github.com/nulnl/nulyun/internal/model/users"
+)
+
+func TestFollowFiles(t *testing.T) {
+	s := newTestServer(t, nil, &users.User{
+		Username: "alice",
+		Perm:     users.Permissions{Create: true, Rename: true, Delete: true},
+	})
+	tk := s.token(t, 1)
+	for _, name := range []string{"disk-moved", "disk-deleted", "app-moved", "app-deleted"} {
+		s.writeFile(t, name, name)
+		body := `{"paths":["/` + name + `"],"add":["` + name + `"]}`
+		if rec := s.do(t, http.MethodPut, "/api/tags", tk, body); rec.Code != http.StatusOK {
+			t.Fatalf("tagging %s: %d %s", name, rec.Code, rec.Body)
+		}
+	}
+
+	if rec := s.do(t, http.MethodPatch, "/api/resources/app-moved?action=rename&destination=%2Fapp-dst", tk, ""); rec.Code != http.StatusOK {
+		t.Fatalf("move: %d %s", rec.Code, rec.Body)
+	}
+	if rec := s.do(t, http.MethodDelete, "/api/resources/app-deleted", tk, ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body)
+	}
+
+	if err := os.Rename(filepath.Join(s.root, "disk-moved"), filepath.Join(s.root, "disk-dst")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(s.root, "disk-deleted")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The watcher sees the changes made through the app too.
+	ch := make(chan events.Event, 4)
+	ch <- events.Event{Type: events.FileMoved, Path: "/app-moved", Destination: "/app-dst", Source: events.SourceWatcher}
+	ch <- events.Event{Type: events.FileDeleted, Path: "/app-deleted", Source: events.SourceWatcher}
+	ch <- events.Event{Type: events.FileMoved, Path: "/disk-moved", Destination: "/disk-dst", Source: events.SourceWatcher}
+	ch <- events.Event{Type: events.FileDeleted, Path: "/disk-deleted", Source: events.SourceWatcher}
+	close(ch)
+	followWatched(s.store, s.root, ch)
+
+	for name, want := range map[string]string{"/app-dst": "app-moved", "/disk-dst": "disk-moved"} {
+		file, err := s.store.Files.Get(name)
+		if err != nil {
+			t.Fatalf("identity of %s: %v", name, err)
+		}
+		l, err := s.store.Tags.Get(1, file.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(l.Tags, []string{want}) {
+			t.Errorf("tags of %s = %v, want [%s]", name, l.Tags, want)
+		}
+	}
+	for _, name := range []string{"/app-moved", "/app-deleted", "/disk-moved", "/disk-deleted"} {
+		if _, err := s.store.Files.Get(name); err == nil {
+			t.Errorf("identity of %s was kept", name)
+		}
+	}
+}
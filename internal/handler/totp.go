@@ -7,6 +7,7 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/golang-jwt/jwt/v5/request"
+	"github.com/tomasen/realip"
 
 	"github.com/nulnl/nulyun/internal/model/users"
 )
@@ -46,12 +47,14 @@ func verifyTOTPHandler(tokenExpireTime time.Duration) handleFunc {
 			return http.StatusUnauthorized, nil
 		}
 
-		keyFunc := func(_ *jwt.Token) (interface{}, error) {
-			return d.settings.Key, nil
+		ring, err := d.store.Keyring.Get()
+		if err != nil {
+			return http.StatusInternalServerError, err
 		}
 
 		var tk totpAuthToken
-		token, err := request.ParseFromRequest(r, &totpExtractor{}, keyFunc, request.WithClaims(&tk))
+		p := jwt.NewParser(jwt.WithValidMethods(ring.Methods()), jwt.WithExpirationRequired())
+		token, err := request.ParseFromRequest(r, &totpExtractor{}, ring.Keyfunc(d.settings.Key), request.WithClaims(&tk), request.WithParser(p))
 
 		if err != nil || !token.Valid {
 			return http.StatusUnauthorized, nil
@@ -62,15 +65,27 @@ func verifyTOTPHandler(tokenExpireTime time.Duration) handleFunc {
 			return http.StatusInternalServerError, err
 		}
 
+		if status := d.user.Status(); status != users.StatusActive {
+			w.Header().Set("X-Account-Status", status)
+			return http.StatusForbidden, nil
+		}
+
 		// Check if TOTP is globally enabled and user has TOTP enabled
-		if !d.settings.TOTPEnabled || !d.user.TOTPEnabled {
+		if !d.settings.TOTPEnabled || (!d.user.TOTPEnabled && !twoFactorRequired(d, d.user)) {
 			return http.StatusForbidden, nil
 		}
 
+		ip := realip.FromRequest(r)
+		if wait := checkAuthAttempt(d, ip, d.user.Username); wait > 0 {
+			return tooManyAttempts(w, wait)
+		}
+
 		// First try TOTP validation
 		if ok, err := users.CheckTOTP(d.settings.TOTPEncryptionKey, d.user.TOTPSecret, d.user.TOTPNonce, code); err != nil {
 			return http.StatusInternalServerError, err
 		} else if ok {
+			authLimiter.Reset("user:" + d.user.Username)
+			recordLogin(r, d, d.user.Username, http.StatusOK)
 			return printToken(w, r, d, d.user, tokenExpireTime)
 		}
 
@@ -81,9 +96,13 @@ func verifyTOTPHandler(tokenExpireTime time.Duration) handleFunc {
 			if err := d.store.Users.Update(d.user, "RecoveryCodes"); err != nil {
 				return http.StatusInternalServerError, err
 			}
+			authLimiter.Reset("user:" + d.user.Username)
+			recordLogin(r, d, d.user.Username, http.StatusOK)
 			return printToken(w, r, d, d.user, tokenExpireTime)
 		}
 
+		recordAuthFailure(d, "totp", ip, d.user.Username)
+		recordLogin(r, d, d.user.Username, http.StatusUnauthorized)
 		return http.StatusUnauthorized, nil
 	}
 }
@@ -100,8 +119,7 @@ func printTOTPToken(w http.ResponseWriter, _ *http.Request, d *data, user *users
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString(d.settings.Key)
+	signed, err := signToken(d, claims)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
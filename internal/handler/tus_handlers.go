@@ -15,7 +15,9 @@ import (
 	"github.com/jellydator/ttlcache/v3"
 	"github.com/spf13/afero"
 
+	"github.com/nulnl/nulyun/internal/events"
 	"github.com/nulnl/nulyun/internal/files"
+	settings "github.com/nulnl/nulyun/internal/model/global"
 	"github.com/nulnl/nulyun/internal/model/users"
 )
 
@@ -149,6 +151,10 @@ func tusPostHandler() handleFunc {
 			}
 		}
 
+		if err := beforeHooks(r.Context(), d, settings.BeforeUpload, r.URL.Path, ""); err != nil {
+			return errToStatus(err), err
+		}
+
 		openFile, err := d.user.Fs.OpenFile(r.URL.Path, fileFlags, d.settings.FileMode)
 		if err != nil {
 			return errToStatus(err), err
@@ -286,6 +292,7 @@ func tusPatchHandler() handleFunc {
 				// Quota exceeded! Delete the file and return error
 				openFile.Close()
 				d.user.Fs.RemoveAll(r.URL.Path)
+				followDelete(d.store, d.user.Scope, r.URL.Path)
 				completeUpload(file.RealPath()) // Clean up upload tracking
 				return http.StatusInsufficientStorage, fmt.Errorf(
 					"storage quota exceeded during upload: current usage %d bytes exceeds quota %d bytes. Partial file deleted",
@@ -298,6 +305,9 @@ func tusPatchHandler() handleFunc {
 
 		if newOffset >= uploadLength {
 			completeUpload(file.RealPath())
+			publishEvent(d, events.UploadCompleted, r.URL.Path)
+			afterHooks(d, settings.AfterUpload, r.URL.Path, "")
+			scanUpload(d.store, d.server, d.user, r.URL.Path)
 		}
 
 		return http.StatusNoContent, nil
@@ -331,6 +341,7 @@ func tusDeleteHandler() handleFunc {
 		if err != nil {
 			return errToStatus(err), err
 		}
+		followDelete(d.store, d.user.Scope, r.URL.Path)
 
 		completeUpload(file.RealPath())
 
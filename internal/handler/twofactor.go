@@ -0,0 +1,101 @@
+package fbhttp
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/nulnl/nulyun/internal/auth"
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/users"
+)
+
+type twoFactorUserReport struct {
+	ID       uint     `json:"id"`
+	Username string   `json:"username"`
+	Admin    bool     `json:"admin"`
+	Groups   []string `json:"groups"`
+	Required bool     `json:"required"`
+	Enrolled bool     `json:"enrolled"`
+}
+
+type twoFactorReport struct {
+	Policy   settings.TwoFactorPolicy `json:"policy"`
+	Deadline int64                    `json:"deadline"`
+	Total    int                      `json:"total"`
+	Enrolled int                      `json:"enrolled"`
+	Required int                      `json:"required"`
+	Missing  int                      `json:"missing"`
+	Users    []twoFactorUserReport    `json:"users"`
+}
+
+// withEnrollment marks a handler as reachable by users that are restricted
+// to the enrollment of their second factor.
+func withEnrollment(fn handleFunc) handleFunc {
+	return func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		d.enrollment = true
+		return fn(w, r, d)
+	}
+}
+
+// twoFactorRequired checks if the policy forces the user to use TOTP and
+// if it can actually be enforced with the current configuration.
+func twoFactorRequired(d *data, u *users.User) bool {
+	if !d.server.EnableTOTP || !d.settings.TOTPEnabled || d.settings.AuthMethod == auth.MethodNoAuth {
+		return false
+	}
+
+	return d.settings.TwoFactor.Applies(u)
+}
+
+// twoFactorRestricted checks if the grace period of a user that must enroll
+// a second factor is over.
+func twoFactorRestricted(d *data, u *users.User) bool {
+	if u.TwoFactorEnrolled() || !twoFactorRequired(d, u) {
+		return false
+	}
+
+	return time.Now().After(d.settings.TwoFactor.Deadline())
+}
+
+var twoFactorReportHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	all, err := d.store.Users.Gets(d.server.Root)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	report := &twoFactorReport{
+		Policy:   d.settings.TwoFactor,
+		Deadline: d.settings.TwoFactor.Deadline().Unix(),
+		Users:    []twoFactorUserReport{},
+	}
+
+	for _, u := range all {
+		entry := twoFactorUserReport{
+			ID:       u.ID,
+			Username: u.Username,
+			Admin:    u.Perm.Admin,
+			Groups:   u.Groups,
+			Required: twoFactorRequired(d, u),
+			Enrolled: u.TwoFactorEnrolled(),
+		}
+
+		report.Total++
+		if entry.Enrolled {
+			report.Enrolled++
+		}
+		if entry.Required {
+			report.Required++
+			if !entry.Enrolled {
+				report.Missing++
+			}
+		}
+		report.Users = append(report.Users, entry)
+	}
+
+	sort.Slice(report.Users, func(i, j int) bool {
+		return report.Users[i].ID < report.Users[j].ID
+	})
+
+	return renderJSON(w, r, report)
+})
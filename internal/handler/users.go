@@ -17,13 +17,14 @@ import (
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 
+	"github.com/nulnl/nulyun/internal/events"
 	"github.com/nulnl/nulyun/internal/files" // Added by user
 	"github.com/nulnl/nulyun/internal/model/users"
 	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
 )
 
 var (
-	NonModifiableFieldsForNonAdmin = []string{"Username", "Scope", "LockPassword", "Perm"}
+	NonModifiableFieldsForNonAdmin = []string{"Username", "Scope", "LockPassword", "Perm", "Commands", "Groups", "LockedUntil", "Email", "AwaitingApproval", "AwaitingVerification", "Disabled", "ExpiresAt"}
 	TOTPIssuer                     = "nulyun"
 )
 
@@ -43,6 +44,7 @@ type createUserRequest struct {
 	ViewMode          users.ViewMode    `json:"viewMode"`
 	SingleClick       bool              `json:"singleClick"`
 	Perm              users.Permissions `json:"perm"`
+	Commands          []string          `json:"commands"`
 	Sorting           files.Sorting     `json:"sorting"`
 	HideDotfiles      bool              `json:"hideDotfiles"`
 	HideHiddenFolders bool              `json:"hideHiddenFolders"`
@@ -50,6 +52,9 @@ type createUserRequest struct {
 	AceEditorTheme    string            `json:"aceEditorTheme"`
 	TOTPEnabled       bool              `json:"totpEnabled"`
 	StorageQuota      string            `json:"storageQuota"` // Accept as string from frontend
+	Groups            []string          `json:"groups"`
+	Email             string            `json:"email"`
+	ExpiresAt         int64             `json:"expiresAt"`
 }
 
 type enableTOTPVerificationRequest struct {
@@ -161,15 +166,6 @@ var userGetHandler = withSelfOrAdmin(func(w http.ResponseWriter, r *http.Request
 	return renderJSON(w, r, response)
 })
 
-var userDeleteHandler = withSelfOrAdmin(func(_ http.ResponseWriter, _ *http.Request, d *data) (int, error) {
-	err := d.store.Users.Delete(d.raw.(uint))
-	if err != nil {
-		return errToStatus(err), err
-	}
-
-	return http.StatusOK, nil
-})
-
 var userPostHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
 	if r.Body == nil {
 		return http.StatusBadRequest, fberrors.ErrEmptyRequest
@@ -203,10 +199,14 @@ var userPostHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, d *
 		ViewMode:     createReq.Data.ViewMode,
 		SingleClick:  createReq.Data.SingleClick,
 		Perm:         createReq.Data.Perm,
+		Commands:     createReq.Data.Commands,
 		Sorting:      createReq.Data.Sorting,
 		HideDotfiles: createReq.Data.HideDotfiles, HideHiddenFolders: createReq.Data.HideHiddenFolders, DateFormat: createReq.Data.DateFormat,
 		AceEditorTheme: createReq.Data.AceEditorTheme,
 		TOTPEnabled:    createReq.Data.TOTPEnabled,
+		Groups:         createReq.Data.Groups,
+		Email:          createReq.Data.Email,
+		ExpiresAt:      createReq.Data.ExpiresAt,
 	}
 
 	newUser.Password, err = users.ValidateAndHashPwd(newUser.Password, d.settings.MinimumPasswordLength)
@@ -242,6 +242,8 @@ var userPostHandler = withAdmin(func(w http.ResponseWriter, r *http.Request, d *
 		return http.StatusInternalServerError, err
 	}
 
+	eventBus.Publish(events.Event{Type: events.UserCreated, UserID: newUser.ID, Username: newUser.Username})
+
 	w.Header().Set("Location", "/settings/users/"+strconv.FormatUint(uint64(newUser.ID), 10))
 	return http.StatusCreated, nil
 })
@@ -256,6 +258,7 @@ var userPutHandler = withSelfOrAdmin(func(w http.ResponseWriter, r *http.Request
 		return http.StatusBadRequest, nil
 	}
 
+	passwordChanged := false
 	if len(req.Which) == 0 || (len(req.Which) == 1 && req.Which[0] == "all") {
 		if !d.user.Perm.Admin {
 			return http.StatusForbidden, nil
@@ -266,6 +269,7 @@ var userPutHandler = withSelfOrAdmin(func(w http.ResponseWriter, r *http.Request
 			if err != nil {
 				return http.StatusBadRequest, err
 			}
+			passwordChanged = true
 		} else {
 			var suser *users.User
 			suser, err = d.store.Users.Get(d.server.Root, d.raw.(uint))
@@ -291,6 +295,7 @@ var userPutHandler = withSelfOrAdmin(func(w http.ResponseWriter, r *http.Request
 			if err != nil {
 				return http.StatusBadRequest, err
 			}
+			passwordChanged = true
 		}
 
 		for _, f := range NonModifiableFieldsForNonAdmin {
@@ -305,6 +310,17 @@ var userPutHandler = withSelfOrAdmin(func(w http.ResponseWriter, r *http.Request
 		return http.StatusInternalServerError, err
 	}
 
+	// A password change logs out every other session of the user.
+	if passwordChanged {
+		var keep []string
+		if req.Data.ID == d.user.ID {
+			keep = append(keep, d.session.ID)
+		}
+		if _, err := d.store.Sessions.RevokeAll(req.Data.ID, keep...); err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+
 	return http.StatusOK, nil
 })
 
@@ -0,0 +1,187 @@
+package fbhttp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"github.com/nulnl/nulyun/internal/files"
+	"github.com/nulnl/nulyun/internal/model/users"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+	storage "github.com/nulnl/nulyun/internal/repository"
+	"github.com/nulnl/nulyun/internal/service"
+)
+
+// What happens to the home directory of a deleted user.
+const (
+	deleteModeKeep     = "keep"
+	deleteModeTransfer = "transfer"
+	deleteModeArchive  = "archive"
+	deleteModePurge    = "purge"
+)
+
+var errSharedHome = errors.New("the home directory is shared with other users")
+
+type deleteUserReport struct {
+	storage.UserRecords
+	Mode              string `json:"mode"`
+	Home              string `json:"home"`
+	MovedTo           string `json:"movedTo,omitempty"`
+	Archive           string `json:"archive,omitempty"`
+	Purged            bool   `json:"purged"`
+	SharesTransferred bool   `json:"sharesTransferred"`
+}
+
+// checkOwnHome makes sure the home directory of a user belongs to it
+// alone, so it can be moved or removed without touching other users' files.
+func checkOwnHome(d *data, u *users.User) error {
+	home := path.Join("/", u.Scope)
+	if home == "/" {
+		return errSharedHome
+	}
+
+	all, err := d.store.Users.Gets(d.server.Root)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range all {
+		if o.ID == u.ID {
+			continue
+		}
+		if other := path.Join("/", o.Scope); other == home || strings.HasPrefix(other, home+"/") {
+			return errSharedHome
+		}
+	}
+
+	return nil
+}
+
+// transferHome moves the home directory of u into the home of target and
+// returns its new path, relative to the server root.
+func transferHome(d *data, u, target *users.User) (string, error) {
+	fs := afero.NewBasePathFs(afero.NewOsFs(), d.server.Root)
+
+	name := service.FileName(u)
+	dst := path.Join("/", target.Scope, name)
+	for i := 1; ; i++ {
+		if _, err := fs.Stat(dst); os.IsNotExist(err) {
+			break
+		}
+		dst = path.Join("/", target.Scope, name+"-"+strconv.Itoa(i))
+	}
+
+	return dst, files.MoveFile(fs, path.Join("/", u.Scope), dst, d.settings.FileMode, d.settings.DirMode)
+}
+
+// userDeleteHandler deletes a user and its dependent records. Admins can
+// choose what happens to its home directory with the mode query parameter:
+// keep it (default), transfer it with the shares to the user given by the
+// to parameter, archive it to a zip file in the archive path, or purge it.
+var userDeleteHandler = withSelfOrAdmin(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	id := d.raw.(uint)
+	if id == 1 {
+		return http.StatusForbidden, fberrors.ErrRootUserDeletion
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = deleteModeKeep
+	}
+	if mode != deleteModeKeep && !d.user.Perm.Admin {
+		return http.StatusForbidden, nil
+	}
+
+	u, err := d.store.Users.Get(d.server.Root, id)
+	if err != nil {
+		return errToStatus(err), err
+	}
+
+	report := &deleteUserReport{Mode: mode, Home: path.Join("/", u.Scope)}
+
+	var transfer *storage.ShareTransfer
+	var target *users.User
+	switch mode {
+	case deleteModeKeep:
+	case deleteModeTransfer:
+		targetID, err := strconv.ParseUint(r.URL.Query().Get("to"), 10, 0)
+		if err != nil || uint(targetID) == id {
+			return http.StatusBadRequest, fberrors.ErrInvalidRequestParams
+		}
+		target, err = d.store.Users.Get(d.server.Root, uint(targetID))
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		if err := checkOwnHome(d, u); err != nil {
+			return http.StatusConflict, err
+		}
+
+		report.MovedTo, err = transferHome(d, u, target)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		transfer = &storage.ShareTransfer{
+			UserID:     target.ID,
+			PathPrefix: strings.TrimPrefix(report.MovedTo, path.Join("/", target.Scope)),
+		}
+		report.SharesTransferred = true
+	case deleteModeArchive, deleteModePurge:
+		if err := checkOwnHome(d, u); err != nil {
+			return http.StatusConflict, err
+		}
+
+		if mode == deleteModeArchive {
+			dir := filepath.Join(d.server.Root, filepath.Join("/", d.settings.ArchivePath))
+			dst, err := service.ArchiveHome(r.Context(), d.server, u, dir)
+			if err != nil {
+				return http.StatusInternalServerError, fmt.Errorf("failed to archive the home directory: %w", err)
+			}
+			report.Archive = path.Join(d.settings.ArchivePath, filepath.Base(dst))
+		}
+	default:
+		return http.StatusBadRequest, fberrors.ErrInvalidRequestParams
+	}
+
+	records, err := d.store.UserDeleter.DeleteUser(id, transfer)
+	if err != nil {
+		if target != nil {
+			// Give the files back as the user still exists.
+			fs := afero.NewBasePathFs(afero.NewOsFs(), d.server.Root)
+			if err := files.MoveFile(fs, report.MovedTo, report.Home, d.settings.FileMode, d.settings.DirMode); err != nil {
+				log.Printf("failed to move %s back to %s: %v", report.MovedTo, report.Home, err)
+			}
+		}
+		return errToStatus(err), err
+	}
+	report.UserRecords = *records
+
+	if target != nil {
+		if err := d.store.Files.Move(report.Home, report.MovedTo); err != nil {
+			log.Printf("failed to move the file identities of %q: %v", u.Username, err)
+		}
+	}
+
+	if mode == deleteModeArchive || mode == deleteModePurge {
+		if err := os.RemoveAll(filepath.Join(d.server.Root, report.Home)); err != nil {
+			log.Printf("failed to remove the home directory of %q: %v", u.Username, err)
+		} else {
+			report.Purged = true
+			if err := d.store.Files.Delete(report.Home); err != nil {
+				log.Printf("failed to delete the file identities of %q: %v", u.Username, err)
+			}
+		}
+	}
+
+	log.Printf("deleted user %q (%s): %d shares, %d albums, %d WebDAV tokens, %d sessions, %d webhooks",
+		u.Username, mode, records.Shares, records.Albums, records.WebDAVTokens, records.Sessions, records.Webhooks)
+
+	return renderJSON(w, r, report)
+})
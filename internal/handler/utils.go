@@ -41,7 +41,7 @@ func errToStatus(err error) int {
 		return http.StatusForbidden
 	case errors.Is(err, libErrors.ErrInvalidRequestParams):
 		return http.StatusBadRequest
-	case errors.Is(err, libErrors.ErrRootUserDeletion):
+	case errors.Is(err, libErrors.ErrRootUserDeletion), errors.Is(err, libErrors.ErrHookVetoed):
 		return http.StatusForbidden
 	case errors.Is(err, filesErr.ErrImageTooLarge):
 		return http.StatusRequestEntityTooLarge
@@ -0,0 +1,179 @@
+package fbhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/nulnl/nulyun/internal/events"
+	"github.com/nulnl/nulyun/internal/model/webhook"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+	"github.com/nulnl/nulyun/internal/service"
+)
+
+// eventBufferSize is the number of events waiting to be dispatched to the
+// webhooks before new ones are dropped.
+const eventBufferSize = 256
+
+type webhookRequest struct {
+	URL        string   `json:"url"`
+	Events     []string `json:"events"`
+	PathPrefix string   `json:"pathPrefix"`
+	AllUsers   bool     `json:"allUsers"`
+	Active     *bool    `json:"active"`
+}
+
+// apply copies the request to a webhook. Only the webhooks of admins can
+// receive the events of every user, whoever edits them.
+func (req *webhookRequest) apply(d *data, h *webhook.Webhook) error {
+	if req.AllUsers {
+		owner := d.user
+		if h.UserID != d.user.ID {
+			var err error
+			if owner, err = d.store.Users.Get(d.server.Root, h.UserID); err != nil {
+				return err
+			}
+		}
+		if !owner.Perm.Admin {
+			return fberrors.ErrPermissionDenied
+		}
+	}
+
+	h.URL = req.URL
+	h.Events = req.Events
+	if h.Events == nil {
+		h.Events = []string{}
+	}
+	h.PathPrefix = ""
+	if req.PathPrefix != "" {
+		h.PathPrefix = path.Join("/", req.PathPrefix)
+	}
+	h.AllUsers = req.AllUsers
+	if req.Active != nil {
+		h.Active = *req.Active
+	}
+
+	return h.Validate()
+}
+
+// withWebhook loads the webhook of the request, which must belong to the
+// user unless it is an admin.
+func withWebhook(fn handleFunc) handleFunc {
+	return withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 0)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+
+		h, err := d.store.Webhooks.Get(uint(id))
+		if err != nil {
+			return errToStatus(err), err
+		}
+		if h.UserID != d.user.ID && !d.user.Perm.Admin {
+			return http.StatusNotFound, nil
+		}
+
+		d.raw = h
+		return fn(w, r, d)
+	})
+}
+
+func webhookStatus(err error) int {
+	switch {
+	case errors.Is(err, fberrors.ErrPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, webhook.ErrInvalidURL), errors.Is(err, webhook.ErrInvalidEvent):
+		return http.StatusBadRequest
+	}
+	return errToStatus(err)
+}
+
+var webhookListHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	var hooks []*webhook.Webhook
+	var err error
+	if d.user.Perm.Admin && r.URL.Query().Get("all") == "true" {
+		hooks, err = d.store.Webhooks.All()
+	} else {
+		hooks, err = d.store.Webhooks.FindByUserID(d.user.ID)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// The secret is only shown once.
+	for _, h := range hooks {
+		h.Secret = ""
+	}
+
+	return renderJSON(w, r, hooks)
+})
+
+var webhookPostHandler = withUser(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	h, err := webhook.New(d.user.ID, req.URL)
+	if err != nil {
+		return webhookStatus(err), err
+	}
+	if err := req.apply(d, h); err != nil {
+		return webhookStatus(err), err
+	}
+
+	if err := d.store.Webhooks.Save(h); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, h)
+})
+
+var webhookPutHandler = withWebhook(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	h := d.raw.(*webhook.Webhook)
+
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err := req.apply(d, h); err != nil {
+		return webhookStatus(err), err
+	}
+
+	if err := d.store.Webhooks.Save(h); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	h.Secret = ""
+	return renderJSON(w, r, h)
+})
+
+var webhookDeleteHandler = withWebhook(func(_ http.ResponseWriter, _ *http.Request, d *data) (int, error) {
+	err := d.store.Webhooks.Delete(d.raw.(*webhook.Webhook).ID)
+	return errToStatus(err), err
+})
+
+var webhookDeliveriesHandler = withWebhook(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+	deliveries, err := d.store.Webhooks.Deliveries(d.raw.(*webhook.Webhook).ID)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	return renderJSON(w, r, deliveries)
+})
+
+// webhookPingHandler sends a ping event to a webhook, without retries, and
+// returns the delivery.
+func webhookPingHandler(dispatcher *service.Webhooks) handleFunc {
+	return withWebhook(func(w http.ResponseWriter, r *http.Request, d *data) (int, error) {
+		h := d.raw.(*webhook.Webhook)
+		e := events.Event{Type: events.Ping, UserID: d.user.ID, Username: d.user.Username}
+		e.Stamp()
+
+		return renderJSON(w, r, dispatcher.Send(r.Context(), h, e, 1))
+	})
+}
@@ -0,0 +1,31 @@
+package fbhttp
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/nulnl/nulyun/internal/model/users"
+	"github.com/nulnl/nulyun/internal/model/webhook"
+)
+
+func TestWebhookAllUsersOwner(t *testing.T) {
+	s := newTestServer(t, nil,
+		&users.User{Username: "admin", Perm: users.Permissions{Admin: true}},
+		&users.User{Username: "bob"},
+	)
+	h, err := webhook.New(2, "https://example.com/hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.Webhooks.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"url":"https://example.com/hook","active":true,"allUsers":true}`
+	if rec := s.do(t, http.MethodPut, "/api/webhooks/1", s.token(t, 1), body); rec.Code != http.StatusForbidden {
+		t.Errorf("admin giving every user's events to bob: %d, want 403", rec.Code)
+	}
+	if h, err := s.store.Webhooks.Get(1); err != nil || h.AllUsers {
+		t.Errorf("webhook = %+v, %v, want it kept to bob's events", h, err)
+	}
+}
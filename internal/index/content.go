@@ -0,0 +1,191 @@
+package index
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	termsBucket    = []byte("terms")
+	contentsBucket = []byte("contents")
+
+	contentMaxSizeKey = []byte("contentMaxSize")
+)
+
+// headSize is the length of the start of the files given to IsText.
+const headSize = 512
+
+// ContentOptions are the options of the indexing of the content of the
+// files.
+type ContentOptions struct {
+	// MaxSize is the size beyond which the content of a file isn't indexed.
+	MaxSize int64
+	// IsText checks if a file is a text file from its name and the start of
+	// its content.
+	IsText func(name string, head []byte) bool
+}
+
+// openContent prepares the content index in tx. It is dropped when the
+// content isn't indexed anymore, as it wouldn't be kept current, and a new
+// generation is started when it was dropped or its options changed.
+func (ix *Index) openContent(tx *bolt.Tx, meta *bolt.Bucket) error {
+	if ix.content == nil {
+		for _, name := range [][]byte{termsBucket, contentsBucket} {
+			if tx.Bucket(name) == nil {
+				continue
+			}
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		for _, k := range [][]byte{contentGenKey, contentReadyKey, contentMaxSizeKey} {
+			if err := meta.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, name := range [][]byte{termsBucket, contentsBucket} {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+
+	maxSize := binary.BigEndian.AppendUint64(nil, uint64(ix.content.MaxSize))
+	if v := meta.Get(contentGenKey); len(v) == 8 && bytes.Equal(meta.Get(contentMaxSizeKey), maxSize) {
+		ix.contentGen = int64(binary.BigEndian.Uint64(v))
+	} else {
+		ix.contentGen = time.Now().UnixNano()
+		if err := meta.Put(contentGenKey, binary.BigEndian.AppendUint64(nil, uint64(ix.contentGen))); err != nil {
+			return err
+		}
+		if err := meta.Put(contentMaxSizeKey, maxSize); err != nil {
+			return err
+		}
+	}
+
+	if v := meta.Get(contentReadyKey); len(v) == 8 && int64(binary.BigEndian.Uint64(v)) == ix.contentGen {
+		ix.contentReady.Store(true)
+	}
+	return nil
+}
+
+// ContentReady checks if the content of all the files was indexed, so the
+// content searches can rely on ContentCandidates.
+func (ix *Index) ContentReady() bool {
+	return ix.content != nil && ix.contentReady.Load()
+}
+
+// staleContent checks if the content of the file of e has to be indexed.
+func (ix *Index) staleContent(e *Entry) bool {
+	return ix.content != nil && !e.Dir && !e.Symlink && e.ContentGen != ix.contentGen
+}
+
+// readTokens returns the tokens of the content of the file of e, nil when it
+// isn't a text file or is too large.
+func (ix *Index) readTokens(e *Entry) []string {
+	if e.Dir || e.Symlink || e.Size > ix.content.MaxSize {
+		return nil
+	}
+
+	f, err := os.Open(ix.diskPath(e.Path))
+	if err != nil {
+		// The unreadable files are indexed without their content, as
+		// they're skipped by the searches too.
+		return nil
+	}
+	defer f.Close()
+
+	head := make([]byte, headSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil
+	}
+	if !ix.content.IsText(e.Path, head[:n]) {
+		return nil
+	}
+
+	rest, err := io.ReadAll(io.LimitReader(f, ix.content.MaxSize-int64(n)))
+	if err != nil {
+		return nil
+	}
+	return Tokenize(string(head[:n]) + string(rest))
+}
+
+// posting returns the key of a token of the file of key k.
+func posting(token string, k []byte) []byte {
+	return append([]byte(token+"\x00"), k...)
+}
+
+// indexContent replaces the indexed tokens of the file of key k.
+func (ix *Index) indexContent(tx *bolt.Tx, k []byte, tokens []string) error {
+	terms, contents := tx.Bucket(termsBucket), tx.Bucket(contentsBucket)
+
+	if old := contents.Get(k); old != nil {
+		for _, token := range strings.Fields(string(old)) {
+			if err := terms.Delete(posting(token, k)); err != nil {
+				return err
+			}
+		}
+	}
+	if len(tokens) == 0 {
+		return contents.Delete(k)
+	}
+
+	for _, token := range tokens {
+		if err := terms.Put(posting(token, k), []byte{}); err != nil {
+			return err
+		}
+	}
+	return contents.Put(k, []byte(strings.Join(tokens, " ")))
+}
+
+// ContentCandidates returns the paths of the files whose indexed content
+// may contain phrase: the files with all of its words, the last one being
+// possibly incomplete. The result is nil when phrase has no words to look
+// up, in which case any file may contain it.
+func (ix *Index) ContentCandidates(phrase string) (map[string]bool, error) {
+	exact, prefix := queryTokens(phrase)
+	if len(exact) == 0 && prefix == "" {
+		return nil, nil
+	}
+
+	var candidates map[string]bool
+	err := ix.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(termsBucket).Cursor()
+		lookup := func(p string) {
+			found := map[string]bool{}
+			for k, _ := c.Seek([]byte(p)); k != nil && bytes.HasPrefix(k, []byte(p)); k, _ = c.Next() {
+				name := pathOf(k[bytes.IndexByte(k, 0)+1:])
+				if candidates == nil || candidates[name] {
+					found[name] = true
+				}
+			}
+			candidates = found
+		}
+
+		for _, token := range exact {
+			lookup(token + "\x00")
+			if len(candidates) == 0 {
+				return nil
+			}
+		}
+		if prefix != "" {
+			lookup(prefix)
+		}
+		return nil
+	})
+	return candidates, err
+}
+
+// OpenFile opens the file name, relative to the root, to read its content.
+func (ix *Index) OpenFile(name string) (*os.File, error) {
+	return os.Open(ix.diskPath(clean(name)))
+}
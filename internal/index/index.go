@@ -0,0 +1,500 @@
+// Package index keeps a persistent index of the files of the root, so the
+// searches don't have to walk the disk. The index is kept current by the
+// file events and periodically reconciled with the disk.
+package index
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/nulnl/nulyun/internal/media"
+)
+
+var (
+	filesBucket = []byte("files")
+	metaBucket  = []byte("meta")
+
+	reconciledKey   = []byte("reconciled")
+	durationKey     = []byte("duration")
+	contentGenKey   = []byte("contentGen")
+	contentReadyKey = []byte("contentReady")
+)
+
+// Entry is an indexed file.
+type Entry struct {
+	// Path is the path of the file, relative to the root.
+	Path    string `json:"-"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"` // in Unix nanoseconds
+	Dir     bool   `json:"dir,omitempty"`
+	Symlink bool   `json:"symlink,omitempty"`
+	// Type is the MIME type of the extension of the file, if known.
+	Type string `json:"type,omitempty"`
+	// ContentGen is the generation of the content index the content of
+	// the file was indexed for.
+	ContentGen int64 `json:"contentGen,omitempty"`
+	// Media is the metadata of an image, read by the version MediaVersion
+	// of the index.
+	Media        *media.Metadata `json:"media,omitempty"`
+	MediaVersion int             `json:"mediaVersion,omitempty"`
+}
+
+func newEntry(name string, info fs.FileInfo) *Entry {
+	e := &Entry{
+		Path:    name,
+		Size:    info.Size(),
+		ModTime: info.ModTime().UnixNano(),
+		Dir:     info.IsDir(),
+		Symlink: info.Mode()&fs.ModeSymlink != 0,
+	}
+	if !e.Dir {
+		e.Type = mime.TypeByExtension(path.Ext(name))
+	}
+	return e
+}
+
+// isImage checks if the metadata of the file of e is read.
+func (e *Entry) isImage() bool {
+	return !e.Dir && !e.Symlink && strings.HasPrefix(e.Type, "image/")
+}
+
+// MediaStale checks if the metadata of the image of e wasn't read yet by
+// this version of the index.
+func (e *Entry) MediaStale() bool {
+	return e.isImage() && e.MediaVersion != mediaVersion
+}
+
+// Info returns the entry as a fs.FileInfo.
+func (e *Entry) Info() fs.FileInfo {
+	return entryInfo{e}
+}
+
+// same checks if two entries describe the same state of a file.
+func (e *Entry) same(o *Entry) bool {
+	return e.Size == o.Size && e.ModTime == o.ModTime && e.Dir == o.Dir && e.Symlink == o.Symlink
+}
+
+type entryInfo struct {
+	e *Entry
+}
+
+func (i entryInfo) Name() string       { return path.Base(i.e.Path) }
+func (i entryInfo) Size() int64        { return i.e.Size }
+func (i entryInfo) ModTime() time.Time { return time.Unix(0, i.e.ModTime) }
+func (i entryInfo) IsDir() bool        { return i.e.Dir }
+func (i entryInfo) Sys() any           { return nil }
+
+func (i entryInfo) Mode() fs.FileMode {
+	switch {
+	case i.e.Dir:
+		return fs.ModeDir | 0o755
+	case i.e.Symlink:
+		return fs.ModeSymlink | 0o777
+	default:
+		return 0o644
+	}
+}
+
+// mediaVersion is the version of the metadata of the images. The images
+// are read again when it changes.
+const mediaVersion = 1
+
+// readMedia returns the metadata of the image name, nil if it has none or
+// can't be read.
+func (ix *Index) readMedia(name string) *media.Metadata {
+	f, err := os.Open(ix.diskPath(name))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	m, _ := media.Read(f)
+	return m
+}
+
+// Index is a persistent index of the files of a root directory. Files are
+// keyed by their directory and name, separated by a null byte, so both the
+// children and the descendants of a directory are contiguous.
+type Index struct {
+	db   *bolt.DB
+	root string
+
+	// interval is the delay between two reconciliations.
+	interval time.Duration
+	// reconciled and duration are the end and the duration of the last
+	// reconciliation, in Unix nanoseconds.
+	reconciled atomic.Int64
+	duration   atomic.Int64
+	// dirty is set when an update failed, until the next reconciliation.
+	dirty atomic.Bool
+
+	// content is nil when the content of the files isn't indexed.
+	content *ContentOptions
+	// contentGen is the generation of the content index, and contentReady
+	// is set once a reconciliation indexed the content of all the files
+	// for it.
+	contentGen   int64
+	contentReady atomic.Bool
+}
+
+// Open opens the index stored in file of the files of root, creating it if
+// needed. The content of the text files is indexed too when content isn't
+// nil.
+func Open(file, root string, interval time.Duration, content *ContentOptions) (*Index, error) {
+	db, err := bolt.Open(file, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	ix := &Index{db: db, root: root, interval: interval, content: content}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(filesBucket); err != nil {
+			return err
+		}
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+
+		if v := meta.Get(reconciledKey); len(v) == 8 {
+			ix.reconciled.Store(int64(binary.BigEndian.Uint64(v)))
+		}
+		if v := meta.Get(durationKey); len(v) == 8 {
+			ix.duration.Store(int64(binary.BigEndian.Uint64(v)))
+		}
+		return ix.openContent(tx, meta)
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return ix, nil
+}
+
+// Close closes the index.
+func (ix *Index) Close() error {
+	return ix.db.Close()
+}
+
+// Fresh checks if the index can be trusted: it was reconciled with the
+// disk, no update failed since then, and the reconciliations are keeping
+// up with their interval.
+func (ix *Index) Fresh() bool {
+	reconciled := ix.reconciled.Load()
+	if reconciled == 0 || ix.dirty.Load() {
+		return false
+	}
+	if ix.interval <= 0 {
+		return true
+	}
+
+	maxAge := 2*ix.interval + time.Duration(ix.duration.Load())
+	return time.Since(time.Unix(0, reconciled)) < maxAge
+}
+
+// key returns the key of the file name, relative to the root.
+func key(name string) []byte {
+	return []byte(path.Dir(name) + "\x00" + path.Base(name))
+}
+
+// pathOf returns the path of the file of a key.
+func pathOf(k []byte) string {
+	i := bytes.LastIndexByte(k, 0)
+	return path.Join(string(k[:i]), string(k[i+1:]))
+}
+
+// clean returns the path name relative to the root, starting with a slash.
+func clean(name string) string {
+	return path.Join("/", filepath.ToSlash(name))
+}
+
+// prefixes returns the key prefixes of the descendants of dir.
+func prefixes(dir string) [][]byte {
+	if dir == "/" {
+		return [][]byte{[]byte("/")}
+	}
+	return [][]byte{[]byte(dir + "\x00"), []byte(dir + "/")}
+}
+
+// Walk calls fn for each indexed file inside dir, which isn't included,
+// depth-first and by name, as filepath.Walk does. When fn returns
+// fs.SkipDir on a directory, its content is skipped, and on a file, the
+// rest of its directory. Walk stops at the first other error returned by
+// fn, and returns it.
+func (ix *Index) Walk(dir string, fn func(e *Entry) error) error {
+	dir = clean(dir)
+
+	return ix.db.View(func(tx *bolt.Tx) error {
+		err := walk(tx.Bucket(filesBucket), dir, fn)
+		if errors.Is(err, fs.SkipDir) {
+			return nil
+		}
+		return err
+	})
+}
+
+func walk(bucket *bolt.Bucket, dir string, fn func(e *Entry) error) error {
+	prefix := []byte(dir + "\x00")
+	c := bucket.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		e := &Entry{}
+		if err := json.Unmarshal(v, e); err != nil {
+			return err
+		}
+		e.Path = pathOf(k)
+
+		err := fn(e)
+		switch {
+		case errors.Is(err, fs.SkipDir) && e.Dir:
+			continue
+		case err != nil:
+			return err
+		}
+
+		if e.Dir {
+			if err := walk(bucket, e.Path, fn); err != nil && !errors.Is(err, fs.SkipDir) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Get returns the indexed file name, or nil if it isn't indexed.
+func (ix *Index) Get(name string) (*Entry, error) {
+	name = clean(name)
+
+	var e *Entry
+	err := ix.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(filesBucket).Get(key(name))
+		if v == nil {
+			return nil
+		}
+		e = &Entry{Path: name}
+		return json.Unmarshal(v, e)
+	})
+	return e, err
+}
+
+// Sync updates the file name, relative to the root, from the disk. The
+// content of a directory that wasn't indexed yet is indexed too.
+func (ix *Index) Sync(name string) error {
+	name = clean(name)
+	if name == "/" {
+		return nil
+	}
+
+	old, err := ix.Get(name)
+	if err != nil {
+		return ix.failed(err)
+	}
+
+	b := ix.newBatch()
+	b.add(name)
+	if err := b.flush(); err != nil {
+		return ix.failed(err)
+	}
+
+	if old == nil {
+		if info, err := os.Lstat(ix.diskPath(name)); err == nil && info.IsDir() {
+			if err := ix.reconcileTree(context.Background(), b, name); err != nil {
+				return ix.failed(err)
+			}
+		}
+	}
+
+	return ix.failed(b.flush())
+}
+
+// failed marks the index dirty when err isn't nil, and returns it.
+func (ix *Index) failed(err error) error {
+	if err != nil {
+		ix.dirty.Store(true)
+	}
+	return err
+}
+
+func (ix *Index) diskPath(name string) string {
+	return filepath.Join(ix.root, filepath.FromSlash(name))
+}
+
+// batchSize is the number of paths updated by a transaction.
+const batchSize = 1000
+
+// batch collects the paths to update from the disk.
+type batch struct {
+	ix    *Index
+	names []string
+}
+
+func (ix *Index) newBatch() *batch {
+	return &batch{ix: ix}
+}
+
+func (b *batch) add(name string) error {
+	b.names = append(b.names, name)
+	if len(b.names) >= batchSize {
+		return b.flush()
+	}
+	return nil
+}
+
+// flush updates the collected paths with their state on the disk at this
+// time, so changes made after they were collected aren't undone. The files
+// are read before the transaction, which doesn't wait for the disk.
+func (b *batch) flush() error {
+	if len(b.names) == 0 {
+		return nil
+	}
+	defer func() { b.names = b.names[:0] }()
+
+	updates := make([]*update, 0, len(b.names))
+	for _, name := range b.names {
+		u, err := b.ix.read(name)
+		if err != nil {
+			return err
+		}
+		updates = append(updates, u)
+	}
+
+	return b.ix.db.Update(func(tx *bolt.Tx) error {
+		for _, u := range updates {
+			if err := b.ix.write(tx, u); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// update is the state of a file to save in the index.
+type update struct {
+	name string
+	// entry is nil when the file was removed.
+	entry *Entry
+	// tokens replace the indexed content of the file when reindex is set.
+	tokens  []string
+	reindex bool
+}
+
+// read returns the update of the file name from the disk.
+func (ix *Index) read(name string) (*update, error) {
+	info, err := os.Lstat(ix.diskPath(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &update{name: name}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	u := &update{name: name, entry: newEntry(name, info)}
+	if ix.content == nil && !u.entry.isImage() {
+		return u, nil
+	}
+
+	old, err := ix.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	unchanged := old != nil && old.same(u.entry)
+
+	if u.entry.isImage() {
+		if unchanged && !old.MediaStale() {
+			u.entry.Media = old.Media
+		} else {
+			u.entry.Media = ix.readMedia(name)
+		}
+		u.entry.MediaVersion = mediaVersion
+	}
+
+	if ix.content == nil {
+		return u, nil
+	}
+	if unchanged && old.ContentGen == ix.contentGen {
+		u.entry.ContentGen = old.ContentGen
+		return u, nil
+	}
+
+	u.reindex = true
+	u.entry.ContentGen = ix.contentGen
+	u.tokens = ix.readTokens(u.entry)
+	return u, nil
+}
+
+// write saves an update.
+func (ix *Index) write(tx *bolt.Tx, u *update) error {
+	if u.entry == nil {
+		return ix.deleteTree(tx, u.name)
+	}
+
+	v, err := json.Marshal(u.entry)
+	if err != nil {
+		return err
+	}
+	k := key(u.name)
+	if err := tx.Bucket(filesBucket).Put(k, v); err != nil {
+		return err
+	}
+
+	if !u.reindex {
+		return nil
+	}
+	return ix.indexContent(tx, k, u.tokens)
+}
+
+// deleteTree deletes the file name and, if it is a directory, its content.
+func (ix *Index) deleteTree(tx *bolt.Tx, name string) error {
+	bucket := tx.Bucket(filesBucket)
+	keys := [][]byte{key(name)}
+	c := bucket.Cursor()
+	for _, prefix := range prefixes(name) {
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			keys = append(keys, bytes.Clone(k))
+		}
+	}
+
+	for _, k := range keys {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+		if ix.content != nil {
+			if err := ix.indexContent(tx, k, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// children returns the indexed files of dir by name.
+func (ix *Index) children(dir string) (map[string]*Entry, error) {
+	children := map[string]*Entry{}
+	prefix := []byte(dir + "\x00")
+
+	err := ix.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(filesBucket).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			e := &Entry{}
+			if err := json.Unmarshal(v, e); err != nil {
+				return err
+			}
+			children[string(k[len(prefix):])] = e
+		}
+		return nil
+	})
+	return children, err
+}
@@ -0,0 +1,176 @@
+package index
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/nulnl/nulyun/internal/events"
+)
+
+func TestIndex(t *testing.T) {
+	root := t.TempDir()
+	write := func(name string) {
+		t.Helper()
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list := func(ix *Index, dir string) []string {
+		t.Helper()
+		var names []string
+		if err := ix.Walk(dir, func(e *Entry) error {
+			names = append(names, e.Path)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	write("/a/b.txt")
+	write("/a/c/d.jpg")
+	write("/a-b/e.txt")
+
+	file := filepath.Join(t.TempDir(), "search.db")
+	ix, err := Open(file, root, time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.Fresh() {
+		t.Error("Fresh() = true before the first reconciliation")
+	}
+	if err := ix.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !ix.Fresh() {
+		t.Error("Fresh() = false after a reconciliation")
+	}
+
+	if got, want := list(ix, "/a"), []string{"/a/b.txt", "/a/c", "/a/c/d.jpg"}; !slices.Equal(got, want) {
+		t.Errorf("Walk(/a) = %q, want %q", got, want)
+	}
+	if got := list(ix, "/"); len(got) != 6 {
+		t.Errorf("Walk(/) = %q, want 6 files", got)
+	}
+	if e, _ := ix.Get("/a/c/d.jpg"); e == nil || e.Type != "image/jpeg" || e.Size != int64(len("/a/c/d.jpg")) {
+		t.Errorf("Get() = %+v", e)
+	}
+
+	// Events update the files, and the content of new directories.
+	write("/a/new/f.txt")
+	if err := os.RemoveAll(filepath.Join(root, "a", "c")); err != nil {
+		t.Fatal(err)
+	}
+	ix.apply(&events.Event{Type: events.FileCreated, Scope: "/a", Path: "/new"})
+	ix.apply(&events.Event{Type: events.FileDeleted, Scope: "/", Path: "/a/c"})
+	if got, want := list(ix, "/a"), []string{"/a/b.txt", "/a/new", "/a/new/f.txt"}; !slices.Equal(got, want) {
+		t.Errorf("Walk(/a) after the events = %q, want %q", got, want)
+	}
+
+	// Changes made behind its back are caught up by the reconciliation,
+	// which persists.
+	write("/a-b/g.txt")
+	if err := os.Remove(filepath.Join(root, "a", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	ix, err = Open(file, root, time.Hour, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	if !ix.Fresh() {
+		t.Error("Fresh() = false after reopening")
+	}
+	want := []string{"/a", "/a-b", "/a-b/e.txt", "/a-b/g.txt", "/a/new", "/a/new/f.txt"}
+	if got := list(ix, "/"); !slices.Equal(got, want) {
+		t.Errorf("Walk(/) after the reconciliation = %q, want %q", got, want)
+	}
+}
+
+func TestContent(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	candidates := func(ix *Index, phrase string) []string {
+		t.Helper()
+		found, err := ix.ContentCandidates(phrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for name := range found {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	write("en.txt", "The quick brown Fox jumps")
+	write("ja.txt", "東京タワーに行きました")
+	write("big.txt", "quick "+string(make([]byte, 100)))
+	write("image.bin", "\x00\x01quick")
+
+	isText := func(_ string, head []byte) bool { return !slices.Contains(head, 0) }
+	ix, err := Open(filepath.Join(t.TempDir(), "search.db"), root, time.Hour, &ContentOptions{MaxSize: 64, IsText: isText})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ix.Close()
+	if err := ix.Reconcile(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !ix.ContentReady() {
+		t.Error("ContentReady() = false after a reconciliation")
+	}
+
+	testCases := map[string]struct {
+		phrase string
+		want   []string
+	}{
+		"word":              {"fox", []string{"/en.txt"}},
+		"words and prefix":  {"QUICK bro", []string{"/en.txt"}},
+		"missing word":      {"quick red", nil},
+		"han":               {"東京", []string{"/ja.txt"}},
+		"katakana":          {"タワー", []string{"/ja.txt"}},
+		"unspaced mismatch": {"京東", nil},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := candidates(ix, tc.phrase); !slices.Equal(got, tc.want) {
+				t.Errorf("ContentCandidates(%q) = %q, want %q", tc.phrase, got, tc.want)
+			}
+		})
+	}
+
+	// The content of the changed files is indexed again.
+	write("en.txt", "A lazy dog")
+	if err := ix.Sync("/en.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got := candidates(ix, "fox"); len(got) != 0 {
+		t.Errorf("ContentCandidates(fox) after a change = %q", got)
+	}
+	if got := candidates(ix, "lazy"); !slices.Equal(got, []string{"/en.txt"}) {
+		t.Errorf("ContentCandidates(lazy) after a change = %q", got)
+	}
+}
@@ -0,0 +1,181 @@
+package index
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/nulnl/nulyun/internal/events"
+)
+
+// Reconcile updates the index with the files on the disk: the missing and
+// changed files are indexed, the removed ones deleted.
+func (ix *Index) Reconcile(ctx context.Context) error {
+	start := time.Now()
+
+	b := ix.newBatch()
+	if err := ix.reconcileTree(ctx, b, "/"); err != nil {
+		return err
+	}
+	if err := b.flush(); err != nil {
+		return err
+	}
+
+	end := time.Now()
+	err := ix.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if err := meta.Put(reconciledKey, binary.BigEndian.AppendUint64(nil, uint64(end.UnixNano()))); err != nil {
+			return err
+		}
+		if ix.content != nil {
+			if err := meta.Put(contentReadyKey, binary.BigEndian.AppendUint64(nil, uint64(ix.contentGen))); err != nil {
+				return err
+			}
+		}
+		return meta.Put(durationKey, binary.BigEndian.AppendUint64(nil, uint64(end.Sub(start))))
+	})
+	if err != nil {
+		return err
+	}
+
+	if ix.content != nil {
+		ix.contentReady.Store(true)
+	}
+	ix.reconciled.Store(end.UnixNano())
+	ix.duration.Store(int64(end.Sub(start)))
+	ix.dirty.Store(false)
+	return nil
+}
+
+// reconcileTree adds to b the files of dir and of its subdirectories that
+// differ from the index.
+func (ix *Index) reconcileTree(ctx context.Context, b *batch, dir string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(ix.diskPath(dir))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return b.add(dir)
+	case err != nil:
+		// Unreadable directories are skipped, as by the walks.
+		return nil
+	}
+
+	indexed, err := ix.children(dir)
+	if err != nil {
+		return err
+	}
+
+	var subdirs []string
+	for _, de := range entries {
+		name := path.Join(dir, de.Name())
+		old := indexed[de.Name()]
+		delete(indexed, de.Name())
+
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		if old == nil || !old.same(newEntry(name, info)) || ix.staleContent(old) || old.MediaStale() {
+			if err := b.add(name); err != nil {
+				return err
+			}
+		}
+		if de.IsDir() {
+			subdirs = append(subdirs, name)
+		}
+	}
+
+	for name := range indexed {
+		if err := b.add(path.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+
+	for _, sub := range subdirs {
+		if err := ix.reconcileTree(ctx, b, sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run keeps the index current until ctx is done: the files of the events
+// received on ch are updated and the whole index is reconciled at start
+// and then on every interval.
+func (ix *Index) Run(ctx context.Context, ch <-chan events.Event) {
+	reconcile := make(chan struct{}, 1)
+	reconcile <- struct{}{}
+
+	if ix.interval > 0 {
+		go func() {
+			ticker := time.NewTicker(ix.interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					select {
+					case reconcile <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	// The reconciliations run aside, so the events are applied meanwhile.
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reconcile:
+				if err := ix.Reconcile(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("failed to reconcile the search index: %v", err)
+				}
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			ix.apply(&e)
+		}
+	}
+}
+
+// apply updates the files of an event.
+func (ix *Index) apply(e *events.Event) {
+	switch e.Type {
+	case events.FileCreated, events.FileModified, events.FileDeleted, events.FileMoved,
+		events.UploadCompleted, events.FileQuarantined:
+	default:
+		return
+	}
+
+	for _, name := range []string{e.Path, e.Destination} {
+		if name == "" {
+			continue
+		}
+		if err := ix.Sync(path.Join("/", e.Scope, name)); err != nil {
+			log.Printf("failed to update the search index of %s: %v", name, err)
+		}
+	}
+}
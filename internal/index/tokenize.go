@@ -0,0 +1,125 @@
+package index
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// maxTokenLength is the length, in characters, beyond which words aren't
+// indexed, as they are rather encoded data than words.
+const maxTokenLength = 64
+
+// Fold normalizes s for the comparisons of the content searches: the
+// compatibility characters are replaced (NFKC), the case is folded unless
+// caseSensitive, and the runs of white spaces are replaced by a space.
+func Fold(s string, caseSensitive bool) string {
+	s = norm.NFKC.String(s)
+	if !caseSensitive {
+		s = cases.Fold().String(s)
+	}
+
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	if space && b.Len() > 0 {
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+// unspaced checks if r belongs to a script written without spaces between
+// the words.
+func unspaced(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana,
+		unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar, unicode.Tibetan)
+}
+
+// wordRune checks if r is part of a word, combining marks included.
+func wordRune(r rune) bool {
+	return unicode.In(r, unicode.Letter, unicode.Digit, unicode.Mn, unicode.Mc)
+}
+
+// IsWordStart checks if next, following prev, is at the start of a word.
+// Any character of the scripts written without spaces starts a word.
+func IsWordStart(prev, next rune) bool {
+	return !wordRune(prev) || unspaced(prev) || unspaced(next)
+}
+
+// Tokenize splits a text into the distinct tokens indexed for it: its
+// folded words and, for the scripts written without spaces, each of their
+// characters and pairs of consecutive characters.
+func Tokenize(text string) []string {
+	seen := map[string]bool{}
+	var tokens []string
+	add := func(token string) {
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	var word, run []rune
+	flushWord := func() {
+		if len(word) > 0 && len(word) <= maxTokenLength {
+			add(string(word))
+		}
+		word = word[:0]
+	}
+	flushRun := func() {
+		for i := range run {
+			add(string(run[i]))
+			if i+1 < len(run) {
+				add(string(run[i : i+2]))
+			}
+		}
+		run = run[:0]
+	}
+
+	for _, r := range Fold(text, false) {
+		switch {
+		case unspaced(r):
+			flushWord()
+			run = append(run, r)
+		case wordRune(r):
+			flushRun()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushRun()
+		}
+	}
+	flushWord()
+	flushRun()
+
+	return tokens
+}
+
+// queryTokens returns the tokens of the words of a phrase, and the start
+// of its last word when the phrase ends within it, as it may be followed
+// by other characters.
+func queryTokens(phrase string) ([]string, string) {
+	folded := []rune(Fold(phrase, false))
+	end := len(folded)
+	for end > 0 && wordRune(folded[end-1]) && !unspaced(folded[end-1]) {
+		end--
+	}
+
+	prefix := string(folded[end:])
+	if len(folded[end:]) > maxTokenLength {
+		prefix = ""
+	}
+	return Tokenize(string(folded[:end])), prefix
+}
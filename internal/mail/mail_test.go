@@ -0,0 +1,119 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSMTP is a minimal SMTP server recording the messages it receives.
+type fakeSMTP struct {
+	ln       net.Listener
+	messages chan string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeSMTP{ln: ln, messages: make(chan string, 1)}
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTP) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTP) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case cmd == "DATA":
+			reply("354 go ahead")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.messages <- data.String()
+			reply("250 queued")
+		case cmd == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	srv := newFakeSMTP(t)
+	host, port, _ := net.SplitHostPort(srv.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+
+	m := &SMTP{Host: host, Port: p, From: "nulyun@example.com"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := m.Send(ctx, &Message{
+		To:      []string{"user@example.com"},
+		Subject: "Hello\r\nBcc: evil@example.com",
+		Body:    "line 1\nline 2",
+	})
+	if err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	select {
+	case msg := <-srv.messages:
+		if !strings.Contains(msg, "To: user@example.com\r\n") {
+			t.Errorf("missing recipient header in %q", msg)
+		}
+		if strings.Contains(msg, "\r\nBcc:") {
+			t.Errorf("header injected in %q", msg)
+		}
+		if !strings.HasSuffix(msg, "line 1\r\nline 2\r\n") {
+			t.Errorf("unexpected body in %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestSMTPNotConfigured(t *testing.T) {
+	if err := (&SMTP{}).Send(context.Background(), &Message{}); err != ErrNotConfigured {
+		t.Errorf("expected ErrNotConfigured, got %v", err)
+	}
+}
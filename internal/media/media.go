@@ -0,0 +1,206 @@
+// Package media reads the metadata of the photos: the date they were taken,
+// the camera, the lens, the location and the dimensions.
+package media
+
+import (
+	"errors"
+	"image"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/dsoprea/go-exif/v3"
+	exifcommon "github.com/dsoprea/go-exif/v3/common"
+
+	// The decoders of the dimensions of the images without EXIF.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// maxExifOffset is the length of the start of a file searched for EXIF.
+const maxExifOffset = 1 << 20
+
+// Metadata is the metadata of a photo.
+type Metadata struct {
+	// Taken is the date the photo was taken, in the time zone of the
+	// camera when it is known, else in the local time zone.
+	Taken  time.Time `json:"taken,omitzero"`
+	Make   string    `json:"make,omitempty"`
+	Model  string    `json:"model,omitempty"`
+	Lens   string    `json:"lens,omitempty"`
+	Width  int       `json:"width,omitempty"`
+	Height int       `json:"height,omitempty"`
+	// Location is where the photo was taken, if known.
+	Location *Location `json:"location,omitempty"`
+}
+
+// Location is a position in decimal degrees.
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Camera returns the make and the model of the camera, without the make
+// when the model already starts with it.
+func (m *Metadata) Camera() string {
+	if m.Make == "" || strings.HasPrefix(strings.ToLower(m.Model), strings.ToLower(m.Make)) {
+		return m.Model
+	}
+	return strings.TrimSpace(m.Make + " " + m.Model)
+}
+
+// earthRadius is the mean radius of the Earth, in meters.
+const earthRadius = 6371008.8
+
+// Distance returns the distance to o along the surface of the Earth, in
+// meters.
+func (l *Location) Distance(o *Location) float64 {
+	lat1, lat2 := l.Latitude*math.Pi/180, o.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (o.Longitude - l.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(min(h, 1)))
+}
+
+// ErrNoMetadata is returned when a file has no metadata.
+var ErrNoMetadata = errors.New("no metadata")
+
+// Read reads the metadata of the image r. The dimensions of the images
+// without EXIF are read from their header.
+func Read(r io.ReadSeeker) (*Metadata, error) {
+	m := &Metadata{}
+	raw, err := exif.SearchAndExtractExifWithReader(io.LimitReader(r, maxExifOffset))
+	if err == nil {
+		readExif(m, raw)
+	}
+
+	if m.Width == 0 || m.Height == 0 {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if config, _, err := image.DecodeConfig(r); err == nil {
+			m.Width, m.Height = config.Width, config.Height
+		}
+	}
+
+	if m.Taken.IsZero() && m.Make == "" && m.Model == "" && m.Width == 0 && m.Location == nil {
+		return nil, ErrNoMetadata
+	}
+	return m, nil
+}
+
+// exifTimeLayout is the layout of the dates of EXIF.
+const exifTimeLayout = "2006:01:02 15:04:05"
+
+// readExif fills m with the tags of raw. Invalid tags are ignored.
+func readExif(m *Metadata, raw []byte) {
+	tags, _, err := exif.GetFlatExifData(raw, nil)
+	if err != nil {
+		return
+	}
+
+	var taken, offset, latRef, lonRef string
+	var lat, lon []exifcommon.Rational
+	for _, tag := range tags {
+		switch tag.IfdPath {
+		case "IFD", "IFD/Exif", "IFD/GPSInfo":
+		default:
+			continue
+		}
+
+		switch v := tag.Value.(type) {
+		case string:
+			v = strings.TrimSpace(strings.TrimRight(v, "\x00"))
+			switch tag.TagName {
+			case "Make":
+				m.Make = v
+			case "Model":
+				m.Model = v
+			case "LensModel":
+				m.Lens = v
+			case "DateTimeOriginal":
+				taken = v
+			case "DateTime":
+				if taken == "" {
+					taken = v
+				}
+			case "OffsetTimeOriginal":
+				offset = v
+			case "GPSLatitudeRef":
+				latRef = v
+			case "GPSLongitudeRef":
+				lonRef = v
+			}
+		case []uint16:
+			if len(v) > 0 {
+				setDimension(m, tag.TagName, int(v[0]))
+			}
+		case []uint32:
+			if len(v) > 0 {
+				setDimension(m, tag.TagName, int(v[0]))
+			}
+		case []exifcommon.Rational:
+			switch tag.TagName {
+			case "GPSLatitude":
+				lat = v
+			case "GPSLongitude":
+				lon = v
+			}
+		}
+	}
+
+	m.Taken = parseTime(taken, offset)
+	m.Location = parseLocation(latRef, lat, lonRef, lon)
+}
+
+func setDimension(m *Metadata, name string, v int) {
+	switch name {
+	case "PixelXDimension":
+		m.Width = v
+	case "PixelYDimension":
+		m.Height = v
+	}
+}
+
+// parseTime parses a date of EXIF, in the time zone of offset if set.
+func parseTime(value, offset string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+
+	if offset != "" {
+		if t, err := time.Parse(exifTimeLayout+"-07:00", value+offset); err == nil {
+			return t
+		}
+	}
+	t, err := time.ParseInLocation(exifTimeLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// parseLocation converts the GPS coordinates of EXIF to decimal degrees.
+func parseLocation(latRef string, lat []exifcommon.Rational, lonRef string, lon []exifcommon.Rational) *Location {
+	if len(lat) != 3 || len(lon) != 3 || latRef == "" || lonRef == "" {
+		return nil
+	}
+	latitude, err := exif.NewGpsDegreesFromRationals(latRef, lat)
+	if err != nil {
+		return nil
+	}
+	longitude, err := exif.NewGpsDegreesFromRationals(lonRef, lon)
+	if err != nil {
+		return nil
+	}
+
+	l := &Location{Latitude: latitude.Decimal(), Longitude: longitude.Decimal()}
+	valid := func(v, limit float64) bool { return !math.IsNaN(v) && math.Abs(v) <= limit }
+	if !valid(l.Latitude, 90) || !valid(l.Longitude, 180) || (l.Latitude == 0 && l.Longitude == 0) {
+		return nil
+	}
+	return l
+}
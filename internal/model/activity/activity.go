@@ -0,0 +1,36 @@
+// Package activity keeps what the users recently did to the files, for
+// their recent files and the activity feed of the others.
+package activity
+
+// Actions of the items.
+const (
+	ActionCreated  = "created"
+	ActionModified = "modified"
+	ActionOpened   = "opened"
+	ActionMoved    = "moved"
+	ActionDeleted  = "deleted"
+)
+
+// MaxItems is the number of items kept by user. The oldest ones are
+// removed beyond it.
+const MaxItems = 200
+
+// Item is something a user did to a file. Paths are relative to the root
+// of the server. The user only has the last item of an action on a path.
+type Item struct {
+	ID          uint64 `storm:"id,increment" json:"id"`
+	UserID      uint   `storm:"index" json:"userID"`
+	Username    string `json:"username"`
+	Action      string `json:"action"`
+	Path        string `json:"path"`
+	Destination string `json:"destination,omitempty"`
+	Time        int64  `json:"time"`
+}
+
+// Name returns the path of the file after the action.
+func (i *Item) Name() string {
+	if i.Action == ActionMoved {
+		return i.Destination
+	}
+	return i.Path
+}
@@ -0,0 +1,59 @@
+package activity
+
+import (
+	"path"
+	"time"
+)
+
+// StorageBackend is the interface to implement for an activity storage.
+type StorageBackend interface {
+	// Record saves an item in place of the previous one of its user with
+	// the same action and paths, and removes the oldest items of the user
+	// beyond keep.
+	Record(i *Item, keep int) error
+	// FindByUserID returns the items of a user, newest first.
+	FindByUserID(id uint) ([]*Item, error)
+	// Last returns the newest item of a user.
+	Last(id uint) (*Item, error)
+	// FindOthers returns the items of the users other than id, newest
+	// first.
+	FindOthers(id uint) ([]*Item, error)
+}
+
+// Storage is an activity storage.
+type Storage struct {
+	back StorageBackend
+}
+
+// NewStorage creates an activity storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Record dates and saves an item, keeping the MaxItems newest ones of its
+// user.
+func (s *Storage) Record(i *Item) error {
+	if i.Time == 0 {
+		i.Time = time.Now().Unix()
+	}
+	i.Path = path.Join("/", i.Path)
+	if i.Destination != "" {
+		i.Destination = path.Join("/", i.Destination)
+	}
+	return s.back.Record(i, MaxItems)
+}
+
+// FindByUserID wraps a StorageBackend.FindByUserID.
+func (s *Storage) FindByUserID(id uint) ([]*Item, error) {
+	return s.back.FindByUserID(id)
+}
+
+// Last wraps a StorageBackend.Last.
+func (s *Storage) Last(id uint) (*Item, error) {
+	return s.back.Last(id)
+}
+
+// FindOthers wraps a StorageBackend.FindOthers.
+func (s *Storage) FindOthers(id uint) ([]*Item, error) {
+	return s.back.FindOthers(id)
+}
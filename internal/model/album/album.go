@@ -0,0 +1,68 @@
+package album
+
+import (
+	"errors"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrEmptyName = errors.New("album name is empty")
+
+// Album is a virtual album: a list of photos of a user, wherever they're
+// stored.
+type Album struct {
+	ID     uint   `storm:"id,increment" json:"id"`
+	UserID uint   `storm:"index" json:"userID"`
+	Name   string `json:"name"`
+	// Paths are the photos of the album, relative to the scope of the user,
+	// in the order they were added.
+	Paths     []string `json:"paths"`
+	CreatedAt int64    `json:"createdAt"`
+	UpdatedAt int64    `json:"updatedAt"`
+}
+
+// New creates an empty album of a user.
+func New(userID uint, name string) (*Album, error) {
+	now := time.Now().Unix()
+	a := &Album{
+		UserID:    userID,
+		Name:      strings.TrimSpace(name),
+		Paths:     []string{},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return a, a.Validate()
+}
+
+// Validate checks the name of the album.
+func (a *Album) Validate() error {
+	if strings.TrimSpace(a.Name) == "" {
+		return ErrEmptyName
+	}
+	return nil
+}
+
+// Add appends the paths which aren't in the album yet.
+func (a *Album) Add(paths ...string) {
+	for _, p := range paths {
+		p = path.Join("/", p)
+		if !slices.Contains(a.Paths, p) {
+			a.Paths = append(a.Paths, p)
+		}
+	}
+}
+
+// Remove removes paths from the album.
+func (a *Album) Remove(paths ...string) {
+	for _, p := range paths {
+		p = path.Join("/", p)
+		a.Paths = slices.DeleteFunc(a.Paths, func(v string) bool { return v == p })
+	}
+}
+
+// Contains checks if the photo p is in the album.
+func (a *Album) Contains(p string) bool {
+	return slices.Contains(a.Paths, path.Join("/", p))
+}
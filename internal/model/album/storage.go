@@ -0,0 +1,43 @@
+package album
+
+// StorageBackend is the interface to implement for an album storage.
+type StorageBackend interface {
+	Get(id uint) (*Album, error)
+	FindByUserID(id uint) ([]*Album, error)
+	Save(a *Album) error
+	// Delete removes an album and its share links.
+	Delete(id uint) error
+}
+
+// Storage is an album storage.
+type Storage struct {
+	back StorageBackend
+}
+
+// NewStorage creates an album storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Get wraps a StorageBackend.Get.
+func (s *Storage) Get(id uint) (*Album, error) {
+	return s.back.Get(id)
+}
+
+// FindByUserID wraps a StorageBackend.FindByUserID.
+func (s *Storage) FindByUserID(id uint) ([]*Album, error) {
+	return s.back.FindByUserID(id)
+}
+
+// Save validates and saves an album.
+func (s *Storage) Save(a *Album) error {
+	if err := a.Validate(); err != nil {
+		return err
+	}
+	return s.back.Save(a)
+}
+
+// Delete wraps a StorageBackend.Delete.
+func (s *Storage) Delete(id uint) error {
+	return s.back.Delete(id)
+}
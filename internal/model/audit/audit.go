@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionLogin           = "login"
+	ActionLoginFailed     = "login.failed"
+	ActionFileCreate      = "file.create"
+	ActionFileModify      = "file.modify"
+	ActionFileDelete      = "file.delete"
+	ActionFileMove        = "file.move"
+	ActionFileCopy        = "file.copy"
+	ActionFileDownload    = "file.download"
+	ActionShareCreate     = "share.create"
+	ActionShareDelete     = "share.delete"
+	ActionShareAccess     = "share.access"
+	ActionUserCreate      = "user.create"
+	ActionUserUpdate      = "user.update"
+	ActionUserDelete      = "user.delete"
+	ActionUserImpersonate = "user.impersonate"
+	ActionSettingsUpdate  = "settings.update"
+	ActionWebDAVWrite     = "webdav.write"
+	ActionCommandRun      = "command.run"
+	ActionFileQuarantine  = "file.quarantine"
+)
+
+// Outcomes of a recorded action.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Entry is a record of the audit log. Entries are never modified once
+// saved, only removed by the retention limits.
+type Entry struct {
+	ID       uint64 `storm:"id,increment" json:"id"`
+	Time     int64  `storm:"index" json:"time"`
+	Action   string `storm:"index" json:"action"`
+	UserID   uint   `json:"userID,omitempty"`
+	Username string `json:"username,omitempty"`
+	// Actor is the admin impersonating the user, if any.
+	Actor       string `json:"actor,omitempty"`
+	IP          string `json:"ip"`
+	Method      string `json:"method,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	// Command is the command line run by the user, if any.
+	Command string `json:"command,omitempty"`
+	// Signature is the malware found in a quarantined file, if any.
+	Signature string `json:"signature,omitempty"`
+	Status    int    `json:"status,omitempty"`
+	Outcome   string `json:"outcome"`
+}
+
+// OutcomeOf returns the outcome of a request from its status code.
+func OutcomeOf(status int) string {
+	if status == 0 || status < http.StatusBadRequest {
+		return OutcomeSuccess
+	}
+	return OutcomeFailure
+}
+
+// Filter selects audit entries. Empty fields match everything.
+type Filter struct {
+	// Action matches the action itself or, when it has no dot, the
+	// actions of its category: "file" matches "file.delete".
+	Action   string
+	Username string
+	// Path matches the entries whose path or destination is inside it.
+	Path    string
+	Outcome string
+	Since   int64
+	Until   int64
+
+	Offset int
+	Limit  int
+}
+
+// Match checks if an entry is selected by the filter. It implements the
+// storm q.Matcher interface.
+func (f *Filter) Match(i interface{}) (bool, error) {
+	var e *Entry
+	switch v := i.(type) {
+	case *Entry:
+		e = v
+	case Entry:
+		e = &v
+	default:
+		return false, nil
+	}
+
+	switch {
+	case f.Action != "" && e.Action != f.Action && !strings.HasPrefix(e.Action, f.Action+"."):
+		return false, nil
+	case f.Username != "" && e.Username != f.Username && e.Actor != f.Username:
+		return false, nil
+	case f.Path != "" && !inPath(e.Path, f.Path) && !inPath(e.Destination, f.Path):
+		return false, nil
+	case f.Outcome != "" && e.Outcome != f.Outcome:
+		return false, nil
+	case f.Since != 0 && e.Time < f.Since:
+		return false, nil
+	case f.Until != 0 && e.Time > f.Until:
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func inPath(p, dir string) bool {
+	if p == "" {
+		return false
+	}
+	dir = strings.TrimSuffix(dir, "/")
+	return p == dir || strings.HasPrefix(p, dir+"/")
+}
@@ -0,0 +1,50 @@
+package audit
+
+import "testing"
+
+func TestFilterMatch(t *testing.T) {
+	e := Entry{
+		Time:        100,
+		Action:      ActionFileMove,
+		Username:    "bob",
+		Actor:       "admin",
+		Path:        "/docs/a.txt",
+		Destination: "/archive/a.txt",
+		Outcome:     OutcomeSuccess,
+	}
+
+	testCases := map[string]struct {
+		filter Filter
+		match  bool
+	}{
+		"empty filter":         {Filter{}, true},
+		"action":               {Filter{Action: ActionFileMove}, true},
+		"action category":      {Filter{Action: "file"}, true},
+		"other action":         {Filter{Action: ActionFileCopy}, false},
+		"action prefix":        {Filter{Action: "fi"}, false},
+		"user":                 {Filter{Username: "bob"}, true},
+		"impersonating admin":  {Filter{Username: "admin"}, true},
+		"other user":           {Filter{Username: "alice"}, false},
+		"path":                 {Filter{Path: "/docs"}, true},
+		"destination":          {Filter{Path: "/archive/"}, true},
+		"path prefix":          {Filter{Path: "/doc"}, false},
+		"outcome":              {Filter{Outcome: OutcomeFailure}, false},
+		"time range":           {Filter{Since: 100, Until: 100}, true},
+		"before the time span": {Filter{Since: 101}, false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// storm passes the entries by value or by pointer.
+			for _, v := range []interface{}{e, &e} {
+				ok, err := tc.filter.Match(v)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if ok != tc.match {
+					t.Errorf("Match(%T) = %v, want %v", v, ok, tc.match)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package audit
+
+import (
+	"time"
+)
+
+// Page sizes of the audit queries.
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// StorageBackend is the interface to implement for an audit storage.
+type StorageBackend interface {
+	Save(e *Entry) error
+	// Query returns the entries matching the filter, newest first, and
+	// the total number of matching entries.
+	Query(f *Filter) ([]*Entry, int, error)
+	// Prune removes the entries older than before and the oldest ones
+	// beyond the keep newest. A zero value disables the limit.
+	Prune(before int64, keep int) (int, error)
+}
+
+// Storage is an audit storage.
+type Storage struct {
+	back StorageBackend
+}
+
+// NewStorage creates an audit storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Record appends an entry to the audit log.
+func (s *Storage) Record(e *Entry) error {
+	if e.Time == 0 {
+		e.Time = time.Now().Unix()
+	}
+	if e.Outcome == "" {
+		e.Outcome = OutcomeOf(e.Status)
+	}
+
+	return s.back.Save(e)
+}
+
+// Query returns a page of the entries matching the filter, newest first,
+// and the total number of matching entries. The offset and limit of the
+// filter are adjusted to the returned page.
+func (s *Storage) Query(f *Filter) ([]*Entry, int, error) {
+	if f.Limit <= 0 {
+		f.Limit = DefaultLimit
+	}
+	f.Limit = min(f.Limit, MaxLimit)
+	f.Offset = max(f.Offset, 0)
+
+	return s.back.Query(f)
+}
+
+// Prune applies the retention limits: entries older than maxAge and the
+// oldest entries beyond maxEntries are removed. It returns the number of
+// removed entries.
+func (s *Storage) Prune(maxAge time.Duration, maxEntries int) (int, error) {
+	var before int64
+	if maxAge > 0 {
+		before = time.Now().Add(-maxAge).Unix()
+	}
+
+	return s.back.Prune(before, maxEntries)
+}
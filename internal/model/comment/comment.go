@@ -0,0 +1,80 @@
+// Package comment holds the comments left on files. They're attached to the
+// identity of the files, so they follow them when they're moved.
+package comment
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLength is the length of the longest comment, in characters.
+const maxLength = 10000
+
+var ErrInvalidBody = fmt.Errorf("comments must have 1 to %d characters", maxLength)
+
+// Comment is a comment on a file, or a reply to another comment on it.
+type Comment struct {
+	ID     uint   `storm:"id,increment" json:"id"`
+	FileID uint64 `storm:"index" json:"fileID"`
+	// ParentID is the comment replied to, 0 for the comments starting a
+	// thread.
+	ParentID uint `storm:"index" json:"parentID"`
+	UserID   uint `json:"userID"`
+	// Author is the name of the user when they wrote the comment, which is
+	// kept after the user is deleted.
+	Author    string `json:"author"`
+	Body      string `json:"body"`
+	CreatedAt int64  `json:"createdAt"`
+	UpdatedAt int64  `json:"updatedAt"`
+}
+
+// New creates a comment of a user on a file.
+func New(fileID uint64, parentID, userID uint, author, body string) (*Comment, error) {
+	now := time.Now().Unix()
+	c := &Comment{
+		FileID:    fileID,
+		ParentID:  parentID,
+		UserID:    userID,
+		Author:    author,
+		Body:      strings.TrimSpace(body),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	return c, c.Validate()
+}
+
+// Validate checks the body of the comment.
+func (c *Comment) Validate() error {
+	if strings.TrimSpace(c.Body) == "" || utf8.RuneCountInString(c.Body) > maxLength {
+		return ErrInvalidBody
+	}
+	return nil
+}
+
+// Thread is a comment with its replies, oldest first.
+type Thread struct {
+	*Comment
+	Replies []*Thread `json:"replies"`
+}
+
+// Threads arranges the comments of a file into threads, oldest first. The
+// replies to missing comments start their own thread.
+func Threads(comments []*Comment) []*Thread {
+	threads := make(map[uint]*Thread, len(comments))
+	for _, c := range comments {
+		threads[c.ID] = &Thread{Comment: c, Replies: []*Thread{}}
+	}
+
+	roots := []*Thread{}
+	for _, c := range comments {
+		t := threads[c.ID]
+		if parent, ok := threads[c.ParentID]; ok && c.ParentID != c.ID {
+			parent.Replies = append(parent.Replies, t)
+		} else {
+			roots = append(roots, t)
+		}
+	}
+	return roots
+}
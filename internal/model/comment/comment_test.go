@@ -0,0 +1,26 @@
+package comment
+
+import "testing"
+
+func TestThreads(t *testing.T) {
+	threads := Threads([]*Comment{
+		{ID: 1},
+		{ID: 2, ParentID: 1},
+		{ID: 3},
+		{ID: 4, ParentID: 2},
+		{ID: 5, ParentID: 1},
+		// The reply to a deleted comment starts a thread.
+		{ID: 6, ParentID: 9},
+	})
+
+	if len(threads) != 3 || threads[0].ID != 1 || threads[1].ID != 3 || threads[2].ID != 6 {
+		t.Fatalf("unexpected threads %+v", threads)
+	}
+	replies := threads[0].Replies
+	if len(replies) != 2 || replies[0].ID != 2 || replies[1].ID != 5 {
+		t.Fatalf("unexpected replies %+v", replies)
+	}
+	if len(replies[0].Replies) != 1 || replies[0].Replies[0].ID != 4 {
+		t.Errorf("unexpected replies %+v", replies[0].Replies)
+	}
+}
@@ -0,0 +1,60 @@
+package comment
+
+import (
+	"cmp"
+	"slices"
+)
+
+// StorageBackend is the interface to implement for a comment storage.
+type StorageBackend interface {
+	Get(id uint) (*Comment, error)
+	FindByFileID(id uint64) ([]*Comment, error)
+	// Count returns the number of comments of the files with ids, by ID.
+	Count(ids []uint64) (map[uint64]int, error)
+	Save(c *Comment) error
+	// Delete removes a comment and the replies to it.
+	Delete(id uint) error
+}
+
+// Storage is a comment storage.
+type Storage struct {
+	back StorageBackend
+}
+
+// NewStorage creates a comment storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Get wraps a StorageBackend.Get.
+func (s *Storage) Get(id uint) (*Comment, error) {
+	return s.back.Get(id)
+}
+
+// FindByFileID returns the comments of a file, oldest first.
+func (s *Storage) FindByFileID(id uint64) ([]*Comment, error) {
+	comments, err := s.back.FindByFileID(id)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(comments, func(a, b *Comment) int { return cmp.Compare(a.ID, b.ID) })
+	return comments, nil
+}
+
+// Count wraps a StorageBackend.Count.
+func (s *Storage) Count(ids []uint64) (map[uint64]int, error) {
+	return s.back.Count(ids)
+}
+
+// Save validates and saves a comment.
+func (s *Storage) Save(c *Comment) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	return s.back.Save(c)
+}
+
+// Delete wraps a StorageBackend.Delete.
+func (s *Storage) Delete(id uint) error {
+	return s.back.Delete(id)
+}
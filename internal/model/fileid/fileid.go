@@ -0,0 +1,10 @@
+// Package fileid gives the files a stable identity, which follows them when
+// they're moved, so records can be attached to them.
+package fileid
+
+// File is the identity of a file. Path is relative to the root of the
+// server, so the users sharing a file share its identity.
+type File struct {
+	ID   uint64 `storm:"id,increment" json:"id"`
+	Path string `storm:"unique" json:"path"`
+}
@@ -0,0 +1,86 @@
+package fileid
+
+import "path"
+
+// StorageBackend is the interface to implement for a file identity
+// storage.
+type StorageBackend interface {
+	Get(path string) (*File, error)
+	// Ensure returns the identity of the file at path, created if missing.
+	Ensure(path string) (*File, error)
+	// Find returns the identities of the files at paths which have one, by
+	// path.
+	Find(paths []string) (map[string]*File, error)
+	// Paths returns the paths of the files with ids, by ID.
+	Paths(ids []uint64) (map[uint64]string, error)
+	// Exists checks if path or its content have identities.
+	Exists(path string) (bool, error)
+	// Move moves the identities of path and of its content to dst. The
+	// files replaced at dst lose theirs.
+	Move(path, dst string) error
+	// Delete removes the identities of path and of its content, with the
+	// records attached to them.
+	Delete(path string) error
+}
+
+// Storage is a file identity storage. Its paths are cleaned, so they can
+// be compared.
+type Storage struct {
+	back StorageBackend
+}
+
+// NewStorage creates a file identity storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Get wraps a StorageBackend.Get.
+func (s *Storage) Get(p string) (*File, error) {
+	return s.back.Get(path.Join("/", p))
+}
+
+// Ensure wraps a StorageBackend.Ensure.
+func (s *Storage) Ensure(p string) (*File, error) {
+	return s.back.Ensure(path.Join("/", p))
+}
+
+// Find returns the identities of the files at paths which have one, by
+// their path as given.
+func (s *Storage) Find(paths []string) (map[string]*File, error) {
+	clean := make([]string, len(paths))
+	for i, p := range paths {
+		clean[i] = path.Join("/", p)
+	}
+
+	files, err := s.back.Find(clean)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*File, len(files))
+	for i, p := range paths {
+		if f, ok := files[clean[i]]; ok {
+			res[p] = f
+		}
+	}
+	return res, nil
+}
+
+// Paths wraps a StorageBackend.Paths.
+func (s *Storage) Paths(ids []uint64) (map[uint64]string, error) {
+	return s.back.Paths(ids)
+}
+
+// Exists wraps a StorageBackend.Exists.
+func (s *Storage) Exists(p string) (bool, error) {
+	return s.back.Exists(path.Join("/", p))
+}
+
+// Move wraps a StorageBackend.Move.
+func (s *Storage) Move(p, dst string) error {
+	return s.back.Move(path.Join("/", p), path.Join("/", dst))
+}
+
+// Delete wraps a StorageBackend.Delete.
+func (s *Storage) Delete(p string) error {
+	return s.back.Delete(path.Join("/", p))
+}
@@ -0,0 +1,53 @@
+package settings
+
+import "time"
+
+const (
+	DefaultBruteForceMaxAttempts      = 5
+	DefaultBruteForceBaseDelay        = 1       // 1 second
+	DefaultBruteForceMaxDelay         = 15 * 60 // 15 minutes
+	DefaultBruteForceLockoutThreshold = 20      // failures
+	DefaultBruteForceLockoutDuration  = 30 * 60 // 30 minutes
+)
+
+// BruteForce contains the brute-force protection settings of the app.
+// Delays and durations are expressed in seconds.
+type BruteForce struct {
+	Enabled          bool `json:"enabled"`
+	MaxAttempts      uint `json:"maxAttempts"`      // failures allowed before backoff starts
+	BaseDelay        uint `json:"baseDelay"`        // first backoff delay, doubled on every failure
+	MaxDelay         uint `json:"maxDelay"`         // upper bound of the backoff delay
+	LockoutThreshold uint `json:"lockoutThreshold"` // failures before an account is locked, 0 disables lockout
+	LockoutDuration  uint `json:"lockoutDuration"`  // how long an account stays locked
+}
+
+// DefaultBruteForce returns the protection applied until an admin sets
+// another one.
+func DefaultBruteForce() *BruteForce {
+	return &BruteForce{
+		Enabled:          true,
+		MaxAttempts:      DefaultBruteForceMaxAttempts,
+		BaseDelay:        DefaultBruteForceBaseDelay,
+		MaxDelay:         DefaultBruteForceMaxDelay,
+		LockoutThreshold: DefaultBruteForceLockoutThreshold,
+		LockoutDuration:  DefaultBruteForceLockoutDuration,
+	}
+}
+
+// Delay returns the backoff imposed after the given number of failures.
+func (b BruteForce) Delay(failures uint) time.Duration {
+	if !b.Enabled || failures <= b.MaxAttempts {
+		return 0
+	}
+
+	maxDelay := time.Duration(b.MaxDelay) * time.Second
+	delay := time.Duration(b.BaseDelay) * time.Second
+	for i := b.MaxAttempts + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+
+	return min(delay, maxDelay)
+}
@@ -1,6 +1,8 @@
 package settings
 
 import (
+	"slices"
+
 	"github.com/nulnl/nulyun/internal/files"
 	"github.com/nulnl/nulyun/internal/model/users"
 )
@@ -14,6 +16,7 @@ type UserDefaults struct {
 	SingleClick       bool              `json:"singleClick"`
 	Sorting           files.Sorting     `json:"sorting"`
 	Perm              users.Permissions `json:"perm"`
+	Commands          []string          `json:"commands"`
 	HideDotfiles      bool              `json:"hideDotfiles"`
 	HideHiddenFolders bool              `json:"hideHiddenFolders"`
 	DateFormat        bool              `json:"dateFormat"`
@@ -29,6 +32,7 @@ func (d *UserDefaults) Apply(u *users.User) {
 	u.ViewMode = d.ViewMode
 	u.SingleClick = d.SingleClick
 	u.Perm = d.Perm
+	u.Commands = slices.Clone(d.Commands)
 	u.Sorting = d.Sorting
 	u.HideDotfiles = d.HideDotfiles
 	u.HideHiddenFolders = d.HideHiddenFolders
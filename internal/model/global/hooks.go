@@ -0,0 +1,61 @@
+package settings
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+)
+
+// Hook events.
+const (
+	BeforeUpload = "before_upload"
+	AfterUpload  = "after_upload"
+	BeforeSave   = "before_save"
+	AfterSave    = "after_save"
+	BeforeDelete = "before_delete"
+	AfterDelete  = "after_delete"
+	BeforeRename = "before_rename"
+	AfterRename  = "after_rename"
+	BeforeCopy   = "before_copy"
+	AfterCopy    = "after_copy"
+	BeforeShare  = "before_share"
+	AfterShare   = "after_share"
+)
+
+// HookEvents lists the events hooks can run on.
+var HookEvents = []string{
+	BeforeUpload, AfterUpload, BeforeSave, AfterSave, BeforeDelete, AfterDelete,
+	BeforeRename, AfterRename, BeforeCopy, AfterCopy, BeforeShare, AfterShare,
+}
+
+var (
+	ErrInvalidHookEvent  = errors.New("invalid hook event")
+	ErrInvalidHookTarget = errors.New("a hook needs either a command or an http(s) URL")
+)
+
+// Hook is a command or an HTTP endpoint run on a file operation. The hooks
+// run before an operation can veto it, the ones run after can't.
+type Hook struct {
+	Event   string `json:"event"`
+	Command string `json:"command,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Timeout uint   `json:"timeout,omitempty"` // in seconds, 0 uses the default
+}
+
+// Validate checks the hook has a known event and a single target.
+func (h *Hook) Validate() error {
+	if !slices.Contains(HookEvents, h.Event) {
+		return ErrInvalidHookEvent
+	}
+	if (h.Command == "") == (h.URL == "") {
+		return ErrInvalidHookTarget
+	}
+	if h.URL != "" {
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return ErrInvalidHookTarget
+		}
+	}
+
+	return nil
+}
@@ -6,9 +6,12 @@ import (
 	"log"
 	"strings"
 	"time"
+
+	"github.com/nulnl/nulyun/internal/mail"
 )
 
 const DefaultUsersHomeBasePath = "/.users"
+const DefaultArchivePath = "/.archives"
 const DefaultLogoutPage = "/login"
 const DefaultMinimumPasswordLength = 12
 const DefaultFileMode = 0640
@@ -19,41 +22,59 @@ type AuthMethod string
 
 // Settings contain the main settings of the application.
 type Settings struct {
-	Key                   []byte       `json:"key"`
-	Signup                bool         `json:"signup"`
-	HideLoginButton       bool         `json:"hideLoginButton"`
-	CreateUserDir         bool         `json:"createUserDir"`
-	UserHomeBasePath      string       `json:"userHomeBasePath"`
-	Defaults              UserDefaults `json:"defaults"`
-	AuthMethod            AuthMethod   `json:"authMethod"`
-	LogoutPage            string       `json:"logoutPage"`
-	Branding              Branding     `json:"branding"`
-	Tus                   Tus          `json:"tus"`
-	Shell                 []string     `json:"shell"`
-	MinimumPasswordLength uint         `json:"minimumPasswordLength"`
-	FileMode              fs.FileMode  `json:"fileMode"`
-	DirMode               fs.FileMode  `json:"dirMode"`
-	HideDotfiles          bool         `json:"hideDotfiles"`
-	TOTPEncryptionKey     []byte       `json:"totpEncryptionKey"`
-	TOTPEnabled           bool         `json:"totpEnabled"`
+	Key                   []byte          `json:"key"`
+	Signup                bool            `json:"signup"`
+	HideLoginButton       bool            `json:"hideLoginButton"`
+	CreateUserDir         bool            `json:"createUserDir"`
+	UserHomeBasePath      string          `json:"userHomeBasePath"`
+	ArchivePath           string          `json:"archivePath"`
+	Defaults              UserDefaults    `json:"defaults"`
+	AuthMethod            AuthMethod      `json:"authMethod"`
+	LogoutPage            string          `json:"logoutPage"`
+	Branding              Branding        `json:"branding"`
+	Tus                   Tus             `json:"tus"`
+	Shell                 []string        `json:"shell"`
+	CommandTimeout        uint            `json:"commandTimeout"` // in seconds, 0 uses the default
+	Hooks                 []Hook          `json:"hooks"`
+	MinimumPasswordLength uint            `json:"minimumPasswordLength"`
+	FileMode              fs.FileMode     `json:"fileMode"`
+	DirMode               fs.FileMode     `json:"dirMode"`
+	HideDotfiles          bool            `json:"hideDotfiles"`
+	TOTPEncryptionKey     []byte          `json:"totpEncryptionKey"`
+	TOTPEnabled           bool            `json:"totpEnabled"`
+	BruteForce            *BruteForce     `json:"bruteForce"`
+	TwoFactor             TwoFactorPolicy `json:"twoFactor"`
+	SignupPolicy          SignupPolicy    `json:"signupPolicy"`
+	// ImpersonationReadOnly blocks the destructive requests of admins
+	// acting as another user.
+	ImpersonationReadOnly bool `json:"impersonationReadOnly"`
 }
 
 // Server specific settings.
 type Server struct {
-	Root                    string `json:"root"`
-	BaseURL                 string `json:"baseURL"`
-	TLSKey                  string `json:"tlsKey"`
-	TLSCert                 string `json:"tlsCert"`
-	Port                    string `json:"port"`
-	Address                 string `json:"address"`
-	Log                     string `json:"log"`
-	EnableThumbnails        bool   `json:"enableThumbnails"`
-	ResizePreview           bool   `json:"resizePreview"`
-	TypeDetectionByHeader   bool   `json:"typeDetectionByHeader"`
-	AuthHook                string `json:"authHook"`
-	TokenExpirationTime     string `json:"tokenExpirationTime"`
-	TOTPTokenExpirationTime string `json:"totpTokenExpirationTime"`
-	EnableTOTP              bool   `json:"enableTOTP"`
+	Root                    string    `json:"root"`
+	BaseURL                 string    `json:"baseURL"`
+	TLSKey                  string    `json:"tlsKey"`
+	TLSCert                 string    `json:"tlsCert"`
+	Port                    string    `json:"port"`
+	Address                 string    `json:"address"`
+	Log                     string    `json:"log"`
+	EnableThumbnails        bool      `json:"enableThumbnails"`
+	ResizePreview           bool      `json:"resizePreview"`
+	TypeDetectionByHeader   bool      `json:"typeDetectionByHeader"`
+	AuthHook                string    `json:"authHook"`
+	TokenExpirationTime     string    `json:"tokenExpirationTime"`
+	TOTPTokenExpirationTime string    `json:"totpTokenExpirationTime"`
+	EnableTOTP              bool      `json:"enableTOTP"`
+	PublicURL               string    `json:"publicURL"`
+	SMTP                    mail.SMTP `json:"smtp"`
+	WatchFiles              bool      `json:"watchFiles"`
+	Clamd                   string    `json:"clamd"`
+	QuarantineDir           string    `json:"quarantineDir"`
+	SearchIndex             string    `json:"searchIndex"`
+	SearchIndexInterval     string    `json:"searchIndexInterval"`
+	IndexContent            bool      `json:"indexContent"`
+	ContentMaxSize          int64     `json:"contentMaxSize"`
 }
 
 // Clean cleans any variables that might need cleaning.
@@ -0,0 +1,8 @@
+package settings
+
+// SignupPolicy contains the restrictions applied to the users signing up.
+type SignupPolicy struct {
+	InviteOnly  bool `json:"inviteOnly"`
+	Approval    bool `json:"approval"`
+	VerifyEmail bool `json:"verifyEmail"`
+}
@@ -34,6 +34,10 @@ func (s *Storage) Get() (*Settings, error) {
 		set.UserHomeBasePath = DefaultUsersHomeBasePath
 	}
 
+	if set.ArchivePath == "" {
+		set.ArchivePath = DefaultArchivePath
+	}
+
 	if set.LogoutPage == "" {
 		set.LogoutPage = DefaultLogoutPage
 	}
@@ -49,6 +53,11 @@ func (s *Storage) Get() (*Settings, error) {
 		}
 	}
 
+	// A protection set by an admin is kept, even when disabled.
+	if set.BruteForce == nil {
+		set.BruteForce = DefaultBruteForce()
+	}
+
 	if set.FileMode == 0 {
 		set.FileMode = DefaultFileMode
 	}
@@ -78,6 +87,15 @@ func (s *Storage) Save(set *Settings) error {
 		set.Shell = []string{}
 	}
 
+	if set.Hooks == nil {
+		set.Hooks = []Hook{}
+	}
+	for i := range set.Hooks {
+		if err := set.Hooks[i].Validate(); err != nil {
+			return err
+		}
+	}
+
 	err := s.back.Save(set)
 	if err != nil {
 		return err
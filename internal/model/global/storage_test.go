@@ -0,0 +1,47 @@
+package settings_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/asdine/storm/v3"
+
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/repository/bolt"
+)
+
+func TestBruteForceDefaults(t *testing.T) {
+	db, err := storm.Open(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	st, err := bolt.NewStorage(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.Settings.Save(&settings.Settings{Key: []byte("key")}); err != nil {
+		t.Fatal(err)
+	}
+	set, err := st.Settings.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *set.BruteForce != *settings.DefaultBruteForce() {
+		t.Errorf("unset protection = %+v, want the defaults", set.BruteForce)
+	}
+
+	// An admin turning the protection off by zeroing it.
+	set.BruteForce = &settings.BruteForce{}
+	if err := st.Settings.Save(set); err != nil {
+		t.Fatal(err)
+	}
+	set, err = st.Settings.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *set.BruteForce != (settings.BruteForce{}) {
+		t.Errorf("disabled protection = %+v, want it kept off", set.BruteForce)
+	}
+}
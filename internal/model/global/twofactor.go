@@ -0,0 +1,55 @@
+package settings
+
+import (
+	"slices"
+	"time"
+
+	"github.com/nulnl/nulyun/internal/model/users"
+)
+
+// TwoFactorMode describes who must enroll a second factor.
+type TwoFactorMode string
+
+const (
+	TwoFactorOptional TwoFactorMode = ""
+	TwoFactorAll      TwoFactorMode = "all"
+	TwoFactorAdmins   TwoFactorMode = "admins"
+	TwoFactorGroups   TwoFactorMode = "groups"
+)
+
+// TwoFactorPolicy contains the two-factor enforcement settings of the app.
+type TwoFactorPolicy struct {
+	Mode        TwoFactorMode `json:"mode"`
+	Groups      []string      `json:"groups"`
+	GracePeriod uint          `json:"gracePeriod"` // in days
+	Since       int64         `json:"since"`       // unix time the policy was enforced
+}
+
+// Applies checks if the policy requires the user to enroll a second factor.
+func (p TwoFactorPolicy) Applies(u *users.User) bool {
+	switch p.Mode {
+	case TwoFactorAll:
+		return true
+	case TwoFactorAdmins:
+		return u.Perm.Admin
+	case TwoFactorGroups:
+		for _, g := range u.Groups {
+			if slices.Contains(p.Groups, g) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// Deadline returns the end of the grace period.
+func (p TwoFactorPolicy) Deadline() time.Time {
+	return time.Unix(p.Since, 0).Add(time.Duration(p.GracePeriod) * 24 * time.Hour)
+}
+
+// Equal checks if two policies enforce the same rules.
+func (p TwoFactorPolicy) Equal(o TwoFactorPolicy) bool {
+	return p.Mode == o.Mode && p.GracePeriod == o.GracePeriod && slices.Equal(p.Groups, o.Groups)
+}
@@ -0,0 +1,292 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+	AlgRS256 = "RS256"
+)
+
+const (
+	hmacKeySize = 64
+	rsaKeySize  = 2048
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown signing algorithm")
+	ErrNoActiveKey      = errors.New("no active signing key")
+	ErrUnknownKey       = errors.New("unknown signing key")
+)
+
+// Key is a signing key of the keyring.
+type Key struct {
+	ID         string `json:"kid"`
+	Algorithm  string `json:"alg"`
+	Secret     []byte `json:"secret,omitempty"`     // HS256 secret
+	PrivateKey string `json:"privateKey,omitempty"` // PEM encoded EdDSA or RS256 key
+	CreatedAt  int64  `json:"createdAt"`
+	RetiredAt  int64  `json:"retiredAt,omitempty"`
+
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// Keyring is a set of signing keys. The newest key that is not retired
+// signs the new tokens, the others are only used to verify them.
+type Keyring struct {
+	Keys []*Key `json:"keys"`
+	// LegacyUntil is the time until which tokens without a key ID,
+	// issued before the keyring existed, are still accepted.
+	LegacyUntil int64 `json:"legacyUntil,omitempty"`
+}
+
+// NewKey generates a new key for the algorithm.
+func NewKey(alg string) (*Key, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	k := &Key{
+		ID:        base64.RawURLEncoding.EncodeToString(id),
+		Algorithm: alg,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	var priv crypto.PrivateKey
+	switch alg {
+	case AlgHS256:
+		k.Secret = make([]byte, hmacKeySize)
+		if _, err := rand.Read(k.Secret); err != nil {
+			return nil, err
+		}
+	case AlgEdDSA:
+		_, edKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		priv = edKey
+	case AlgRS256:
+		rsaKey, err := rsa.GenerateKey(rand.Reader, rsaKeySize)
+		if err != nil {
+			return nil, err
+		}
+		priv = rsaKey
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, alg)
+	}
+
+	if priv != nil {
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			return nil, err
+		}
+		k.PrivateKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	}
+
+	return k, k.init()
+}
+
+// Retired checks if the key no longer signs tokens.
+func (k *Key) Retired() bool {
+	return k.RetiredAt != 0
+}
+
+// init parses the key material.
+func (k *Key) init() error {
+	switch k.Algorithm {
+	case AlgHS256:
+		if len(k.Secret) == 0 {
+			return fmt.Errorf("key %q: missing secret", k.ID)
+		}
+		k.signKey, k.verifyKey = k.Secret, k.Secret
+		return nil
+	case AlgEdDSA, AlgRS256:
+	default:
+		return fmt.Errorf("key %q: %w: %q", k.ID, ErrUnknownAlgorithm, k.Algorithm)
+	}
+
+	block, _ := pem.Decode([]byte(k.PrivateKey))
+	if block == nil {
+		return fmt.Errorf("key %q: invalid PEM private key", k.ID)
+	}
+
+	var priv interface{}
+	var err error
+	if block.Type == "RSA PRIVATE KEY" {
+		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return fmt.Errorf("key %q: %w", k.ID, err)
+	}
+
+	switch key := priv.(type) {
+	case ed25519.PrivateKey:
+		if k.Algorithm != AlgEdDSA {
+			break
+		}
+		k.signKey, k.verifyKey = key, key.Public()
+		return nil
+	case *rsa.PrivateKey:
+		if k.Algorithm != AlgRS256 {
+			break
+		}
+		k.signKey, k.verifyKey = key, &key.PublicKey
+		return nil
+	}
+
+	return fmt.Errorf("key %q: private key does not match algorithm %s", k.ID, k.Algorithm)
+}
+
+// Parse reads a keyring from its JSON representation.
+func Parse(data []byte) (*Keyring, error) {
+	ring := &Keyring{}
+	if err := json.Unmarshal(data, ring); err != nil {
+		return nil, err
+	}
+
+	return ring, ring.init()
+}
+
+func (r *Keyring) init() error {
+	ids := map[string]bool{}
+	for _, k := range r.Keys {
+		if k.ID == "" {
+			return errors.New("key without kid")
+		}
+		if ids[k.ID] {
+			return fmt.Errorf("duplicate key %q", k.ID)
+		}
+		ids[k.ID] = true
+
+		if err := k.init(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Active returns the key used to sign new tokens.
+func (r *Keyring) Active() *Key {
+	for i := len(r.Keys) - 1; i >= 0; i-- {
+		if !r.Keys[i].Retired() {
+			return r.Keys[i]
+		}
+	}
+
+	return nil
+}
+
+// Get returns the key with the given ID.
+func (r *Keyring) Get(id string) *Key {
+	for _, k := range r.Keys {
+		if k.ID == id {
+			return k
+		}
+	}
+
+	return nil
+}
+
+// Rotate returns a copy of the keyring with a new active key. The previous
+// keys are retired but kept to verify the tokens they signed.
+func (r *Keyring) Rotate(alg string) (*Keyring, error) {
+	k, err := NewKey(alg)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	next := &Keyring{LegacyUntil: r.LegacyUntil}
+	for _, old := range r.Keys {
+		cp := *old
+		if !cp.Retired() {
+			cp.RetiredAt = now
+		}
+		next.Keys = append(next.Keys, &cp)
+	}
+	next.Keys = append(next.Keys, k)
+
+	return next, nil
+}
+
+// Prune returns a copy of the keyring without the keys retired for longer
+// than ttl, after which no token they signed can still be valid. It reports
+// whether any key was removed.
+func (r *Keyring) Prune(ttl time.Duration) (*Keyring, bool) {
+	limit := time.Now().Add(-ttl).Unix()
+	next := &Keyring{LegacyUntil: r.LegacyUntil}
+	for _, k := range r.Keys {
+		if k.Retired() && k.RetiredAt < limit {
+			continue
+		}
+		next.Keys = append(next.Keys, k)
+	}
+
+	return next, len(next.Keys) != len(r.Keys)
+}
+
+// Sign signs the claims with the active key.
+func (r *Keyring) Sign(claims jwt.Claims) (string, error) {
+	k := r.Active()
+	if k == nil {
+		return "", ErrNoActiveKey
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(k.Algorithm), claims)
+	token.Header["kid"] = k.ID
+	return token.SignedString(k.signKey)
+}
+
+// Methods returns the algorithms accepted when verifying tokens.
+func (r *Keyring) Methods() []string {
+	methods := []string{AlgHS256}
+	for _, k := range r.Keys {
+		if k.Algorithm != AlgHS256 {
+			methods = append(methods, k.Algorithm)
+		}
+	}
+
+	return methods
+}
+
+// Keyfunc returns the function resolving the verification key of a token
+// from its kid header. Tokens without kid are verified with the legacy
+// HS256 key while it is still accepted.
+func (r *Keyring) Keyfunc(legacy []byte) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			if len(legacy) == 0 || time.Now().Unix() > r.LegacyUntil || t.Method.Alg() != AlgHS256 {
+				return nil, ErrUnknownKey
+			}
+			return legacy, nil
+		}
+
+		k := r.Get(kid)
+		if k == nil || t.Method.Alg() != k.Algorithm {
+			return nil, ErrUnknownKey
+		}
+
+		return k.verifyKey, nil
+	}
+}
@@ -0,0 +1,93 @@
+package keyring
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestKeyringRotation(t *testing.T) {
+	for _, alg := range []string{AlgHS256, AlgEdDSA, AlgRS256} {
+		t.Run(alg, func(t *testing.T) {
+			ring, err := (&Keyring{}).Rotate(alg)
+			if err != nil {
+				t.Fatalf("failed to create key: %v", err)
+			}
+
+			old, err := ring.Sign(jwt.RegisteredClaims{Subject: "old"})
+			if err != nil {
+				t.Fatalf("failed to sign: %v", err)
+			}
+
+			ring, err = ring.Rotate(AlgHS256)
+			if err != nil {
+				t.Fatalf("failed to rotate: %v", err)
+			}
+			if len(ring.Keys) != 2 || !ring.Keys[0].Retired() || ring.Active() != ring.Keys[1] {
+				t.Fatalf("unexpected keys after rotation: %+v", ring.Keys)
+			}
+
+			p := jwt.NewParser(jwt.WithValidMethods(ring.Methods()))
+			if _, err := p.Parse(old, ring.Keyfunc(nil)); err != nil {
+				t.Errorf("token signed by a retired key rejected: %v", err)
+			}
+
+			// Retired keys are kept for the lifetime of their tokens.
+			if _, pruned := ring.Prune(time.Hour); pruned {
+				t.Errorf("key pruned before its tokens expired")
+			}
+			ring.Keys[0].RetiredAt = time.Now().Add(-2 * time.Hour).Unix()
+			ring, pruned := ring.Prune(time.Hour)
+			if !pruned || len(ring.Keys) != 1 {
+				t.Fatalf("expired key not pruned")
+			}
+			if _, err := p.Parse(old, ring.Keyfunc(nil)); err == nil {
+				t.Errorf("token signed by a pruned key accepted")
+			}
+		})
+	}
+}
+
+func TestKeyringLegacyKey(t *testing.T) {
+	legacy := []byte("legacy")
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{}).SignedString(legacy)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	ring := &Keyring{LegacyUntil: time.Now().Add(time.Hour).Unix()}
+	if _, err := jwt.Parse(signed, ring.Keyfunc(legacy)); err != nil {
+		t.Errorf("legacy token rejected: %v", err)
+	}
+
+	ring.LegacyUntil = time.Now().Add(-time.Hour).Unix()
+	if _, err := jwt.Parse(signed, ring.Keyfunc(legacy)); err == nil {
+		t.Errorf("legacy token accepted after the legacy period")
+	}
+}
+
+func TestParse(t *testing.T) {
+	key, err := NewKey(AlgEdDSA)
+	if err != nil {
+		t.Fatalf("failed to create key: %v", err)
+	}
+
+	ring, err := Parse([]byte(`{"keys":[{"kid":"a","alg":"EdDSA","privateKey":` + jsonString(key.PrivateKey) + `}]}`))
+	if err != nil {
+		t.Fatalf("failed to parse keyring: %v", err)
+	}
+	if ring.Active() == nil || ring.Active().ID != "a" {
+		t.Errorf("unexpected active key")
+	}
+
+	if _, err := Parse([]byte(`{"keys":[{"kid":"a","alg":"RS256","privateKey":` + jsonString(key.PrivateKey) + `}]}`)); err == nil {
+		t.Errorf("key not matching its algorithm accepted")
+	}
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
@@ -0,0 +1,124 @@
+package keyring
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+// StorageBackend is the interface to implement for a keyring storage.
+type StorageBackend interface {
+	Get() (*Keyring, error)
+	Save(*Keyring) error
+}
+
+// Storage is a keyring storage. The keyring is kept in memory once loaded
+// and replaced as a whole on rotation, so the returned value is never
+// modified and can be used without locking.
+type Storage struct {
+	back StorageBackend
+
+	mux      sync.RWMutex
+	ring     *Keyring
+	external bool
+}
+
+// NewStorage creates a keyring storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Get returns the current keyring.
+func (s *Storage) Get() (*Keyring, error) {
+	s.mux.RLock()
+	ring := s.ring
+	s.mux.RUnlock()
+	if ring != nil {
+		return ring, nil
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.ring != nil {
+		return s.ring, nil
+	}
+
+	ring, err := s.back.Get()
+	if errors.Is(err, fberrors.ErrNotExist) {
+		ring = &Keyring{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := ring.init(); err != nil {
+		return nil, err
+	}
+
+	s.ring = ring
+	return ring, nil
+}
+
+// Use replaces the stored keyring by one managed outside of the database,
+// such as a key file. It is never persisted nor rotated.
+func (s *Storage) Use(ring *Keyring) error {
+	if ring.Active() == nil {
+		return ErrNoActiveKey
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.ring = ring
+	s.external = true
+	return nil
+}
+
+// Maintain makes sure the active key uses the algorithm alg and is younger
+// than every, rotating it otherwise, and drops the keys retired for longer
+// than ttl, the longest lifetime of a token. A zero every disables the
+// scheduled rotation.
+func (s *Storage) Maintain(alg string, every, ttl time.Duration) (rotated bool, err error) {
+	if _, err := s.Get(); err != nil {
+		return false, err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.external {
+		return false, nil
+	}
+
+	next, changed := s.ring.Prune(ttl)
+
+	active := next.Active()
+	switch {
+	case active == nil:
+		// First key: the tokens signed with the legacy key stay valid
+		// until they expire.
+		next.LegacyUntil = time.Now().Add(ttl).Unix()
+		rotated = true
+	case active.Algorithm != alg:
+		rotated = true
+	case every > 0 && time.Since(time.Unix(active.CreatedAt, 0)) >= every:
+		rotated = true
+	}
+
+	if rotated {
+		next, err = next.Rotate(alg)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if !rotated && !changed {
+		return false, nil
+	}
+
+	if err := s.back.Save(next); err != nil {
+		return false, err
+	}
+
+	s.ring = next
+	return rotated, nil
+}
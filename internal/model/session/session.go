@@ -0,0 +1,52 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+)
+
+// Session describes a login session bound to an issued token ID (jti).
+type Session struct {
+	ID         string `storm:"id" json:"id"`
+	UserID     uint   `storm:"index" json:"userID"`
+	Device     string `json:"device"`
+	IP         string `json:"ip"`
+	CreatedAt  int64  `json:"createdAt"`
+	LastActive int64  `json:"lastActive"`
+	ExpiresAt  int64  `json:"expiresAt"`
+	Revoked    bool   `json:"revoked"`
+	Current    bool   `json:"current,omitempty"`
+	// ActorID and Actor identify the admin impersonating the user.
+	ActorID uint   `json:"actorID,omitempty"`
+	Actor   string `json:"actor,omitempty"`
+}
+
+// New creates a session for a user with a random ID.
+func New(userID uint, device, ip string, expiration time.Duration) (*Session, error) {
+	b := make([]byte, 18)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &Session{
+		ID:         base64.RawURLEncoding.EncodeToString(b),
+		UserID:     userID,
+		Device:     device,
+		IP:         ip,
+		CreatedAt:  now.Unix(),
+		LastActive: now.Unix(),
+		ExpiresAt:  now.Add(expiration).Unix(),
+	}, nil
+}
+
+// Expired checks if the session is past its expiration time.
+func (s *Session) Expired() bool {
+	return s.ExpiresAt != 0 && s.ExpiresAt <= time.Now().Unix()
+}
+
+// Valid checks if the session can still be used to authenticate requests.
+func (s *Session) Valid() bool {
+	return !s.Revoked && !s.Expired()
+}
@@ -0,0 +1,134 @@
+package session
+
+import (
+	"errors"
+	"time"
+
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+// activityResolution is the minimum delay between two writes of the
+// last activity of a session, to avoid hitting the database on every request.
+const activityResolution = time.Minute
+
+// StorageBackend is the interface to implement for a session storage.
+type StorageBackend interface {
+	Get(id string) (*Session, error)
+	FindByUserID(id uint) ([]*Session, error)
+	Save(s *Session) error
+	Delete(id string) error
+}
+
+// Storage is a session storage.
+type Storage struct {
+	back StorageBackend
+}
+
+// NewStorage creates a session storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Get wraps a StorageBackend.Get.
+func (s *Storage) Get(id string) (*Session, error) {
+	return s.back.Get(id)
+}
+
+// FindByUserID returns the sessions of a user, dropping the expired ones.
+func (s *Storage) FindByUserID(id uint) ([]*Session, error) {
+	sessions, err := s.back.FindByUserID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	active := sessions[:0]
+	for _, sess := range sessions {
+		if sess.Expired() {
+			if err := s.back.Delete(sess.ID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		active = append(active, sess)
+	}
+
+	return active, nil
+}
+
+// Save wraps a StorageBackend.Save.
+func (s *Storage) Save(sess *Session) error {
+	return s.back.Save(sess)
+}
+
+// Touch updates the last activity and IP of a session.
+func (s *Storage) Touch(sess *Session, ip string) error {
+	now := time.Now()
+	if sess.IP == ip && now.Sub(time.Unix(sess.LastActive, 0)) < activityResolution {
+		return nil
+	}
+
+	sess.IP = ip
+	sess.LastActive = now.Unix()
+	return s.back.Save(sess)
+}
+
+// Revoke marks a session as revoked. Revoked sessions are kept until they
+// expire so that the tokens referencing them are rejected.
+func (s *Storage) Revoke(id string) error {
+	sess, err := s.back.Get(id)
+	if err != nil {
+		return err
+	}
+
+	sess.Revoked = true
+	return s.back.Save(sess)
+}
+
+// RevokeAll revokes every session of a user except the ones listed in keep.
+// It returns the number of revoked sessions.
+func (s *Storage) RevokeAll(userID uint, keep ...string) (int, error) {
+	sessions, err := s.FindByUserID(userID)
+	if errors.Is(err, fberrors.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+outer:
+	for _, sess := range sessions {
+		if sess.Revoked {
+			continue
+		}
+		for _, id := range keep {
+			if sess.ID == id {
+				continue outer
+			}
+		}
+
+		sess.Revoked = true
+		if err := s.back.Save(sess); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// DeleteByUserID removes every session of a user.
+func (s *Storage) DeleteByUserID(userID uint) error {
+	sessions, err := s.back.FindByUserID(userID)
+	if errors.Is(err, fberrors.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, sess := range sessions {
+		err = errors.Join(err, s.back.Delete(sess.ID))
+	}
+	return err
+}
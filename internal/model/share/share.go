@@ -17,4 +17,6 @@ type Link struct {
 	// URL-Safe and is used to download links in password-protected shares via a
 	// query arg.
 	Token string `json:"token,omitempty"`
+	// Album is the ID of the shared album, in which case Path is empty.
+	Album uint `json:"album,omitempty" storm:"index"`
 }
@@ -0,0 +1,96 @@
+package signup
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/nulnl/nulyun/internal/model/users"
+)
+
+// VerificationExpiration is the lifetime of an email verification link.
+const VerificationExpiration = 48 * time.Hour
+
+// Invite is an invitation code allowing to sign up.
+type Invite struct {
+	Code      string             `storm:"id" json:"code"`
+	CreatedBy uint               `json:"createdBy"`
+	CreatedAt int64              `json:"createdAt"`
+	MaxUses   uint               `json:"maxUses"` // 0 means unlimited
+	Uses      uint               `json:"uses"`
+	ExpiresAt int64              `json:"expiresAt"` // 0 means never
+	Groups    []string           `json:"groups"`
+	Perm      *users.Permissions `json:"perm"` // nil keeps the default permissions
+}
+
+// Usable checks if the invitation can still be used.
+func (i *Invite) Usable() bool {
+	if i.ExpiresAt != 0 && i.ExpiresAt <= time.Now().Unix() {
+		return false
+	}
+
+	return i.MaxUses == 0 || i.Uses < i.MaxUses
+}
+
+// Apply sets the groups and permissions of the invitation to a user.
+func (i *Invite) Apply(u *users.User) {
+	if len(i.Groups) > 0 {
+		u.Groups = append([]string(nil), i.Groups...)
+	}
+	if i.Perm != nil {
+		u.Perm = *i.Perm
+		// Invitations can't grant administration rights.
+		u.Perm.Admin = false
+	}
+}
+
+// Verification is a pending email address verification.
+type Verification struct {
+	Token     string `storm:"id" json:"token"`
+	UserID    uint   `storm:"index" json:"userID"`
+	Email     string `json:"email"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// Expired checks if the verification link can no longer be used.
+func (v *Verification) Expired() bool {
+	return v.ExpiresAt <= time.Now().Unix()
+}
+
+// NewInvite creates an invitation with a random code.
+func NewInvite(createdBy uint) (*Invite, error) {
+	code, err := randomString(9)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Invite{
+		Code:      code,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now().Unix(),
+	}, nil
+}
+
+// NewVerification creates a verification of the email of a user.
+func NewVerification(userID uint, email string) (*Verification, error) {
+	token, err := randomString(24)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verification{
+		Token:     token,
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().Add(VerificationExpiration).Unix(),
+	}, nil
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
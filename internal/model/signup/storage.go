@@ -0,0 +1,97 @@
+package signup
+
+import (
+	"sync"
+
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+// StorageBackend is the interface to implement for a signup storage.
+type StorageBackend interface {
+	GetInvite(code string) (*Invite, error)
+	Invites() ([]*Invite, error)
+	SaveInvite(i *Invite) error
+	DeleteInvite(code string) error
+	GetVerification(token string) (*Verification, error)
+	SaveVerification(v *Verification) error
+	DeleteVerifications(userID uint) error
+}
+
+// Storage is a signup storage.
+type Storage struct {
+	back StorageBackend
+	mux  sync.Mutex
+}
+
+// NewStorage creates a signup storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// GetInvite wraps a StorageBackend.GetInvite.
+func (s *Storage) GetInvite(code string) (*Invite, error) {
+	return s.back.GetInvite(code)
+}
+
+// Invites wraps a StorageBackend.Invites.
+func (s *Storage) Invites() ([]*Invite, error) {
+	return s.back.Invites()
+}
+
+// SaveInvite wraps a StorageBackend.SaveInvite.
+func (s *Storage) SaveInvite(i *Invite) error {
+	return s.back.SaveInvite(i)
+}
+
+// DeleteInvite wraps a StorageBackend.DeleteInvite.
+func (s *Storage) DeleteInvite(code string) error {
+	return s.back.DeleteInvite(code)
+}
+
+// UseInvite consumes one use of an invitation, failing with
+// fberrors.ErrNotExist if it doesn't exist or can't be used anymore.
+func (s *Storage) UseInvite(code string) (*Invite, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	i, err := s.back.GetInvite(code)
+	if err != nil {
+		return nil, err
+	}
+
+	if !i.Usable() {
+		return nil, fberrors.ErrNotExist
+	}
+
+	i.Uses++
+	return i, s.back.SaveInvite(i)
+}
+
+// SaveVerification wraps a StorageBackend.SaveVerification.
+func (s *Storage) SaveVerification(v *Verification) error {
+	return s.back.SaveVerification(v)
+}
+
+// Verify consumes a verification token, failing with fberrors.ErrNotExist
+// if it doesn't exist or expired.
+func (s *Storage) Verify(token string) (*Verification, error) {
+	v, err := s.back.GetVerification(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.back.DeleteVerifications(v.UserID); err != nil {
+		return nil, err
+	}
+
+	if v.Expired() {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return v, nil
+}
+
+// DeleteVerifications wraps a StorageBackend.DeleteVerifications.
+func (s *Storage) DeleteVerifications(userID uint) error {
+	return s.back.DeleteVerifications(userID)
+}
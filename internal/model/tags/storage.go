@@ -0,0 +1,47 @@
+package tags
+
+import (
+	"errors"
+
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+// StorageBackend is the interface to implement for a tags storage.
+type StorageBackend interface {
+	Get(userID uint, fileID uint64) (*Labels, error)
+	FindByUserID(id uint) ([]*Labels, error)
+	Save(l *Labels) error
+	Delete(userID uint, fileID uint64) error
+}
+
+// Storage is a tags storage.
+type Storage struct {
+	back StorageBackend
+}
+
+// NewStorage creates a tags storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Get returns the labels of a user on a file, empty if it has none.
+func (s *Storage) Get(userID uint, fileID uint64) (*Labels, error) {
+	l, err := s.back.Get(userID, fileID)
+	if errors.Is(err, fberrors.ErrNotExist) {
+		return New(userID, fileID), nil
+	}
+	return l, err
+}
+
+// FindByUserID wraps a StorageBackend.FindByUserID.
+func (s *Storage) FindByUserID(id uint) ([]*Labels, error) {
+	return s.back.FindByUserID(id)
+}
+
+// Save saves the labels, or deletes them when they're empty.
+func (s *Storage) Save(l *Labels) error {
+	if l.Empty() {
+		return s.back.Delete(l.UserID, l.FileID)
+	}
+	return s.back.Save(l)
+}
@@ -0,0 +1,81 @@
+package tags
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLength is the length of the longest tag, in characters.
+const maxLength = 64
+
+var ErrInvalidTag = fmt.Errorf("tags must have 1 to %d characters, without commas", maxLength)
+
+// Labels are the tags and the favorite mark of a user on a file.
+type Labels struct {
+	ID       string   `storm:"id" json:"id"`
+	UserID   uint     `storm:"index" json:"userID"`
+	FileID   uint64   `storm:"index" json:"fileID"`
+	Tags     []string `json:"tags"`
+	Favorite bool     `json:"favorite"`
+}
+
+// New creates the empty labels of a user on a file.
+func New(userID uint, fileID uint64) *Labels {
+	return &Labels{
+		ID:     LabelsID(userID, fileID),
+		UserID: userID,
+		FileID: fileID,
+		Tags:   []string{},
+	}
+}
+
+// LabelsID returns the ID of the labels of a user on a file.
+func LabelsID(userID uint, fileID uint64) string {
+	return fmt.Sprintf("%d/%d", userID, fileID)
+}
+
+// Clean trims a tag and checks it.
+func Clean(tag string) (string, error) {
+	tag = strings.Join(strings.Fields(tag), " ")
+	if tag == "" || utf8.RuneCountInString(tag) > maxLength || strings.Contains(tag, ",") {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+// Has checks if the file has a tag, in any case.
+func (l *Labels) Has(tag string) bool {
+	return slices.ContainsFunc(l.Tags, func(t string) bool { return strings.EqualFold(t, tag) })
+}
+
+// Add adds the tags the file doesn't have yet, in any case.
+func (l *Labels) Add(tags ...string) error {
+	for _, tag := range tags {
+		tag, err := Clean(tag)
+		if err != nil {
+			return err
+		}
+		if !l.Has(tag) {
+			l.Tags = append(l.Tags, tag)
+		}
+	}
+	slices.SortFunc(l.Tags, func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+	return nil
+}
+
+// Remove removes tags from the file, in any case.
+func (l *Labels) Remove(tags ...string) {
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(tag), " ")
+		l.Tags = slices.DeleteFunc(l.Tags, func(t string) bool { return strings.EqualFold(t, tag) })
+	}
+}
+
+// Empty checks if the labels can be dropped.
+func (l *Labels) Empty() bool {
+	return len(l.Tags) == 0 && !l.Favorite
+}
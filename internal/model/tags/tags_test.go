@@ -0,0 +1,46 @@
+package tags
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLabelsAdd(t *testing.T) {
+	l := New(1, 2)
+	if err := l.Add("  work  notes ", "Invoice", "invoice", "2024"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2024", "Invoice", "work notes"}; !slices.Equal(l.Tags, want) {
+		t.Errorf("Tags = %v, want %v", l.Tags, want)
+	}
+
+	l.Remove("INVOICE", "work   notes")
+	if want := []string{"2024"}; !slices.Equal(l.Tags, want) {
+		t.Errorf("Tags = %v, want %v", l.Tags, want)
+	}
+
+	l.Remove("2024")
+	if !l.Empty() {
+		t.Errorf("Empty() = false, want true")
+	}
+}
+
+func TestClean(t *testing.T) {
+	testCases := map[string]bool{
+		"photos":                true,
+		"":                      false,
+		"   ":                   false,
+		"a,b":                   false,
+		strings.Repeat("é", 64): true,
+		strings.Repeat("é", 65): false,
+	}
+
+	for tag, valid := range testCases {
+		_, err := Clean(tag)
+		if got := !errors.Is(err, ErrInvalidTag); got != valid {
+			t.Errorf("Clean(%q) valid = %v, want %v", tag, got, valid)
+		}
+	}
+}
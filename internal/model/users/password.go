@@ -112,6 +112,17 @@ func DecryptSymmetric(encryptionKey []byte, cipherTextB64, nonceB64 string) (str
 	return string(secret), nil
 }
 
+// ReencryptSymmetric decrypts a cipher text with oldKey and encrypts it
+// again with newKey, returning the new cipher text and nonce in base64.
+func ReencryptSymmetric(oldKey, newKey []byte, cipherTextB64, nonceB64 string) (string, string, error) {
+	secret, err := DecryptSymmetric(oldKey, cipherTextB64, nonceB64)
+	if err != nil {
+		return "", "", err
+	}
+
+	return EncryptSymmetric(newKey, []byte(secret))
+}
+
 // Decrypt the secret and validate the code
 func CheckTOTP(totpEncryptionKey []byte, encryptedSecretB64, nonceB64, code string) (bool, error) {
 	if len(totpEncryptionKey) != 32 {
@@ -2,6 +2,8 @@ package users
 
 import (
 	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/spf13/afero"
 
@@ -19,29 +21,46 @@ const (
 
 // User describes a user.
 type User struct {
-	ID                uint          `storm:"id,increment" json:"id"`
-	Username          string        `storm:"unique" json:"username"`
-	Password          string        `json:"password"`
-	Scope             string        `json:"scope"`
-	Locale            string        `json:"locale"`
-	LockPassword      bool          `json:"lockPassword"`
-	ViewMode          ViewMode      `json:"viewMode"`
-	SingleClick       bool          `json:"singleClick"`
-	Perm              Permissions   `json:"perm"`
-	Sorting           files.Sorting `json:"sorting"`
-	Fs                afero.Fs      `json:"-" yaml:"-"`
-	HideDotfiles      bool          `json:"hideDotfiles"`
-	HideHiddenFolders bool          `json:"hideHiddenFolders"`
-	DateFormat        bool          `json:"dateFormat"`
-	AceEditorTheme    string        `json:"aceEditorTheme"`
-	TOTPSecret        string        `json:"totpSecret"`
-	TOTPNonce         string        `json:"totpNonce"`
-	TOTPVerified      bool          `json:"totpVerified"`
-	TOTPEnabled       bool          `json:"totpEnabled"`
-	RecoveryCodes     []string      `json:"recoveryCodes"`
-	StorageQuota      int64         `json:"storageQuota"` // in bytes, 0 means unlimited
+	ID                   uint          `storm:"id,increment" json:"id"`
+	Username             string        `storm:"unique" json:"username"`
+	Password             string        `json:"password"`
+	Scope                string        `json:"scope"`
+	Locale               string        `json:"locale"`
+	LockPassword         bool          `json:"lockPassword"`
+	ViewMode             ViewMode      `json:"viewMode"`
+	SingleClick          bool          `json:"singleClick"`
+	Perm                 Permissions   `json:"perm"`
+	Commands             []string      `json:"commands"`
+	Sorting              files.Sorting `json:"sorting"`
+	Fs                   afero.Fs      `json:"-" yaml:"-"`
+	HideDotfiles         bool          `json:"hideDotfiles"`
+	HideHiddenFolders    bool          `json:"hideHiddenFolders"`
+	DateFormat           bool          `json:"dateFormat"`
+	AceEditorTheme       string        `json:"aceEditorTheme"`
+	TOTPSecret           string        `json:"totpSecret"`
+	TOTPNonce            string        `json:"totpNonce"`
+	TOTPVerified         bool          `json:"totpVerified"`
+	TOTPEnabled          bool          `json:"totpEnabled"`
+	RecoveryCodes        []string      `json:"recoveryCodes"`
+	StorageQuota         int64         `json:"storageQuota"` // in bytes, 0 means unlimited
+	LockedUntil          int64         `json:"lockedUntil"`  // unix time, set after too many failed logins
+	Groups               []string      `json:"groups"`
+	Email                string        `json:"email"`
+	AwaitingApproval     bool          `json:"awaitingApproval"`     // set on signup until an admin approves the user
+	AwaitingVerification bool          `json:"awaitingVerification"` // set on signup until the email is verified
+	Disabled             bool          `json:"disabled"`
+	ExpiresAt            int64         `json:"expiresAt"` // unix time, 0 means never
 }
 
+// Account statuses preventing a user from logging in.
+const (
+	StatusActive     = ""
+	StatusDisabled   = "disabled"
+	StatusExpired    = "expired"
+	StatusPending    = "pending"
+	StatusUnverified = "unverified"
+)
+
 var checkableFields = []string{
 	"Username",
 	"Password",
@@ -87,6 +106,44 @@ func (u *User) Clean(baseScope string, fields ...string) error {
 	return nil
 }
 
+// Locked checks if the account is temporarily locked and returns how long
+// the lock lasts.
+func (u *User) Locked() (time.Duration, bool) {
+	wait := time.Until(time.Unix(u.LockedUntil, 0))
+	return wait, u.LockedUntil != 0 && wait > 0
+}
+
+// Expired checks if the account is past its expiry date.
+func (u *User) Expired() bool {
+	return u.ExpiresAt != 0 && u.ExpiresAt <= time.Now().Unix()
+}
+
+// Status returns why the account can't be used, or StatusActive.
+func (u *User) Status() string {
+	switch {
+	case u.Disabled:
+		return StatusDisabled
+	case u.Expired():
+		return StatusExpired
+	case u.AwaitingVerification:
+		return StatusUnverified
+	case u.AwaitingApproval:
+		return StatusPending
+	default:
+		return StatusActive
+	}
+}
+
+// TwoFactorEnrolled checks if the user completed the TOTP setup.
+func (u *User) TwoFactorEnrolled() bool {
+	return u.TOTPSecret != "" && u.TOTPVerified
+}
+
+// CanExecute checks if the user is allowed to run the command name.
+func (u *User) CanExecute(name string) bool {
+	return u.Perm.Execute && slices.Contains(u.Commands, name)
+}
+
 // FullPath gets the full path for a user's relative path.
 func (u *User) FullPath(path string) string {
 	return afero.FullBaseFsPath(u.Fs.(*afero.BasePathFs), path)
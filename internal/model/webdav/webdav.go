@@ -3,7 +3,9 @@ package webdav
 import (
 	"context"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -13,12 +15,26 @@ import (
 	"github.com/nulnl/nulyun/internal/model/users"
 )
 
+// Observer is notified of every request served by the WebDAV handler.
+// name and dst are the target and the destination of the request in the
+// scope of the user, err is the error of the request if any.
+type Observer func(r *http.Request, u *users.User, name, dst string, err error)
+
+// Guard is called before serving a request, with the same arguments as an
+// Observer. The request is refused with the returned error, if any.
+type Guard func(r *http.Request, u *users.User, name, dst string) error
+
 // Handler is the WebDAV handler
 type Handler struct {
 	storage *Storage
 	users   users.Store
 	baseURL string
 	server  *settings.Server
+
+	// Guard, if set, can refuse the requests.
+	Guard Guard
+	// Observer, if set, is notified of the served requests.
+	Observer Observer
 }
 
 // NewHandler creates a new WebDAV handler
@@ -43,6 +59,10 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "User not found", http.StatusUnauthorized)
 		return
 	}
+	if user.Status() != users.StatusActive {
+		http.Error(w, "Account disabled", http.StatusUnauthorized)
+		return
+	}
 	token, err := h.storage.GetByToken(tokenStr)
 	if err != nil || token.UserID != user.ID {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -79,6 +99,33 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		LockSystem: webdav.NewMemLS(),
 	}
 
+	scoped := func(p string) string {
+		p, ok := strings.CutPrefix(p, mountPath)
+		if !ok {
+			return ""
+		}
+		return path.Join(tokenPath, p)
+	}
+	destination := func(r *http.Request) string {
+		if u, err := url.Parse(r.Header.Get("Destination")); err == nil && u.Path != "" {
+			return scoped(u.Path)
+		}
+		return ""
+	}
+
+	if h.Guard != nil {
+		if err := h.Guard(r, user, scoped(r.URL.Path), destination(r)); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	if h.Observer != nil {
+		handler.Logger = func(r *http.Request, err error) {
+			h.Observer(r, user, scoped(r.URL.Path), destination(r), err)
+		}
+	}
+
 	handler.ServeHTTP(w, r)
 }
 
@@ -0,0 +1,67 @@
+package webhook
+
+// DeliveriesKept is the number of deliveries kept in the log of a webhook.
+const DeliveriesKept = 100
+
+// StorageBackend is the interface to implement for a webhook storage.
+type StorageBackend interface {
+	Get(id uint) (*Webhook, error)
+	All() ([]*Webhook, error)
+	FindByUserID(id uint) ([]*Webhook, error)
+	Save(w *Webhook) error
+	// Delete removes a webhook and its deliveries.
+	Delete(id uint) error
+	// SaveDelivery records a delivery and drops the oldest ones of the
+	// webhook beyond keep.
+	SaveDelivery(d *Delivery, keep int) error
+	// Deliveries returns the deliveries of a webhook, newest first.
+	Deliveries(id uint) ([]*Delivery, error)
+}
+
+// Storage is a webhook storage.
+type Storage struct {
+	back StorageBackend
+}
+
+// NewStorage creates a webhook storage from a backend.
+func NewStorage(back StorageBackend) *Storage {
+	return &Storage{back: back}
+}
+
+// Get wraps a StorageBackend.Get.
+func (s *Storage) Get(id uint) (*Webhook, error) {
+	return s.back.Get(id)
+}
+
+// All wraps a StorageBackend.All.
+func (s *Storage) All() ([]*Webhook, error) {
+	return s.back.All()
+}
+
+// FindByUserID wraps a StorageBackend.FindByUserID.
+func (s *Storage) FindByUserID(id uint) ([]*Webhook, error) {
+	return s.back.FindByUserID(id)
+}
+
+// Save validates and saves a webhook.
+func (s *Storage) Save(w *Webhook) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	return s.back.Save(w)
+}
+
+// Delete wraps a StorageBackend.Delete.
+func (s *Storage) Delete(id uint) error {
+	return s.back.Delete(id)
+}
+
+// SaveDelivery records a delivery in the log of its webhook.
+func (s *Storage) SaveDelivery(d *Delivery) error {
+	return s.back.SaveDelivery(d, DeliveriesKept)
+}
+
+// Deliveries wraps a StorageBackend.Deliveries.
+func (s *Storage) Deliveries(id uint) ([]*Delivery, error) {
+	return s.back.Deliveries(id)
+}
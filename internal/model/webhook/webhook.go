@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/nulnl/nulyun/internal/events"
+)
+
+var (
+	ErrInvalidURL   = errors.New("webhook URL must be an absolute http or https URL")
+	ErrInvalidEvent = errors.New("unknown event type")
+)
+
+// Webhook is a subscription delivering the matching events to an URL.
+type Webhook struct {
+	ID     uint   `storm:"id,increment" json:"id"`
+	UserID uint   `storm:"index" json:"userID"`
+	URL    string `json:"url"`
+	// Secret signs the payloads. It is only shown when the webhook is
+	// created.
+	Secret string `json:"secret,omitempty"`
+	// Events are the event types delivered, all of them if empty.
+	Events []string `json:"events"`
+	// PathPrefix restricts the file events to a directory, relative to
+	// the server root when AllUsers is set.
+	PathPrefix string `json:"pathPrefix"`
+	// AllUsers delivers the events of every user, with their paths
+	// relative to the server root. Only admins can set it.
+	AllUsers  bool  `json:"allUsers"`
+	Active    bool  `json:"active"`
+	CreatedAt int64 `json:"createdAt"`
+}
+
+// Delivery is an attempt to deliver an event to a webhook.
+type Delivery struct {
+	ID        uint64 `storm:"id,increment" json:"id"`
+	WebhookID uint   `storm:"index" json:"webhookID"`
+	EventID   string `json:"eventID"`
+	Event     string `json:"event"`
+	Time      int64  `json:"time"`
+	Attempt   int    `json:"attempt"`
+	Status    int    `json:"status,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Duration  int64  `json:"duration"` // milliseconds
+	Success   bool   `json:"success"`
+}
+
+// New creates an active webhook of a user with a random secret.
+func New(userID uint, rawURL string) (*Webhook, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	w := &Webhook{
+		UserID:    userID,
+		URL:       rawURL,
+		Secret:    hex.EncodeToString(b),
+		Active:    true,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	return w, w.Validate()
+}
+
+// Validate checks the URL and the event types of the webhook.
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidURL
+	}
+
+	for _, t := range w.Events {
+		if !slices.Contains(events.Types, t) {
+			return ErrInvalidEvent
+		}
+	}
+
+	return nil
+}
+
+// Event returns an event as delivered to the webhook. The paths of the
+// events of every user are relative to the server root, as their scopes
+// differ, and the ones of the events of the owner to its scope.
+func (w *Webhook) Event(e events.Event) events.Event {
+	if w.AllUsers {
+		return e.Rooted()
+	}
+	return e
+}
+
+// Matches checks if an event must be delivered to the webhook. The changes
+// seen by the file watcher are not delivered, as the changes made through
+// the app would be delivered twice.
+func (w *Webhook) Matches(e *events.Event) bool {
+	ev := w.Event(*e)
+	e = &ev
+
+	switch {
+	case !w.Active, e.Source == events.SourceWatcher:
+		return false
+	case !w.AllUsers && e.UserID != w.UserID:
+		return false
+	case len(w.Events) > 0 && !slices.Contains(w.Events, e.Type):
+		return false
+	case w.PathPrefix != "" && !e.InPath(w.PathPrefix):
+		return false
+	}
+
+	return true
+}
+
+// Sign returns the signature of a payload, sent in the X-Nulyun-Signature
+// header.
+func (w *Webhook) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(w.Secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/nulnl/nulyun/internal/events"
+)
+
+func TestWebhookMatches(t *testing.T) {
+	e := &events.Event{Type: events.FileMoved, UserID: 2, Path: "/docs/a.txt", Destination: "/archive/a.txt", Scope: "/users/bob"}
+
+	testCases := map[string]struct {
+		hook  Webhook
+		match bool
+	}{
+		"owner":             {Webhook{UserID: 2, Active: true}, true},
+		"other user":        {Webhook{UserID: 3, Active: true}, false},
+		"all users":         {Webhook{UserID: 1, AllUsers: true, Active: true}, true},
+		"inactive":          {Webhook{UserID: 2}, false},
+		"event type":        {Webhook{UserID: 2, Active: true, Events: []string{events.FileMoved}}, true},
+		"other event type":  {Webhook{UserID: 2, Active: true, Events: []string{events.FileCreated}}, false},
+		"path prefix":       {Webhook{UserID: 2, Active: true, PathPrefix: "/docs"}, true},
+		"destination":       {Webhook{UserID: 2, Active: true, PathPrefix: "/archive"}, true},
+		"partial directory": {Webhook{UserID: 2, Active: true, PathPrefix: "/doc"}, false},
+		"all users root":    {Webhook{UserID: 1, AllUsers: true, Active: true, PathPrefix: "/users/bob/docs"}, true},
+		"all users scope":   {Webhook{UserID: 1, AllUsers: true, Active: true, PathPrefix: "/docs"}, false},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			if got := tc.hook.Matches(e); got != tc.match {
+				t.Errorf("Matches() = %v, want %v", got, tc.match)
+			}
+		})
+	}
+}
+
+func TestWebhookEvent(t *testing.T) {
+	e := events.Event{Type: events.FileMoved, UserID: 2, Path: "/docs/a.txt", Destination: "/archive/a.txt", Scope: "/users/bob"}
+
+	if got := (&Webhook{UserID: 2}).Event(e); got.Path != "/docs/a.txt" || got.Destination != "/archive/a.txt" {
+		t.Errorf("event of the owner = %+v, want its paths relative to the scope", got)
+	}
+	got := (&Webhook{UserID: 1, AllUsers: true}).Event(e)
+	if got.Path != "/users/bob/docs/a.txt" || got.Destination != "/users/bob/archive/a.txt" {
+		t.Errorf("event of every user = %+v, want its paths relative to the root", got)
+	}
+
+	e = events.Event{Type: events.UserCreated, UserID: 2, Scope: "/users/bob"}
+	if got := (&Webhook{UserID: 1, AllUsers: true}).Event(e); got.Path != "" || got.Destination != "" {
+		t.Errorf("event without paths = %+v, want none added", got)
+	}
+}
+
+func TestWebhookSign(t *testing.T) {
+	w := &Webhook{Secret: "secret"}
+
+	// printf '{}' | openssl dgst -sha256 -hmac secret
+	const want = "sha256=77325902caca812dc259733aacd046b73817372c777b8d95b402647474516e13"
+	if got := w.Sign([]byte("{}")); got != want {
+		t.Errorf("Sign() = %q, want %q", got, want)
+	}
+}
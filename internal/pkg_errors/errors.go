@@ -24,6 +24,7 @@ var (
 	ErrInvalidRequestParams = errors.New("invalid request params")
 	ErrSourceIsParent       = errors.New("source is parent")
 	ErrRootUserDeletion     = errors.New("user with id 1 can't be deleted")
+	ErrHookVetoed           = errors.New("vetoed by hook")
 )
 
 type ErrShortPassword struct {
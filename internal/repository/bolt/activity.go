@@ -0,0 +1,86 @@
+package bolt
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+
+	"github.com/nulnl/nulyun/internal/model/activity"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+type activityBackend struct {
+	db *storm.DB
+}
+
+func (s activityBackend) Record(i *activity.Item, keep int) error {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var items []*activity.Item
+	err = tx.Find("UserID", i.UserID, &items)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+
+	// The newest items are kept.
+	slices.SortFunc(items, func(a, b *activity.Item) int { return cmp.Compare(a.ID, b.ID) })
+	kept := 0
+	for j := len(items) - 1; j >= 0; j-- {
+		v := items[j]
+		same := v.Action == i.Action && v.Path == i.Path && v.Destination == i.Destination
+		if !same && kept < keep-1 {
+			kept++
+			continue
+		}
+		if err := tx.DeleteStruct(v); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Save(i); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindByUserID and FindOthers rely on the IDs being stored in increasing
+// order to return the newest items first without sorting them.
+func (s activityBackend) FindByUserID(id uint) ([]*activity.Item, error) {
+	v := []*activity.Item{}
+	err := s.db.Select(q.Eq("UserID", id)).Reverse().Find(&v)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (s activityBackend) Last(id uint) (*activity.Item, error) {
+	var v activity.Item
+	err := s.db.Select(q.Eq("UserID", id)).Reverse().First(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+func (s activityBackend) FindOthers(id uint) ([]*activity.Item, error) {
+	v := []*activity.Item{}
+	err := s.db.Select(q.Not(q.Eq("UserID", id))).Reverse().Find(&v)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	return v, nil
+}
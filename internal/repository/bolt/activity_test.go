@@ -0,0 +1,106 @@
+package bolt
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/asdine/storm/v3"
+
+	"github.com/nulnl/nulyun/internal/model/activity"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+func newActivityBackend(t *testing.T) activityBackend {
+	t.Helper()
+	db, err := storm.Open(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return activityBackend{db: db}
+}
+
+// itemNames returns the action and path of the items of a user, newest
+// first.
+func itemNames(t *testing.T, s activityBackend, id uint) []string {
+	t.Helper()
+	items, err := s.FindByUserID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, i := range items {
+		names = append(names, i.Action+" "+i.Path)
+	}
+	return names
+}
+
+func TestActivityRecordBound(t *testing.T) {
+	s := newActivityBackend(t)
+
+	if err := s.Record(&activity.Item{UserID: 2, Action: activity.ActionCreated, Path: "/other"}, 3); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/a", "/b", "/c", "/d", "/e"} {
+		if err := s.Record(&activity.Item{UserID: 1, Action: activity.ActionCreated, Path: p}, 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := itemNames(t, s, 1), []string{"created /e", "created /d", "created /c"}; !slices.Equal(got, want) {
+		t.Errorf("items = %v, want %v", got, want)
+	}
+	// The bound is by user.
+	if got, want := itemNames(t, s, 2), []string{"created /other"}; !slices.Equal(got, want) {
+		t.Errorf("items of the other user = %v, want %v", got, want)
+	}
+}
+
+func TestActivityRecordDuplicates(t *testing.T) {
+	s := newActivityBackend(t)
+	record := func(keep int, items ...*activity.Item) {
+		t.Helper()
+		for _, i := range items {
+			i.UserID = 1
+			if err := s.Record(i, keep); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	record(10,
+		&activity.Item{Action: activity.ActionOpened, Path: "/a"},
+		&activity.Item{Action: activity.ActionOpened, Path: "/b"},
+		&activity.Item{Action: activity.ActionModified, Path: "/a"},
+		&activity.Item{Action: activity.ActionOpened, Path: "/a"},
+		&activity.Item{Action: activity.ActionMoved, Path: "/a", Destination: "/c"},
+		&activity.Item{Action: activity.ActionMoved, Path: "/a", Destination: "/c"},
+	)
+	want := []string{"moved /a", "opened /a", "modified /a", "opened /b"}
+	if got := itemNames(t, s, 1); !slices.Equal(got, want) {
+		t.Errorf("items = %v, want %v", got, want)
+	}
+
+	// At the bound, a duplicate replaces its previous item rather than the
+	// oldest one.
+	record(4, &activity.Item{Action: activity.ActionOpened, Path: "/b"})
+	want = []string{"opened /b", "moved /a", "opened /a", "modified /a"}
+	if got := itemNames(t, s, 1); !slices.Equal(got, want) {
+		t.Errorf("items = %v, want %v", got, want)
+	}
+	record(4, &activity.Item{Action: activity.ActionOpened, Path: "/a"})
+	want = []string{"opened /a", "opened /b", "moved /a", "modified /a"}
+	if got := itemNames(t, s, 1); !slices.Equal(got, want) {
+		t.Errorf("items = %v, want %v", got, want)
+	}
+
+	last, err := s.Last(1)
+	if err != nil || last.Action != activity.ActionOpened || last.Path != "/a" {
+		t.Errorf("Last() = %+v, %v, want the open of /a", last, err)
+	}
+	if _, err := s.Last(2); !errors.Is(err, fberrors.ErrNotExist) {
+		t.Errorf("Last() of a user without items: %v, want ErrNotExist", err)
+	}
+}
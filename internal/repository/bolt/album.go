@@ -0,0 +1,62 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+
+	"github.com/nulnl/nulyun/internal/model/album"
+	"github.com/nulnl/nulyun/internal/model/share"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+type albumBackend struct {
+	db *storm.DB
+}
+
+func (s albumBackend) Get(id uint) (*album.Album, error) {
+	var v album.Album
+	err := s.db.One("ID", id, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s albumBackend) FindByUserID(id uint) ([]*album.Album, error) {
+	v := []*album.Album{}
+	err := s.db.Find("UserID", id, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return []*album.Album{}, nil
+	}
+
+	return v, err
+}
+
+func (s albumBackend) Save(a *album.Album) error {
+	return s.db.Save(a)
+}
+
+func (s albumBackend) Delete(id uint) error {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.Select(q.Eq("Album", id)).Delete(&share.Link{})
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+
+	err = tx.DeleteStruct(&album.Album{ID: id})
+	if errors.Is(err, storm.ErrNotFound) {
+		return fberrors.ErrNotExist
+	} else if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
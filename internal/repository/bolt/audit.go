@@ -0,0 +1,66 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+
+	"github.com/nulnl/nulyun/internal/model/audit"
+)
+
+type auditBackend struct {
+	db *storm.DB
+}
+
+func (s auditBackend) Save(e *audit.Entry) error {
+	return s.db.Save(e)
+}
+
+// Query relies on the IDs being stored in increasing order to walk the
+// entries from the newest without sorting them.
+func (s auditBackend) Query(f *audit.Filter) ([]*audit.Entry, int, error) {
+	total, err := s.db.Select(f).Count(&audit.Entry{})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	v := []*audit.Entry{}
+	err = s.db.Select(f).Reverse().Skip(f.Offset).Limit(f.Limit).Find(&v)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, 0, err
+	}
+
+	return v, total, nil
+}
+
+func (s auditBackend) Prune(before int64, keep int) (int, error) {
+	count := func() (int, error) { return s.db.Count(&audit.Entry{}) }
+
+	initial, err := count()
+	if err != nil {
+		return 0, err
+	}
+
+	if before > 0 {
+		err := s.db.Select(q.Lt("Time", before)).Delete(&audit.Entry{})
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return 0, err
+		}
+	}
+
+	total, err := count()
+	if err != nil {
+		return 0, err
+	}
+
+	if keep > 0 && total > keep {
+		err := s.db.Select().Limit(total - keep).Delete(&audit.Entry{})
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return 0, err
+		}
+		total = keep
+	}
+
+	return initial - total, nil
+}
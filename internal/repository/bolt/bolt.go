@@ -4,10 +4,20 @@ import (
 	"github.com/asdine/storm/v3"
 
 	"github.com/nulnl/nulyun/internal/auth"
+	"github.com/nulnl/nulyun/internal/model/activity"
+	"github.com/nulnl/nulyun/internal/model/album"
+	"github.com/nulnl/nulyun/internal/model/audit"
+	"github.com/nulnl/nulyun/internal/model/comment"
+	"github.com/nulnl/nulyun/internal/model/fileid"
 	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/keyring"
+	"github.com/nulnl/nulyun/internal/model/session"
 	"github.com/nulnl/nulyun/internal/model/share"
+	"github.com/nulnl/nulyun/internal/model/signup"
+	"github.com/nulnl/nulyun/internal/model/tags"
 	"github.com/nulnl/nulyun/internal/model/users"
 	"github.com/nulnl/nulyun/internal/model/webdav"
+	"github.com/nulnl/nulyun/internal/model/webhook"
 	storage "github.com/nulnl/nulyun/internal/repository"
 )
 
@@ -18,6 +28,16 @@ func NewStorage(db *storm.DB) (*storage.Storage, error) {
 	settingsStore := settings.NewStorage(settingsBackend{db: db})
 	authStore := auth.NewStorage(authBackend{db: db}, userStore)
 	webdavStore := webdav.NewStorage(webdavBackend{db: db})
+	sessionStore := session.NewStorage(sessionBackend{db: db})
+	keyringStore := keyring.NewStorage(keyringBackend{db: db})
+	signupStore := signup.NewStorage(signupBackend{db: db})
+	auditStore := audit.NewStorage(auditBackend{db: db})
+	webhookStore := webhook.NewStorage(webhookBackend{db: db})
+	albumStore := album.NewStorage(albumBackend{db: db})
+	fileStore := fileid.NewStorage(fileidBackend{db: db})
+	tagsStore := tags.NewStorage(tagsBackend{db: db})
+	commentStore := comment.NewStorage(commentBackend{db: db})
+	activityStore := activity.NewStorage(activityBackend{db: db})
 
 	err := save(db, "version", 2)
 	if err != nil {
@@ -30,5 +50,17 @@ func NewStorage(db *storm.DB) (*storage.Storage, error) {
 		Share:    shareStore,
 		Settings: settingsStore,
 		WebDAV:   webdavStore,
+		Sessions: sessionStore,
+		Keyring:  keyringStore,
+		Signup:   signupStore,
+		Audit:    auditStore,
+		Webhooks: webhookStore,
+		Albums:   albumStore,
+		Files:    fileStore,
+		Tags:     tagsStore,
+		Comments: commentStore,
+		Activity: activityStore,
+
+		UserDeleter: userDeleter{db: db},
 	}, nil
 }
@@ -0,0 +1,145 @@
+package bolt
+
+import (
+	"errors"
+	"path"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+
+	"github.com/nulnl/nulyun/internal/model/activity"
+	"github.com/nulnl/nulyun/internal/model/album"
+	"github.com/nulnl/nulyun/internal/model/session"
+	"github.com/nulnl/nulyun/internal/model/share"
+	"github.com/nulnl/nulyun/internal/model/signup"
+	"github.com/nulnl/nulyun/internal/model/tags"
+	"github.com/nulnl/nulyun/internal/model/users"
+	"github.com/nulnl/nulyun/internal/model/webdav"
+	"github.com/nulnl/nulyun/internal/model/webhook"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+	storage "github.com/nulnl/nulyun/internal/repository"
+)
+
+type userDeleter struct {
+	db *storm.DB
+}
+
+func (d userDeleter) DeleteUser(id uint, transfer *storage.ShareTransfer) (*storage.UserRecords, error) {
+	if id == 1 {
+		return nil, fberrors.ErrRootUserDeletion
+	}
+
+	tx, err := d.db.Begin(true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var user users.User
+	if err := tx.One("ID", id, &user); err != nil {
+		if errors.Is(err, storm.ErrNotFound) {
+			return nil, fberrors.ErrNotExist
+		}
+		return nil, err
+	}
+
+	records := &storage.UserRecords{}
+
+	var links []*share.Link
+	if err := tx.Select(q.Eq("UserID", id)).Find(&links); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+	for _, l := range links {
+		if transfer != nil {
+			l.UserID = transfer.UserID
+			if l.Album == 0 {
+				l.Path = path.Join("/", transfer.PathPrefix, l.Path)
+			}
+			err = tx.Save(l)
+		} else {
+			err = tx.DeleteStruct(l)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	records.Shares = len(links)
+
+	var albums []*album.Album
+	if err := tx.Find("UserID", id, &albums); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+	for _, a := range albums {
+		if transfer != nil {
+			a.UserID = transfer.UserID
+			for i, p := range a.Paths {
+				a.Paths[i] = path.Join("/", transfer.PathPrefix, p)
+			}
+			err = tx.Save(a)
+		} else {
+			err = tx.DeleteStruct(a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	records.Albums = len(albums)
+
+	var tokens []*webdav.Token
+	if err := tx.Find("UserID", id, &tokens); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+	for _, t := range tokens {
+		if err := tx.DeleteStruct(t); err != nil {
+			return nil, err
+		}
+	}
+	records.WebDAVTokens = len(tokens)
+
+	var sessions []*session.Session
+	if err := tx.Find("UserID", id, &sessions); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+	for _, s := range sessions {
+		if err := tx.DeleteStruct(s); err != nil {
+			return nil, err
+		}
+	}
+	records.Sessions = len(sessions)
+
+	var hooks []*webhook.Webhook
+	if err := tx.Find("UserID", id, &hooks); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+	for _, h := range hooks {
+		err := tx.Select(q.Eq("WebhookID", h.ID)).Delete(&webhook.Delivery{})
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return nil, err
+		}
+		if err := tx.DeleteStruct(h); err != nil {
+			return nil, err
+		}
+	}
+	records.Webhooks = len(hooks)
+
+	err = tx.Select(q.Eq("UserID", id)).Delete(&tags.Labels{})
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	err = tx.Select(q.Eq("UserID", id)).Delete(&activity.Item{})
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	err = tx.Select(q.Eq("UserID", id)).Delete(&signup.Verification{})
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	if err := tx.DeleteStruct(&user); err != nil {
+		return nil, err
+	}
+
+	return records, tx.Commit()
+}
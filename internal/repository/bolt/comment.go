@@ -0,0 +1,91 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+
+	"github.com/nulnl/nulyun/internal/model/comment"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+type commentBackend struct {
+	db *storm.DB
+}
+
+func (s commentBackend) Get(id uint) (*comment.Comment, error) {
+	var v comment.Comment
+	err := s.db.One("ID", id, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s commentBackend) FindByFileID(id uint64) ([]*comment.Comment, error) {
+	v := []*comment.Comment{}
+	err := s.db.Find("FileID", id, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return []*comment.Comment{}, nil
+	}
+
+	return v, err
+}
+
+func (s commentBackend) Count(ids []uint64) (map[uint64]int, error) {
+	tx, err := s.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	counts := make(map[uint64]int, len(ids))
+	for _, id := range ids {
+		var v []*comment.Comment
+		err := tx.Find("FileID", id, &v)
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return nil, err
+		}
+		if len(v) > 0 {
+			counts[id] = len(v)
+		}
+	}
+	return counts, nil
+}
+
+func (s commentBackend) Save(c *comment.Comment) error {
+	return s.db.Save(c)
+}
+
+func (s commentBackend) Delete(id uint) error {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var c comment.Comment
+	err = tx.One("ID", id, &c)
+	if errors.Is(err, storm.ErrNotFound) {
+		return fberrors.ErrNotExist
+	} else if err != nil {
+		return err
+	}
+
+	// The replies are removed with the comments they reply to.
+	for queue := []*comment.Comment{&c}; len(queue) > 0; queue = queue[1:] {
+		var replies []*comment.Comment
+		err := tx.Find("ParentID", queue[0].ID, &replies)
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return err
+		}
+		queue = append(queue, replies...)
+
+		if err := tx.DeleteStruct(queue[0]); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,175 @@
+package bolt
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+
+	"github.com/nulnl/nulyun/internal/model/comment"
+	"github.com/nulnl/nulyun/internal/model/fileid"
+	"github.com/nulnl/nulyun/internal/model/tags"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+type fileidBackend struct {
+	db *storm.DB
+}
+
+func (s fileidBackend) Get(path string) (*fileid.File, error) {
+	var v fileid.File
+	err := s.db.One("Path", path, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s fileidBackend) Ensure(path string) (*fileid.File, error) {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var v fileid.File
+	err = tx.One("Path", path, &v)
+	if err == nil {
+		return &v, nil
+	} else if !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	v = fileid.File{Path: path}
+	if err := tx.Save(&v); err != nil {
+		return nil, err
+	}
+	return &v, tx.Commit()
+}
+
+func (s fileidBackend) Find(paths []string) (map[string]*fileid.File, error) {
+	tx, err := s.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	files := map[string]*fileid.File{}
+	for _, path := range paths {
+		var v fileid.File
+		err := tx.One("Path", path, &v)
+		if err == nil {
+			files[path] = &v
+		} else if !errors.Is(err, storm.ErrNotFound) {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func (s fileidBackend) Paths(ids []uint64) (map[uint64]string, error) {
+	var files []*fileid.File
+	err := s.db.Select(q.In("ID", ids)).Find(&files)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	paths := make(map[uint64]string, len(files))
+	for _, f := range files {
+		paths[f.ID] = f.Path
+	}
+	return paths, nil
+}
+
+// findFiles returns the identities of path and of its content.
+func findFiles(tx storm.Node, path string) ([]*fileid.File, error) {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+
+	var files []*fileid.File
+	err := tx.Prefix("Path", prefix, &files)
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+
+	var v fileid.File
+	err = tx.One("Path", path, &v)
+	if err == nil {
+		files = append(files, &v)
+	} else if !errors.Is(err, storm.ErrNotFound) {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (s fileidBackend) Exists(path string) (bool, error) {
+	files, err := findFiles(s.db, path)
+	return len(files) > 0, err
+}
+
+// deleteFiles removes the identities of path and of its content, and the
+// records attached to them.
+func deleteFiles(tx storm.Node, path string) error {
+	files, err := findFiles(tx, path)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		err := tx.Select(q.Eq("FileID", f.ID)).Delete(&tags.Labels{})
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return err
+		}
+		err = tx.Select(q.Eq("FileID", f.ID)).Delete(&comment.Comment{})
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return err
+		}
+		if err := tx.DeleteStruct(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s fileidBackend) Move(path, dst string) error {
+	if path == dst {
+		return nil
+	}
+
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	files, err := findFiles(tx, path)
+	if err != nil {
+		return err
+	}
+	if err := deleteFiles(tx, dst); err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		f.Path = dst + strings.TrimPrefix(f.Path, path)
+		if err := tx.Save(f); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s fileidBackend) Delete(path string) error {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := deleteFiles(tx, path); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
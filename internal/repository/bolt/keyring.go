@@ -0,0 +1,20 @@
+package bolt
+
+import (
+	"github.com/asdine/storm/v3"
+
+	"github.com/nulnl/nulyun/internal/model/keyring"
+)
+
+type keyringBackend struct {
+	db *storm.DB
+}
+
+func (s keyringBackend) Get() (*keyring.Keyring, error) {
+	ring := &keyring.Keyring{}
+	return ring, get(s.db, "keyring", ring)
+}
+
+func (s keyringBackend) Save(ring *keyring.Keyring) error {
+	return save(s.db, "keyring", ring)
+}
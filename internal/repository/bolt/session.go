@@ -0,0 +1,46 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+
+	"github.com/nulnl/nulyun/internal/model/session"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+type sessionBackend struct {
+	db *storm.DB
+}
+
+func (s sessionBackend) Get(id string) (*session.Session, error) {
+	var v session.Session
+	err := s.db.One("ID", id, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s sessionBackend) FindByUserID(id uint) ([]*session.Session, error) {
+	var v []*session.Session
+	err := s.db.Find("UserID", id, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, fberrors.ErrNotExist
+	}
+
+	return v, err
+}
+
+func (s sessionBackend) Save(sess *session.Session) error {
+	return s.db.Save(sess)
+}
+
+func (s sessionBackend) Delete(id string) error {
+	err := s.db.DeleteStruct(&session.Session{ID: id})
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	return err
+}
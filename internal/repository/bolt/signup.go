@@ -0,0 +1,69 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+
+	"github.com/nulnl/nulyun/internal/model/signup"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+type signupBackend struct {
+	db *storm.DB
+}
+
+func (s signupBackend) GetInvite(code string) (*signup.Invite, error) {
+	var v signup.Invite
+	err := s.db.One("Code", code, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s signupBackend) Invites() ([]*signup.Invite, error) {
+	var v []*signup.Invite
+	err := s.db.All(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, nil
+	}
+
+	return v, err
+}
+
+func (s signupBackend) SaveInvite(i *signup.Invite) error {
+	return s.db.Save(i)
+}
+
+func (s signupBackend) DeleteInvite(code string) error {
+	err := s.db.DeleteStruct(&signup.Invite{Code: code})
+	if errors.Is(err, storm.ErrNotFound) {
+		return fberrors.ErrNotExist
+	}
+	return err
+}
+
+func (s signupBackend) GetVerification(token string) (*signup.Verification, error) {
+	var v signup.Verification
+	err := s.db.One("Token", token, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s signupBackend) SaveVerification(v *signup.Verification) error {
+	return s.db.Save(v)
+}
+
+func (s signupBackend) DeleteVerifications(userID uint) error {
+	err := s.db.Select(q.Eq("UserID", userID)).Delete(&signup.Verification{})
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	return err
+}
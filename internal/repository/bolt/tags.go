@@ -0,0 +1,46 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+
+	"github.com/nulnl/nulyun/internal/model/tags"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+type tagsBackend struct {
+	db *storm.DB
+}
+
+func (s tagsBackend) Get(userID uint, fileID uint64) (*tags.Labels, error) {
+	var v tags.Labels
+	err := s.db.One("ID", tags.LabelsID(userID, fileID), &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s tagsBackend) FindByUserID(id uint) ([]*tags.Labels, error) {
+	v := []*tags.Labels{}
+	err := s.db.Find("UserID", id, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return []*tags.Labels{}, nil
+	}
+
+	return v, err
+}
+
+func (s tagsBackend) Save(l *tags.Labels) error {
+	return s.db.Save(l)
+}
+
+func (s tagsBackend) Delete(userID uint, fileID uint64) error {
+	err := s.db.DeleteStruct(&tags.Labels{ID: tags.LabelsID(userID, fileID)})
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package bolt
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/asdine/storm/v3"
+
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/users"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+// RotateTOTPKey re-encrypts the TOTP secret of every user with newKey and
+// saves it as the TOTP encryption key, all in a single transaction. When
+// dryRun is set, the transaction is rolled back once every secret has been
+// re-encrypted. It returns the number of re-encrypted secrets.
+func RotateTOTPKey(db *storm.DB, newKey []byte, dryRun bool) (int, error) {
+	if len(newKey) != 32 {
+		return 0, fberrors.ErrInvalidEncryptionKey
+	}
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	set := &settings.Settings{}
+	if err := tx.Get("config", "settings", set); err != nil {
+		return 0, err
+	}
+	oldKey := set.TOTPEncryptionKey
+
+	var all []*users.User
+	if err := tx.All(&all); err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return 0, err
+	}
+
+	count := 0
+	for _, u := range all {
+		if u.TOTPSecret == "" {
+			continue
+		}
+
+		u.TOTPSecret, u.TOTPNonce, err = users.ReencryptSymmetric(oldKey, newKey, u.TOTPSecret, u.TOTPNonce)
+		if err != nil {
+			return 0, fmt.Errorf("user %q: %w", u.Username, err)
+		}
+
+		if err := tx.Save(u); err != nil {
+			return 0, err
+		}
+		count++
+	}
+
+	set.TOTPEncryptionKey = newKey
+	if err := tx.Set("config", "settings", set); err != nil {
+		return 0, err
+	}
+
+	if dryRun {
+		return count, nil
+	}
+
+	return count, tx.Commit()
+}
@@ -0,0 +1,165 @@
+package bolt
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3"
+	"github.com/pquerna/otp/totp"
+	bbolt "go.etcd.io/bbolt"
+
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/users"
+)
+
+var (
+	oldTOTPKey = bytes.Repeat([]byte("o"), 32)
+	newTOTPKey = bytes.Repeat([]byte("n"), 32)
+)
+
+// newTOTPDB opens a database whose settings hold key and with a user per
+// secret, each encrypted with the key of the same index.
+func newTOTPDB(t *testing.T, key []byte, secretKeys ...[]byte) (*storm.DB, []string) {
+	t.Helper()
+	db, err := storm.Open(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Set("config", "settings", &settings.Settings{Key: []byte("key"), TOTPEncryptionKey: key}); err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := make([]string, len(secretKeys))
+	for i, k := range secretKeys {
+		otpKey, err := totp.Generate(totp.GenerateOpts{Issuer: "nulyun", AccountName: "user"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		secrets[i] = otpKey.Secret()
+
+		u := &users.User{Username: "user" + string(rune('a'+i)), TOTPEnabled: true}
+		if u.TOTPSecret, u.TOTPNonce, err = users.EncryptSymmetric(k, []byte(secrets[i])); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Save(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A user without TOTP is left alone.
+	if err := db.Save(&users.User{Username: "plain"}); err != nil {
+		t.Fatal(err)
+	}
+	return db, secrets
+}
+
+// dumpDB returns every key and value of the database, nested buckets
+// included.
+func dumpDB(t *testing.T, db *storm.DB) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var walk func(b *bbolt.Bucket)
+	walk = func(b *bbolt.Bucket) {
+		_ = b.ForEach(func(k, v []byte) error {
+			buf.Write(k)
+			buf.WriteByte('=')
+			if v == nil {
+				buf.WriteByte('{')
+				walk(b.Bucket(k))
+				buf.WriteByte('}')
+			} else {
+				buf.Write(v)
+			}
+			buf.WriteByte('\n')
+			return nil
+		})
+	}
+	err := db.Bolt.View(func(tx *bbolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bbolt.Bucket) error {
+			buf.Write(name)
+			buf.WriteByte(':')
+			walk(b)
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRotateTOTPKey(t *testing.T) {
+	db, secrets := newTOTPDB(t, oldTOTPKey, oldTOTPKey, oldTOTPKey)
+
+	count, err := RotateTOTPKey(db, newTOTPKey, false)
+	if err != nil || count != 2 {
+		t.Fatalf("RotateTOTPKey() = %d, %v, want 2 secrets", count, err)
+	}
+
+	set := &settings.Settings{}
+	if err := db.Get("config", "settings", set); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(set.TOTPEncryptionKey, newTOTPKey) {
+		t.Errorf("saved key = %q, want the new key", set.TOTPEncryptionKey)
+	}
+
+	for i, secret := range secrets {
+		u := &users.User{}
+		if err := db.One("Username", "user"+string(rune('a'+i)), u); err != nil {
+			t.Fatal(err)
+		}
+		code, err := totp.GenerateCode(secret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := users.CheckTOTP(newTOTPKey, u.TOTPSecret, u.TOTPNonce, code); !ok || err != nil {
+			t.Errorf("%s: code checked with the new key = %v, %v", u.Username, ok, err)
+		}
+		if _, err := users.CheckTOTP(oldTOTPKey, u.TOTPSecret, u.TOTPNonce, code); err == nil {
+			t.Errorf("%s: secret still decrypts with the old key", u.Username)
+		}
+	}
+}
+
+func TestRotateTOTPKeyDryRun(t *testing.T) {
+	db, _ := newTOTPDB(t, oldTOTPKey, oldTOTPKey, oldTOTPKey)
+	before := dumpDB(t, db)
+
+	count, err := RotateTOTPKey(db, newTOTPKey, true)
+	if err != nil || count != 2 {
+		t.Fatalf("RotateTOTPKey() = %d, %v, want 2 secrets", count, err)
+	}
+	if after := dumpDB(t, db); !bytes.Equal(before, after) {
+		t.Errorf("dry run changed the database")
+	}
+}
+
+func TestRotateTOTPKeyRollback(t *testing.T) {
+	wrongKey := bytes.Repeat([]byte("w"), 32)
+	tests := map[string]struct {
+		key        []byte
+		secretKeys [][]byte
+	}{
+		"wrong old key": {wrongKey, [][]byte{oldTOTPKey, oldTOTPKey}},
+		// The first secret is re-encrypted before the second one fails.
+		"failure partway": {oldTOTPKey, [][]byte{oldTOTPKey, wrongKey}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			db, _ := newTOTPDB(t, tt.key, tt.secretKeys...)
+			before := dumpDB(t, db)
+
+			if count, err := RotateTOTPKey(db, newTOTPKey, false); err == nil {
+				t.Fatalf("RotateTOTPKey() = %d, want an error", count)
+			}
+			if after := dumpDB(t, db); !bytes.Equal(before, after) {
+				t.Errorf("failed rotation changed the database")
+			}
+		})
+	}
+}
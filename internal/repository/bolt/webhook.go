@@ -0,0 +1,107 @@
+package bolt
+
+import (
+	"errors"
+
+	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/q"
+
+	"github.com/nulnl/nulyun/internal/model/webhook"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+type webhookBackend struct {
+	db *storm.DB
+}
+
+func (s webhookBackend) Get(id uint) (*webhook.Webhook, error) {
+	var v webhook.Webhook
+	err := s.db.One("ID", id, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return nil, fberrors.ErrNotExist
+	}
+
+	return &v, err
+}
+
+func (s webhookBackend) All() ([]*webhook.Webhook, error) {
+	v := []*webhook.Webhook{}
+	err := s.db.All(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return v, nil
+	}
+
+	return v, err
+}
+
+func (s webhookBackend) FindByUserID(id uint) ([]*webhook.Webhook, error) {
+	v := []*webhook.Webhook{}
+	err := s.db.Find("UserID", id, &v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return []*webhook.Webhook{}, nil
+	}
+
+	return v, err
+}
+
+func (s webhookBackend) Save(w *webhook.Webhook) error {
+	return s.db.Save(w)
+}
+
+func (s webhookBackend) Delete(id uint) error {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.Select(q.Eq("WebhookID", id)).Delete(&webhook.Delivery{})
+	if err != nil && !errors.Is(err, storm.ErrNotFound) {
+		return err
+	}
+
+	err = tx.DeleteStruct(&webhook.Webhook{ID: id})
+	if errors.Is(err, storm.ErrNotFound) {
+		return fberrors.ErrNotExist
+	} else if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s webhookBackend) SaveDelivery(d *webhook.Delivery, keep int) error {
+	tx, err := s.db.Begin(true)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := tx.Save(d); err != nil {
+		return err
+	}
+
+	// The deliveries are stored in increasing order, the oldest first.
+	n, err := tx.Select(q.Eq("WebhookID", d.WebhookID)).Count(&webhook.Delivery{})
+	if err != nil {
+		return err
+	}
+	if n > keep {
+		err := tx.Select(q.Eq("WebhookID", d.WebhookID)).Limit(n - keep).Delete(&webhook.Delivery{})
+		if err != nil && !errors.Is(err, storm.ErrNotFound) {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s webhookBackend) Deliveries(id uint) ([]*webhook.Delivery, error) {
+	v := []*webhook.Delivery{}
+	err := s.db.Select(q.Eq("WebhookID", id)).Reverse().Find(&v)
+	if errors.Is(err, storm.ErrNotFound) {
+		return []*webhook.Delivery{}, nil
+	}
+
+	return v, err
+}
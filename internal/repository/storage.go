@@ -2,12 +2,47 @@ package storage
 
 import (
 	"github.com/nulnl/nulyun/internal/auth"
+	"github.com/nulnl/nulyun/internal/model/activity"
+	"github.com/nulnl/nulyun/internal/model/album"
+	"github.com/nulnl/nulyun/internal/model/audit"
+	"github.com/nulnl/nulyun/internal/model/comment"
+	"github.com/nulnl/nulyun/internal/model/fileid"
 	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/keyring"
+	"github.com/nulnl/nulyun/internal/model/session"
 	"github.com/nulnl/nulyun/internal/model/share"
+	"github.com/nulnl/nulyun/internal/model/signup"
+	"github.com/nulnl/nulyun/internal/model/tags"
 	"github.com/nulnl/nulyun/internal/model/users"
 	"github.com/nulnl/nulyun/internal/model/webdav"
+	"github.com/nulnl/nulyun/internal/model/webhook"
 )
 
+// ShareTransfer describes the new owner of the shares of a deleted user.
+// PathPrefix is prepended to the share paths, relative to the new owner's
+// scope.
+type ShareTransfer struct {
+	UserID     uint
+	PathPrefix string
+}
+
+// UserRecords counts the records removed or transferred with a user.
+type UserRecords struct {
+	Shares       int `json:"shares"`
+	WebDAVTokens int `json:"webdavTokens"`
+	Sessions     int `json:"sessions"`
+	Webhooks     int `json:"webhooks"`
+	Albums       int `json:"albums"`
+}
+
+// UserDeleter deletes a user together with the records depending on it.
+type UserDeleter interface {
+	// DeleteUser deletes a user, its WebDAV tokens, sessions, webhooks, tags,
+	// activity and pending verifications in a single operation. Its shares and albums are
+	// transferred when transfer is set and deleted otherwise.
+	DeleteUser(id uint, transfer *ShareTransfer) (*UserRecords, error)
+}
+
 // Storage is a storage powered by a Backend which makes the necessary
 // verifications when fetching and saving data to ensure consistency.
 type Storage struct {
@@ -16,4 +51,16 @@ type Storage struct {
 	Auth     *auth.Storage
 	Settings *settings.Storage
 	WebDAV   *webdav.Storage
+	Sessions *session.Storage
+	Keyring  *keyring.Storage
+	Signup   *signup.Storage
+	Audit    *audit.Storage
+	Webhooks *webhook.Storage
+	Albums   *album.Storage
+	Files    *fileid.Storage
+	Tags     *tags.Storage
+	Comments *comment.Storage
+	Activity *activity.Storage
+
+	UserDeleter UserDeleter
 }
@@ -0,0 +1,198 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+const (
+	// DefaultHookTimeout stops the hooks setting no timeout.
+	DefaultHookTimeout = 30 * time.Second
+	// maxHookMessage is the length of the hook output kept as the message
+	// of a veto.
+	maxHookMessage = 512
+)
+
+// Operation is a file operation hooks run on. Paths are relative to the
+// scope of the user, Scope is its directory on the disk.
+type Operation struct {
+	Event       string `json:"event"`
+	UserID      uint   `json:"userID"`
+	Username    string `json:"username"`
+	Scope       string `json:"scope"`
+	Path        string `json:"path"`
+	Destination string `json:"destination,omitempty"`
+}
+
+// file returns the path of name on the disk.
+func (op *Operation) file(name string) string {
+	if name == "" {
+		return ""
+	}
+	return filepath.Join(op.Scope, filepath.FromSlash(name))
+}
+
+// env returns the variables describing the operation to a command.
+func (op *Operation) env() map[string]string {
+	return map[string]string{
+		"TRIGGER":     op.Event,
+		"USERNAME":    op.Username,
+		"USER_ID":     strconv.FormatUint(uint64(op.UserID), 10),
+		"SCOPE":       op.Scope,
+		"FILE":        op.file(op.Path),
+		"DESTINATION": op.file(op.Destination),
+	}
+}
+
+// RunBefore runs the hooks of the event of op, which must be a "before"
+// one, in order. The first hook failing vetoes the operation: the returned
+// error wraps fberrors.ErrHookVetoed with the message of the hook.
+func RunBefore(ctx context.Context, set *settings.Settings, op *Operation) error {
+	for _, h := range set.Hooks {
+		if h.Event != op.Event {
+			continue
+		}
+
+		msg, err := runHook(ctx, set.Shell, &h, op)
+		if err != nil {
+			log.Printf("hooks: %s vetoed by %s: %v", op.Event, hookName(&h), err)
+			if msg == "" {
+				msg = "hook failed"
+			}
+			return fmt.Errorf("%w: %s", fberrors.ErrHookVetoed, msg)
+		}
+	}
+
+	return nil
+}
+
+// RunAfter starts the hooks of the event of op, which must be an "after"
+// one, in the background.
+func RunAfter(set *settings.Settings, op *Operation) {
+	var hooks []settings.Hook
+	for _, h := range set.Hooks {
+		if h.Event == op.Event {
+			hooks = append(hooks, h)
+		}
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	go func() {
+		for _, h := range hooks {
+			if _, err := runHook(context.Background(), set.Shell, &h, op); err != nil {
+				log.Printf("hooks: %s failed for %s: %v", hookName(&h), op.Event, err)
+			}
+		}
+	}()
+}
+
+func hookName(h *settings.Hook) string {
+	if h.URL != "" {
+		return h.URL
+	}
+	return strings.SplitN(h.Command, " ", 2)[0]
+}
+
+// runHook runs a hook and returns its message: the output of a command or
+// the response of an endpoint.
+func runHook(ctx context.Context, shell []string, h *settings.Hook, op *Operation) (string, error) {
+	timeout := DefaultHookTimeout
+	if h.Timeout != 0 {
+		timeout = time.Duration(h.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if h.URL != "" {
+		return postHook(ctx, h.URL, op)
+	}
+	return execHook(ctx, shell, h.Command, op)
+}
+
+// execHook runs a hook command with the operation in its environment. The
+// variables are also expanded in the arguments when no shell is set.
+func execHook(ctx context.Context, shell []string, command string, op *Operation) (string, error) {
+	env := op.env()
+
+	var args []string
+	if len(shell) > 0 && shell[0] != "" {
+		args = append(append(args, shell...), command)
+	} else {
+		words, err := Split(command)
+		if err != nil {
+			return "", err
+		}
+		if len(words) == 0 {
+			return "", ErrEmptyCommand
+		}
+		for _, w := range words {
+			args = append(args, os.Expand(w, func(key string) string {
+				if v, ok := env[key]; ok {
+					return v
+				}
+				return os.Getenv(key)
+			}))
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.WaitDelay = time.Second
+
+	out, err := cmd.CombinedOutput()
+	return hookMessage(out), err
+}
+
+// postHook sends the operation to a hook endpoint, which must answer with
+// a 2xx status.
+func postHook(ctx context.Context, url string, op *Operation) (string, error) {
+	payload, err := json.Marshal(op)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Nulyun-Hook")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxHookMessage))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return hookMessage(body), fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return hookMessage(body), nil
+}
+
+func hookMessage(out []byte) string {
+	if len(out) > maxHookMessage {
+		out = out[:maxHookMessage]
+	}
+	return strings.TrimSpace(string(out))
+}
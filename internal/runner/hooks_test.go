@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	fberrors "github.com/nulnl/nulyun/internal/pkg_errors"
+)
+
+func TestRunBefore(t *testing.T) {
+	var received Operation
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		if received.Path == "/virus.exe" {
+			http.Error(w, "infected file", http.StatusUnprocessableEntity)
+		}
+	}))
+	defer srv.Close()
+
+	op := &Operation{Event: settings.BeforeUpload, Username: "bob", Scope: "/srv/bob", Path: "/a.txt"}
+	testCases := map[string]struct {
+		hook settings.Hook
+		path string
+		veto string
+	}{
+		"command passes":       {settings.Hook{Event: settings.BeforeUpload, Command: "true"}, "/a.txt", ""},
+		"command vetoes":       {settings.Hook{Event: settings.BeforeUpload, Command: `sh -c "echo no $FILE; exit 1"`}, "/a.txt", "no " + filepath.FromSlash("/srv/bob/a.txt")},
+		"missing command":      {settings.Hook{Event: settings.BeforeUpload, Command: "nulyun-missing-hook"}, "/a.txt", "hook failed"},
+		"endpoint passes":      {settings.Hook{Event: settings.BeforeUpload, URL: srv.URL}, "/a.txt", ""},
+		"endpoint vetoes":      {settings.Hook{Event: settings.BeforeUpload, URL: srv.URL}, "/virus.exe", "infected file"},
+		"other events skipped": {settings.Hook{Event: settings.BeforeDelete, Command: "false"}, "/a.txt", ""},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			op := *op
+			op.Path = tc.path
+			set := &settings.Settings{Hooks: []settings.Hook{tc.hook}}
+
+			err := RunBefore(context.Background(), set, &op)
+			switch {
+			case tc.veto == "" && err != nil:
+				t.Fatalf("RunBefore() error = %v, want none", err)
+			case tc.veto != "" && !errors.Is(err, fberrors.ErrHookVetoed):
+				t.Fatalf("RunBefore() error = %v, want a veto", err)
+			case tc.veto != "" && err.Error() != fberrors.ErrHookVetoed.Error()+": "+tc.veto:
+				t.Errorf("RunBefore() error = %q, want message %q", err, tc.veto)
+			}
+		})
+	}
+
+	if received.Username != "bob" || received.Event != settings.BeforeUpload {
+		t.Errorf("endpoint received %+v", received)
+	}
+}
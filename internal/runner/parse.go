@@ -0,0 +1,89 @@
+// Package runner parses the command lines run by the users.
+package runner
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrEmptyCommand      = errors.New("empty command")
+	ErrUnterminatedQuote = errors.New("unterminated quote")
+	ErrShellOperator     = errors.New("shell operators are not allowed")
+)
+
+// shellOperators are the characters letting a shell run other commands
+// than the first one, or redirect their output.
+const shellOperators = ";&|`$<>(){}\n\r"
+
+// Split splits a command line into words. Single and double quotes group
+// words and a backslash escapes the next character, except within single
+// quotes.
+func Split(raw string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, c := range raw {
+		switch {
+		case escaped:
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(c)
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, ErrUnterminatedQuote
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+// Parse returns the name of the command of raw, to be checked against the
+// allowed commands, and the arguments to run it with: the words of raw, or
+// the shell followed by raw when one is set. Shell operators are refused,
+// so the shell can't run another command than the checked one.
+func Parse(shell []string, raw string) (string, []string, error) {
+	words, err := Split(raw)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(words) == 0 {
+		return "", nil, ErrEmptyCommand
+	}
+
+	if len(shell) == 0 || shell[0] == "" {
+		return words[0], words, nil
+	}
+
+	if strings.ContainsAny(raw, shellOperators) {
+		return "", nil, ErrShellOperator
+	}
+
+	args := append(append([]string{}, shell...), raw)
+	return words[0], args, nil
+}
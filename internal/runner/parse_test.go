@@ -0,0 +1,68 @@
+package runner
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	testCases := map[string]struct {
+		raw   string
+		words []string
+		err   error
+	}{
+		"words":             {`ls -la  docs`, []string{"ls", "-la", "docs"}, nil},
+		"double quotes":     {`grep "two words" a.txt`, []string{"grep", "two words", "a.txt"}, nil},
+		"single quotes":     {`echo 'a \ b'`, []string{"echo", `a \ b`}, nil},
+		"escaped space":     {`cat my\ file`, []string{"cat", "my file"}, nil},
+		"escaped quote":     {`echo "say \"hi\""`, []string{"echo", `say "hi"`}, nil},
+		"empty quotes":      {`touch ""`, []string{"touch", ""}, nil},
+		"joined quotes":     {`echo a"b c"d`, []string{"echo", "ab cd"}, nil},
+		"unterminated":      {`echo "a`, nil, ErrUnterminatedQuote},
+		"trailing escape":   {`echo a\`, nil, ErrUnterminatedQuote},
+		"only white spaces": {" \t ", nil, nil},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			words, err := Split(tc.raw)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Split() error = %v, want %v", err, tc.err)
+			}
+			if !slices.Equal(words, tc.words) {
+				t.Errorf("Split() = %q, want %q", words, tc.words)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	testCases := map[string]struct {
+		shell []string
+		raw   string
+		name  string
+		args  []string
+		err   error
+	}{
+		"no shell":              {nil, `git log -n 1`, "git", []string{"git", "log", "-n", "1"}, nil},
+		"operators are literal": {nil, `echo a; rm b`, "echo", []string{"echo", "a;", "rm", "b"}, nil},
+		"shell":                 {[]string{"sh", "-c"}, `ls *.txt`, "ls", []string{"sh", "-c", "ls *.txt"}, nil},
+		"shell operator":        {[]string{"sh", "-c"}, `ls; rm -rf .`, "", nil, ErrShellOperator},
+		"shell substitution":    {[]string{"sh", "-c"}, `ls $(rm a)`, "", nil, ErrShellOperator},
+		"shell redirection":     {[]string{"sh", "-c"}, `ls > a`, "", nil, ErrShellOperator},
+		"empty":                 {nil, ``, "", nil, ErrEmptyCommand},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cmd, args, err := Parse(tc.shell, tc.raw)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("Parse() error = %v, want %v", err, tc.err)
+			}
+			if cmd != tc.name || !slices.Equal(args, tc.args) {
+				t.Errorf("Parse() = %q %q, want %q %q", cmd, args, tc.name, tc.args)
+			}
+		})
+	}
+}
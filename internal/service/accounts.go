@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nulnl/nulyun/internal/files"
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/users"
+	storage "github.com/nulnl/nulyun/internal/repository"
+)
+
+// FileName returns a name for the files of a user, such as its archived
+// home, made from its username without path separators.
+func FileName(u *users.User) string {
+	name := strings.Trim(strings.NewReplacer("/", "-", "\\", "-").Replace(u.Username), ".")
+	if name == "" {
+		name = "user-" + strconv.FormatUint(uint64(u.ID), 10)
+	}
+	return name
+}
+
+// ArchiveHome writes a zip archive of the home directory of a user into
+// dir and returns its path. Users whose scope is the server root are
+// refused, as their home is shared with everyone else.
+func ArchiveHome(ctx context.Context, server *settings.Server, u *users.User, dir string) (string, error) {
+	home := filepath.Join(server.Root, filepath.Join("/", u.Scope))
+	if home == filepath.Clean(server.Root) {
+		return "", fmt.Errorf("user %q: home is the server root", u.Username)
+	}
+
+	name := fmt.Sprintf("%s-%d-%s.zip", FileName(u), u.ID, time.Now().Format("20060102-150405"))
+	dst := filepath.Join(dir, name)
+	if filepath.Dir(dst) != filepath.Clean(dir) {
+		return "", fmt.Errorf("user %q: invalid archive name %q", u.Username, name)
+	}
+
+	return dst, files.ArchiveDir(ctx, home, dst)
+}
+
+// ExpireAccounts disables the accounts past their expiry date and revokes
+// their sessions. When archiveDir is set, the home directory of each of
+// these users is also archived into it, unless it can't be, such as the
+// server root, which is only logged. It returns the number of disabled
+// accounts.
+func ExpireAccounts(ctx context.Context, st *storage.Storage, server *settings.Server, archiveDir string) (int, error) {
+	all, err := st.Users.Gets(server.Root)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, u := range all {
+		if u.Disabled || !u.Expired() {
+			continue
+		}
+
+		if archiveDir != "" {
+			if dst, err := ArchiveHome(ctx, server, u, archiveDir); err != nil {
+				log.Printf("failed to archive the home of %q: %v", u.Username, err)
+			} else {
+				log.Printf("Archived the home of %q to %s", u.Username, dst)
+			}
+		}
+
+		u.Disabled = true
+		if err := st.Users.Update(u, "Disabled"); err != nil {
+			return count, err
+		}
+		count++
+		log.Printf("Disabled expired account %q", u.Username)
+
+		if _, err := st.Sessions.RevokeAll(u.ID); err != nil {
+			log.Printf("failed to revoke the sessions of %q: %v", u.Username, err)
+		}
+	}
+
+	return count, nil
+}
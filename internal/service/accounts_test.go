@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/v3"
+
+	settings "github.com/nulnl/nulyun/internal/model/global"
+	"github.com/nulnl/nulyun/internal/model/session"
+	"github.com/nulnl/nulyun/internal/model/users"
+	storage "github.com/nulnl/nulyun/internal/repository"
+	"github.com/nulnl/nulyun/internal/repository/bolt"
+)
+
+func newStorage(t *testing.T) *storage.Storage {
+	t.Helper()
+	db, err := storm.Open(filepath.Join(t.TempDir(), "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	st, err := bolt.NewStorage(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func TestExpireAccounts(t *testing.T) {
+	st := newStorage(t)
+	server := &settings.Server{Root: t.TempDir()}
+	archiveDir := t.TempDir()
+	past, future := time.Now().Add(-time.Hour).Unix(), time.Now().Add(time.Hour).Unix()
+
+	for _, u := range []*users.User{
+		{Username: "expired", Scope: "/users/expired", ExpiresAt: past},
+		{Username: "active", Scope: "/users/active", ExpiresAt: future},
+		// The home of a user scoped to the root can't be archived.
+		{Username: "unarchived", Scope: ".", ExpiresAt: past},
+	} {
+		u.Password = "pw"
+		if err := st.Users.Save(u); err != nil {
+			t.Fatal(err)
+		}
+		home := filepath.Join(server.Root, u.Scope)
+		if err := os.MkdirAll(home, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(home, "a.txt"), []byte(u.Username), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := st.Sessions.Save(&session.Session{ID: u.Username, UserID: u.ID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	count, err := ExpireAccounts(context.Background(), st, server, archiveDir)
+	if err != nil || count != 2 {
+		t.Fatalf("ExpireAccounts() = %d, %v, want 2 accounts", count, err)
+	}
+
+	for name, disabled := range map[string]bool{"expired": true, "active": false, "unarchived": true} {
+		u, err := st.Users.Get(server.Root, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Disabled != disabled {
+			t.Errorf("%s: disabled = %v, want %v", name, u.Disabled, disabled)
+		}
+		sess, err := st.Sessions.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sess.Revoked != disabled {
+			t.Errorf("%s: session revoked = %v, want %v", name, sess.Revoked, disabled)
+		}
+	}
+
+	entries, err := os.ReadDir(archiveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !strings.HasPrefix(entries[0].Name(), "expired-") || filepath.Ext(entries[0].Name()) != ".zip" {
+		t.Errorf("archives = %v, want only the home of expired", entries)
+	}
+	if _, err := os.Stat(filepath.Join(server.Root, "users", "expired", "a.txt")); err != nil {
+		t.Errorf("archived home not kept in place: %v", err)
+	}
+}
+
+func TestArchiveHomeName(t *testing.T) {
+	server := &settings.Server{Root: t.TempDir()}
+	if err := os.MkdirAll(filepath.Join(server.Root, "users", "x"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "archives", "expired")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"../../x": "-..-x-3-",
+		"..":      "user-3-3-",
+		`..\x`:    "-x-3-",
+	}
+	for username, prefix := range tests {
+		u := &users.User{ID: 3, Username: username, Scope: "/users/x"}
+		dst, err := ArchiveHome(context.Background(), server, u, dir)
+		if err != nil {
+			t.Fatalf("%q: %v", username, err)
+		}
+		if filepath.Dir(dst) != dir || !strings.HasPrefix(filepath.Base(dst), prefix) {
+			t.Errorf("%q: archive = %s, want %s in %s", username, dst, prefix+"*", dir)
+		}
+		if _, err := os.Stat(dst); err != nil {
+			t.Errorf("%q: %v", username, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/jellydator/ttlcache/v3"
+
+	"github.com/nulnl/nulyun/internal/clamd"
+	"github.com/nulnl/nulyun/internal/mail"
+	"github.com/nulnl/nulyun/internal/model/users"
+	storage "github.com/nulnl/nulyun/internal/repository"
+)
+
+const (
+	// verdictTTL is how long the verdict on a content is trusted, so
+	// new signatures eventually apply to it.
+	verdictTTL = 24 * time.Hour
+	// maxVerdicts bounds the number of cached verdicts.
+	maxVerdicts = 10000
+)
+
+// Infection is an infected upload moved to quarantine.
+type Infection struct {
+	Time     int64  `json:"time"`
+	UserID   uint   `json:"userID"`
+	Username string `json:"username"`
+	// Path is the path of the file, relative to the scope of the user.
+	Path      string `json:"path"`
+	Signature string `json:"signature"`
+	Checksum  string `json:"checksum"`
+	// Quarantined is the name of the file in the quarantine directory.
+	Quarantined string `json:"quarantined"`
+}
+
+// Antivirus scans the uploads with a clamd daemon and moves the infected
+// ones to a quarantine directory. The verdicts are cached by checksum, so
+// the same content is only scanned once.
+type Antivirus struct {
+	client     *clamd.Client
+	quarantine string
+	verdicts   *ttlcache.Cache[string, clamd.Result]
+}
+
+// NewAntivirus creates an antivirus quarantining the infected files into
+// dir.
+func NewAntivirus(client *clamd.Client, dir string) *Antivirus {
+	return &Antivirus{
+		client:     client,
+		quarantine: dir,
+		verdicts: ttlcache.New(
+			ttlcache.WithTTL[string, clamd.Result](verdictTTL),
+			ttlcache.WithCapacity[string, clamd.Result](maxVerdicts),
+			ttlcache.WithDisableTouchOnHit[string, clamd.Result](),
+		),
+	}
+}
+
+// Scan returns the verdict on the file at path, on disk, and its checksum.
+func (a *Antivirus) Scan(ctx context.Context, path string) (*clamd.Result, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, "", err
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	if item := a.verdicts.Get(checksum); item != nil {
+		res := item.Value()
+		return &res, checksum, nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, "", err
+	}
+	res, err := a.client.Scan(ctx, f)
+	if err != nil {
+		return nil, "", err
+	}
+
+	a.verdicts.Set(checksum, *res, ttlcache.DefaultTTL)
+	return res, checksum, nil
+}
+
+// ScanUpload scans a file uploaded by u into its scope under root. An
+// infected file is quarantined and returned, nil is returned for a clean
+// one.
+func (a *Antivirus) ScanUpload(ctx context.Context, root string, u *users.User, name string) (*Infection, error) {
+	path := filepath.Join(root, filepath.Join("/", u.Scope), filepath.Join("/", name))
+	res, checksum, err := a.Scan(ctx, path)
+	if err != nil || !res.Infected {
+		return nil, err
+	}
+
+	inf := &Infection{
+		Time:      time.Now().Unix(),
+		UserID:    u.ID,
+		Username:  u.Username,
+		Path:      name,
+		Signature: res.Signature,
+		Checksum:  checksum,
+	}
+	return inf, a.isolate(path, inf)
+}
+
+// isolate moves an infected file into the quarantine directory, next to a
+// record of the infection. The file is renamed after its checksum, so
+// nothing of its name is trusted.
+func (a *Antivirus) isolate(path string, inf *Infection) error {
+	if err := os.MkdirAll(a.quarantine, 0o700); err != nil {
+		return err
+	}
+
+	inf.Quarantined = fmt.Sprintf("%d-%s", time.Now().UnixNano(), inf.Checksum[:16])
+	dst := filepath.Join(a.quarantine, inf.Quarantined)
+	if err := moveFile(path, dst); err != nil {
+		return err
+	}
+	if err := os.Chmod(dst, 0o600); err != nil {
+		return err
+	}
+
+	record, err := json.MarshalIndent(inf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst+".json", record, 0o600)
+}
+
+// moveFile renames src to dst, copying it when they are on different
+// devices.
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
+
+// NotifyInfection emails the uploader and the admins about a quarantined
+// file. Users without an email address are skipped.
+func NotifyInfection(ctx context.Context, st *storage.Storage, root string, mailer mail.Mailer, inf *Infection) error {
+	all, err := st.Users.Gets(root)
+	if err != nil {
+		return err
+	}
+
+	var admins []string
+	for _, u := range all {
+		if u.Email == "" {
+			continue
+		}
+		switch {
+		case u.ID == inf.UserID:
+			err = errors.Join(err, mailer.Send(ctx, &mail.Message{
+				To:      []string{u.Email},
+				Subject: "A file you uploaded was quarantined",
+				Body: fmt.Sprintf("The file %s you uploaded contains %s.\n\n"+
+					"It was moved to quarantine and is no longer available. "+
+					"Contact an administrator if you think this is a mistake.\n",
+					inf.Path, inf.Signature),
+			}))
+		case u.Perm.Admin && !u.Disabled:
+			admins = append(admins, u.Email)
+		}
+	}
+
+	if len(admins) > 0 {
+		err = errors.Join(err, mailer.Send(ctx, &mail.Message{
+			To:      admins,
+			Subject: "Infected file quarantined",
+			Body: fmt.Sprintf("The file %s uploaded by %s contains %s.\n\n"+
+				"It was moved to quarantine as %s (SHA-256 %s).\n",
+				inf.Path, inf.Username, inf.Signature, inf.Quarantined, inf.Checksum),
+		}))
+	}
+
+	return err
+}
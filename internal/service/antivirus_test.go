@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nulnl/nulyun/internal/clamd"
+	"github.com/nulnl/nulyun/internal/clamd/clamdtest"
+	"github.com/nulnl/nulyun/internal/model/users"
+)
+
+func TestAntivirusScanUpload(t *testing.T) {
+	srv := clamdtest.NewServer()
+	defer srv.Close()
+
+	client, err := clamd.New(srv.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	quarantine := filepath.Join(t.TempDir(), "quarantine")
+	av := NewAntivirus(client, quarantine)
+	u := &users.User{ID: 2, Username: "bob", Scope: "/users/bob"}
+
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(root, "users", "bob", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	clean := write("a.txt", "hello")
+	for range 2 {
+		inf, err := av.ScanUpload(context.Background(), root, u, "/a.txt")
+		if err != nil || inf != nil {
+			t.Fatalf("ScanUpload() = %+v, %v, want a clean file", inf, err)
+		}
+	}
+	if _, err := os.Stat(clean); err != nil {
+		t.Errorf("clean file removed: %v", err)
+	}
+	if n := srv.Scans(); n != 1 {
+		t.Errorf("scans = %d, want the verdict cached after 1", n)
+	}
+
+	infected := write("docs/eicar.com", clamdtest.EICAR)
+	inf, err := av.ScanUpload(context.Background(), root, u, "/docs/eicar.com")
+	if err != nil {
+		t.Fatalf("ScanUpload() error = %v", err)
+	}
+	if inf == nil || inf.Signature != clamdtest.Signature || inf.Path != "/docs/eicar.com" {
+		t.Fatalf("ScanUpload() = %+v, want the infection", inf)
+	}
+	if _, err := os.Stat(infected); !os.IsNotExist(err) {
+		t.Errorf("infected file still in place: %v", err)
+	}
+
+	record, err := os.ReadFile(filepath.Join(quarantine, inf.Quarantined+".json"))
+	if err != nil {
+		t.Fatalf("quarantine record: %v", err)
+	}
+	var saved Infection
+	if err := json.Unmarshal(record, &saved); err != nil || saved != *inf {
+		t.Errorf("quarantine record = %+v, %v, want %+v", saved, err, *inf)
+	}
+	if content, err := os.ReadFile(filepath.Join(quarantine, inf.Quarantined)); err != nil || string(content) != clamdtest.EICAR {
+		t.Errorf("quarantined file = %q, %v", content, err)
+	}
+}
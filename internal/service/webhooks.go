@@ -0,0 +1,211 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/nulnl/nulyun/internal/events"
+	"github.com/nulnl/nulyun/internal/model/webhook"
+	storage "github.com/nulnl/nulyun/internal/repository"
+)
+
+const (
+	webhookTimeout     = 10 * time.Second
+	webhookConcurrency = 8
+)
+
+// WebhookBackoff are the delays before retrying a failed delivery. The
+// delivery is abandoned once they are exhausted.
+var WebhookBackoff = []time.Duration{
+	10 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+}
+
+var errPrivateAddress = errors.New("webhook target is not a public address")
+
+// Webhooks delivers the events to the matching webhooks.
+type Webhooks struct {
+	store   *storage.Storage
+	backoff []time.Duration
+	sem     chan struct{}
+
+	// client is used for the webhooks of admins, public for the others,
+	// which can't reach the local network of the server.
+	client *http.Client
+	public *http.Client
+}
+
+// NewWebhooks creates a webhook dispatcher.
+func NewWebhooks(st *storage.Storage) *Webhooks {
+	return &Webhooks{
+		store:   st,
+		backoff: WebhookBackoff,
+		sem:     make(chan struct{}, webhookConcurrency),
+		client:  &http.Client{Timeout: webhookTimeout},
+		public:  &http.Client{Timeout: webhookTimeout, Transport: publicTransport()},
+	}
+}
+
+// publicTransport returns a transport refusing to connect to loopback,
+// private and link-local addresses, checked after name resolution.
+func publicTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     time.Minute,
+	}
+}
+
+// Run dispatches the events received from ch until it is closed.
+func (w *Webhooks) Run(ch <-chan events.Event) {
+	for e := range ch {
+		w.Dispatch(e)
+	}
+}
+
+// Dispatch starts the delivery of an event to the matching webhooks.
+func (w *Webhooks) Dispatch(e events.Event) {
+	hooks, err := w.matching(&e)
+	if err != nil {
+		log.Printf("webhooks: failed to list the webhooks: %v", err)
+		return
+	}
+
+	for _, h := range hooks {
+		go w.deliver(h, e)
+	}
+}
+
+// matching returns the webhooks an event must be delivered to. The events
+// of the other users are only delivered to the webhooks of admins, which
+// their owners may have stopped being.
+func (w *Webhooks) matching(e *events.Event) ([]*webhook.Webhook, error) {
+	hooks, err := w.store.Webhooks.All()
+	if err != nil {
+		return nil, err
+	}
+
+	admins := map[uint]bool{}
+	var res []*webhook.Webhook
+	for _, h := range hooks {
+		if !h.Matches(e) {
+			continue
+		}
+		if h.AllUsers && e.UserID != h.UserID {
+			admin, ok := admins[h.UserID]
+			if !ok {
+				u, err := w.store.Users.Get("", h.UserID)
+				admin = err == nil && u.Perm.Admin
+				admins[h.UserID] = admin
+			}
+			if !admin {
+				continue
+			}
+		}
+		res = append(res, h)
+	}
+	return res, nil
+}
+
+// deliver sends an event to a webhook, retrying with the backoff delays
+// while the webhook still exists and is active.
+func (w *Webhooks) deliver(h *webhook.Webhook, e events.Event) {
+	for attempt := 1; ; attempt++ {
+		if d := w.Send(context.Background(), h, e, attempt); d.Success || attempt > len(w.backoff) {
+			return
+		}
+
+		time.Sleep(w.backoff[attempt-1])
+
+		var err error
+		if h, err = w.store.Webhooks.Get(h.ID); err != nil || !h.Active {
+			return
+		}
+	}
+}
+
+// Send makes a single delivery attempt of an event and records it in the
+// delivery log of the webhook.
+func (w *Webhooks) Send(ctx context.Context, h *webhook.Webhook, e events.Event, attempt int) *webhook.Delivery {
+	w.sem <- struct{}{}
+	defer func() { <-w.sem }()
+
+	d := &webhook.Delivery{
+		WebhookID: h.ID,
+		EventID:   e.ID,
+		Event:     e.Type,
+		Time:      time.Now().Unix(),
+		Attempt:   attempt,
+	}
+
+	start := time.Now()
+	d.Status, d.Error = w.post(ctx, h, e)
+	d.Duration = time.Since(start).Milliseconds()
+	d.Success = d.Error == "" && d.Status >= 200 && d.Status < 300
+
+	if err := w.store.Webhooks.SaveDelivery(d); err != nil {
+		log.Printf("webhooks: failed to record the delivery of %s to webhook %d: %v", e.ID, h.ID, err)
+	}
+
+	return d
+}
+
+func (w *Webhooks) post(ctx context.Context, h *webhook.Webhook, e events.Event) (int, string) {
+	payload, err := json.Marshal(h.Event(e))
+	if err != nil {
+		return 0, err.Error()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err.Error()
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Nulyun-Webhook")
+	req.Header.Set("X-Nulyun-Event", e.Type)
+	req.Header.Set("X-Nulyun-Delivery", e.ID)
+	req.Header.Set("X-Nulyun-Signature", h.Sign(payload))
+
+	client := w.public
+	if u, err := w.store.Users.Get("", h.UserID); err == nil && u.Perm.Admin {
+		client = w.client
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Sprintf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, ""
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/nulnl/nulyun/internal/events"
+	"github.com/nulnl/nulyun/internal/model/users"
+	"github.com/nulnl/nulyun/internal/model/webhook"
+)
+
+func TestWebhooksMatchingOwners(t *testing.T) {
+	st := newStorage(t)
+
+	for _, u := range []*users.User{
+		{Username: "admin", Password: "pw", Scope: ".", Perm: users.Permissions{Admin: true}},
+		{Username: "demoted", Password: "pw", Scope: "."},
+		{Username: "bob", Password: "pw", Scope: "."},
+	} {
+		if err := st.Users.Save(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hooks := map[uint]*webhook.Webhook{}
+	for _, id := range []uint{1, 2} {
+		h, err := webhook.New(id, "https://example.com/hook")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// An admin made the hook of user 2 before it was demoted.
+		h.AllUsers = true
+		if err := st.Webhooks.Save(h); err != nil {
+			t.Fatal(err)
+		}
+		hooks[id] = h
+	}
+
+	w := NewWebhooks(st)
+	match := func(userID uint) []uint {
+		t.Helper()
+		e := events.Event{Type: events.FileCreated, UserID: userID, Path: "/a.txt"}
+		hs, err := w.matching(&e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var owners []uint
+		for _, h := range hs {
+			owners = append(owners, h.UserID)
+		}
+		return owners
+	}
+
+	if got := match(3); len(got) != 1 || got[0] != 1 {
+		t.Errorf("hooks matching the events of another user = %v, want only the admin's", got)
+	}
+	if got := match(2); len(got) != 2 {
+		t.Errorf("hooks matching the events of their owner = %v, want both", got)
+	}
+}
@@ -0,0 +1,14 @@
+import { baseURL } from "@/utils/constants";
+
+// Subscribes to the changes of the files visible to the user. The browser
+// reconnects by itself when the stream is closed. Returns the function
+// closing the stream.
+export function subscribe(onChange: (change: ChangeNotification) => void) {
+  const source = new EventSource(`${baseURL}/api/events`);
+
+  source.addEventListener("change", (event) => {
+    onChange(JSON.parse((event as MessageEvent).data));
+  });
+
+  return () => source.close();
+}
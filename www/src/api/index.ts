@@ -5,5 +5,6 @@ import * as settings from "./settings";
 import * as pub from "./pub";
 import * as webdav from "./webdav";
 import search from "./search";
+import * as events from "./events";
 
-export { files, share, users, settings, pub, webdav, search };
+export { files, share, users, settings, pub, webdav, search, events };
@@ -1,7 +1,11 @@
 import { fetchURL, removePrefix } from "./utils";
 import url from "../utils/url";
 
-export default async function search(base: string, query: string) {
+export default async function search(
+  base: string,
+  query: string,
+  signal?: AbortSignal
+) {
   base = removePrefix(base);
   query = encodeURIComponent(query);
 
@@ -9,7 +13,7 @@ export default async function search(base: string, query: string) {
     base += "/";
   }
 
-  const res = await fetchURL(`/api/search${base}?query=${query}`, {});
+  const res = await fetchURL(`/api/search${base}?query=${query}`, { signal });
 
   let data = await res.json();
 
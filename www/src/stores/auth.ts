@@ -7,10 +7,13 @@ export const useAuthStore = defineStore("auth", {
   state: (): {
     user: IUser | null;
     jwt: string;
+    // username of the admin impersonating the user
+    actor: string | null;
     logoutTimer: number | null;
   } => ({
     user: null,
     jwt: "",
+    actor: null,
     logoutTimer: null,
   }),
   getters: {
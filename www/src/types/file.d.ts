@@ -9,6 +9,9 @@ interface ResourceBase {
   isSymlink: boolean;
   type: ResourceType;
   url: string;
+  tags?: string[];
+  favorite?: boolean;
+  comments?: number;
 }
 
 interface Resource extends ResourceBase {
@@ -21,11 +24,33 @@ interface Resource extends ResourceBase {
   index: number;
   subtitles?: string[];
   content?: string;
+  metadata?: MediaMetadata;
 }
 
 interface ResourceItem extends ResourceBase {
   index: number;
   subtitles?: string[];
+  snippets?: Snippet[];
+}
+
+interface MediaMetadata {
+  taken?: string; // ISO 8601 datetime
+  make?: string;
+  model?: string;
+  lens?: string;
+  width?: number;
+  height?: number;
+  location?: {
+    latitude: number;
+    longitude: number;
+  };
+}
+
+interface Snippet {
+  line: number;
+  before: string;
+  match: string;
+  after: string;
 }
 
 type ResourceType =
@@ -57,3 +82,11 @@ interface BreadCrumb {
   name: string;
   url: string;
 }
+
+interface ChangeNotification {
+  type: string;
+  time: number;
+  path?: string;
+  destination?: string;
+  dirs: string[];
+}
@@ -7,6 +7,16 @@ interface ISettings {
   defaults: SettingsDefaults;
   branding: SettingsBranding;
   tus: SettingsTus;
+  shell: string[];
+  commandTimeout: number;
+  hooks: SettingsHook[];
+}
+
+interface SettingsHook {
+  event: string;
+  command?: string;
+  url?: string;
+  timeout?: number;
 }
 
 interface SettingsDefaults {
@@ -5,6 +5,7 @@ interface IUser {
   scope: string;
   locale: string;
   perm: Permissions;
+  commands: string[];
   lockPassword: boolean;
   hideDotfiles: boolean;
   hideHiddenFolders: boolean;
@@ -27,6 +28,7 @@ interface IUserForm {
   scope?: string;
   locale?: string;
   perm?: Permissions;
+  commands?: string[];
   lockPassword?: boolean;
   hideDotfiles?: boolean;
   hideHiddenFolders?: boolean;
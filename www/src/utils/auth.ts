@@ -8,7 +8,9 @@ import { setSafeTimeout } from "@/api/utils";
 
 export function parseToken(token: string) {
   // falsy or malformed jwt will throw InvalidTokenError
-  const data = jwtDecode<JwtPayload & { user: IUser }>(token);
+  const data = jwtDecode<
+    JwtPayload & { user: IUser; act?: { sub: string; id: number } }
+  >(token);
 
   document.cookie = `auth=${token}; Path=/; SameSite=Strict;`;
 
@@ -16,6 +18,7 @@ export function parseToken(token: string) {
 
   const authStore = useAuthStore();
   authStore.jwt = token;
+  authStore.actor = data.act?.sub ?? null;
   authStore.setUser(data.user);
 
   // proxy auth with custom logout subject to unknown external timeout
@@ -142,6 +145,16 @@ export function logout(reason?: string) {
   document.cookie = "auth=; Max-Age=0; Path=/; SameSite=Strict;";
 
   const authStore = useAuthStore();
+  const jwt = authStore.jwt;
+  if (jwt) {
+    // Revoke the server-side session, the result doesn't block the logout.
+    fetch(`${baseURL}/api/logout`, {
+      method: "POST",
+      headers: {
+        "X-Auth": jwt,
+      },
+    }).catch(() => {});
+  }
   authStore.clearUser();
 
   localStorage.setItem("jwt", "");